@@ -0,0 +1,408 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"metechain/pkg/block"
+	"metechain/pkg/blockchain"
+	"metechain/pkg/blockchain/archive"
+	"metechain/pkg/blockchain/snapshot"
+	"metechain/pkg/config"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/migrate"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+	"metechain/pkg/storage/store/engine"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// runCommand runs one of the offline subcommands instead of starting the node.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "db":
+		return runDB(args[1:])
+	case "export":
+		return chainExport(args[1:])
+	case "import":
+		return chainImport(args[1:])
+	case "snapshot":
+		return runSnapshot(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func runDB(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: metechain db migrate|verify")
+	}
+	switch args[0] {
+	case "migrate":
+		return dbMigrate(args[1:])
+	case "verify":
+		return dbVerify(args[1:])
+	}
+	return fmt.Errorf("unknown db command %q", args[0])
+}
+
+func dbMigrate(args []string) error {
+	fs := flag.NewFlagSet("db migrate", flag.ExitOnError)
+	from := fs.String("from", "", "source database, engine:dir")
+	to := fs.String("to", "", "target database, engine:dir")
+	samples := fs.Int("samples", migrate.DefaultSamples, "number of state roots to verify")
+	fromAncient := fs.String("from-ancient", "", "freezer of the source (default: dir/ancient of --from)")
+	toAncient := fs.String("to-ancient", "", "freezer of the target (default: dir/ancient of --to)")
+	fs.Parse(args)
+	if len(*from) == 0 || len(*to) == 0 {
+		fs.Usage()
+		return errors.New("both --from and --to are required")
+	}
+
+	srcCfg, err := engine.Parse(*from)
+	if err != nil {
+		return err
+	}
+	dstCfg, err := engine.Parse(*to)
+	if err != nil {
+		return err
+	}
+	if srcCfg.Engine == engine.Mem || dstCfg.Engine == engine.Mem {
+		return errors.New("the mem engine can not be migrated")
+	}
+	if srcCfg.Dir() == dstCfg.Dir() {
+		return errors.New("source and target are the same directory")
+	}
+	// the target is synced once at the end of the copy
+	dstCfg.SyncOnCommit = false
+
+	src, err := engine.Open(srcCfg, logger.Logger)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer src.Close()
+	dst, err := engine.Open(dstCfg, logger.Logger)
+	if err != nil {
+		return fmt.Errorf("open target: %w", err)
+	}
+	defer dst.Close()
+
+	keys, err := migrate.Copy(src, dst, func(keys int, size int64) {
+		fmt.Fprintf(os.Stderr, "\rcopied %d keys, %d bytes", keys, size)
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+	if err := migrate.Verify(src, dst, *samples); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	fmt.Printf("migrated %d keys from %s to %s\n", keys, *from, *to)
+
+	// the blocks moved to a freezer are only found in the one of the target
+	frozen, err := blockchain.FrozenHeight(src)
+	if err != nil || frozen == 0 {
+		return err
+	}
+	if len(*fromAncient) == 0 {
+		*fromAncient = filepath.Join(srcCfg.Dir(), "ancient")
+	}
+	if len(*toAncient) == 0 {
+		*toAncient = filepath.Join(dstCfg.Dir(), "ancient")
+	}
+	srcF, err := bgdb.NewFreezer(*fromAncient)
+	if err != nil {
+		return fmt.Errorf("open source freezer: %w", err)
+	}
+	defer srcF.Close()
+	dstF, err := bgdb.NewFreezer(*toAncient)
+	if err != nil {
+		return fmt.Errorf("open target freezer: %w", err)
+	}
+	defer dstF.Close()
+	n, err := migrate.CopyAncients(srcF, dstF)
+	if err != nil {
+		return fmt.Errorf("copy freezer: %w", err)
+	}
+	if err := migrate.VerifyAncients(dst, srcF, dstF, *samples); err != nil {
+		return fmt.Errorf("verify freezer: %w", err)
+	}
+	fmt.Printf("migrated %d frozen blocks from %s to %s\n", n, *fromAncient, *toAncient)
+	return nil
+}
+
+func dbVerify(args []string) error {
+	fs := flag.NewFlagSet("db verify", flag.ExitOnError)
+	dbSpec := fs.String("db", "", "database, engine:dir (default: the storage section of the config)")
+	truncate := fs.Bool("truncate", false, "delete every block from the first bad one up")
+	ancient := fs.String("ancient", "", "freezer of the database (default: the freezer section of the config)")
+	fs.Parse(args)
+
+	db, cfg, err := openStore(*dbSpec)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	f, err := openAncients(db, cfg, *ancient)
+	if err != nil {
+		return err
+	}
+
+	var ancients blockchain.AncientReader
+	if f != nil {
+		ancients = f
+	}
+	problems, err := blockchain.Check(db, ancients, func(h uint64) {
+		if h%10000 == 0 {
+			fmt.Fprintf(os.Stderr, "\rchecked height %d", h)
+		}
+	})
+	fmt.Fprintln(os.Stderr)
+	// the chain opens the freezer itself to truncate
+	if f != nil {
+		f.Close()
+	}
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) == 0 {
+		fmt.Println("no problems found")
+		return nil
+	}
+	if !*truncate {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+
+	first := problems[0].Height
+	if first == blockchain.InitHeight {
+		return errors.New("the genesis block is bad, nothing to truncate back to")
+	}
+	chainCfg := &blockchain.ChainConfig{Miner: &common.Address{}}
+	if cfg != nil && cfg.ChainCfg != nil {
+		chainCfg = cfg.ChainCfg
+		chainCfg.Miner = &common.Address{}
+	}
+	bc, err := blockchain.New(db, chainCfg)
+	if err != nil {
+		return err
+	}
+	if err := bc.DeleteBlock(first); err != nil {
+		return fmt.Errorf("truncate to %d: %w", first-1, err)
+	}
+	fmt.Printf("truncated the chain to height %d\n", first-1)
+	return nil
+}
+
+// openStore opens the database spec, engine:dir, or the one of the storage
+// section of the config if spec is empty. The config is optional when spec is
+// given, it is returned as nil if it can not be loaded.
+func openStore(spec string) (store.DB, *config.CfgInfo, error) {
+	cfg, cfgErr := config.LoadConfig()
+	if cfgErr != nil {
+		cfg = nil
+	}
+	var storageCfg *engine.Config
+	if len(spec) > 0 {
+		var err error
+		if storageCfg, err = engine.Parse(spec); err != nil {
+			return nil, nil, err
+		}
+	} else if cfgErr != nil {
+		return nil, nil, fmt.Errorf("load config: %w", cfgErr)
+	} else {
+		storageCfg = cfg.StorageCfg
+	}
+	db, err := engine.Open(storageCfg, logger.Logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, cfg, nil
+}
+
+// openAncients opens the freezer in dir, or the one of the config if dir is
+// empty, if blocks of db were moved to one. It returns nil if none were.
+func openAncients(db store.DB, cfg *config.CfgInfo, dir string) (*bgdb.Freezer, error) {
+	frozen, err := blockchain.FrozenHeight(db)
+	if err != nil || frozen == 0 {
+		return nil, err
+	}
+	if len(dir) == 0 && cfg != nil && cfg.ChainCfg != nil && cfg.ChainCfg.Freezer != nil {
+		dir = cfg.ChainCfg.Freezer.Dir
+	}
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("the blocks below %d are in a freezer, --ancient is required", frozen)
+	}
+	return bgdb.NewFreezer(dir)
+}
+
+// openChain opens the blockchain described by the config file.
+func openChain() (*blockchain.Blockchain, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	if cfg.ChainCfg == nil || cfg.MinerConfig == nil {
+		return nil, errors.New("load nil config")
+	}
+	db, err := engine.Open(cfg.StorageCfg, logger.Logger)
+	if err != nil {
+		return nil, err
+	}
+	mineraddr := common.HexToAddress(cfg.MinerConfig.MiningAddr)
+	cfg.ChainCfg.Miner = &mineraddr
+	bc, err := blockchain.New(db, cfg.ChainCfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return bc, nil
+}
+
+func chainExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	from := fs.Uint64("from", 1, "first height to export")
+	to := fs.Uint64("to", 0, "last height to export (default: the tip)")
+	out := fs.String("out", "", "archive file (default: stdout)")
+	fs.Parse(args)
+
+	bc, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	if *to == 0 {
+		if *to, err = bc.GetMaxBlockHeight(); err != nil {
+			return err
+		}
+	}
+	w := os.Stdout
+	if len(*out) > 0 {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	n, err := archive.Export(bc, w, *from, *to)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d blocks\n", n)
+	return nil
+}
+
+func chainImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	trusted := fs.Bool("trusted", false, "add blocks without validating them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: metechain import [--trusted] chain.bin")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bc, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	n, err := archive.Import(bc, f, *trusted, func(b *block.Block) {
+		if b.Height%1000 == 0 {
+			fmt.Fprintf(os.Stderr, "imported height %d\n", b.Height)
+		}
+	})
+	fmt.Fprintf(os.Stderr, "imported %d blocks\n", n)
+	return err
+}
+
+func runSnapshot(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: metechain snapshot export|import")
+	}
+	switch args[0] {
+	case "export":
+		return snapshotExport(args[1:])
+	case "import":
+		return snapshotImport(args[1:])
+	}
+	return fmt.Errorf("unknown snapshot command %q", args[0])
+}
+
+func snapshotExport(args []string) error {
+	fs := flag.NewFlagSet("snapshot export", flag.ExitOnError)
+	dbSpec := fs.String("db", "", "database, engine:dir (default: the storage section of the config)")
+	height := fs.Uint64("height", 0, "height of the state to export (default: the tip)")
+	out := fs.String("out", "", "snapshot file (default: stdout)")
+	ancient := fs.String("ancient", "", "freezer of the database (default: the freezer section of the config)")
+	fs.Parse(args)
+
+	db, cfg, err := openStore(*dbSpec)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	f, err := openAncients(db, cfg, *ancient)
+	if err != nil {
+		return err
+	}
+	var ancients blockchain.AncientReader
+	if f != nil {
+		defer f.Close()
+		ancients = f
+	}
+
+	w := os.Stdout
+	if len(*out) > 0 {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	stats, err := snapshot.Export(db, ancients, w, *height)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d headers, %d trie nodes, %d codes, %d bytes\n",
+		stats.Blocks, stats.Nodes, stats.Codes, stats.Bytes)
+	return nil
+}
+
+func snapshotImport(args []string) error {
+	fs := flag.NewFlagSet("snapshot import", flag.ExitOnError)
+	dbSpec := fs.String("db", "", "database, engine:dir (default: the storage section of the config)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: metechain snapshot import [--db engine:dir] state.snap")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	db, _, err := openStore(*dbSpec)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	hdr, err := snapshot.Import(db, f, func(stats snapshot.Stats) {
+		fmt.Fprintf(os.Stderr, "\rimported %d headers, %d trie nodes, %d bytes", stats.Blocks, stats.Nodes, stats.Bytes)
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported the state of height %d, root %x\n", hdr.Height, hdr.Root)
+	return nil
+}
@@ -18,6 +18,7 @@ require (
 	github.com/gogf/gf v1.16.6
 	github.com/goinggo/mapstructure v0.0.0-20140717182941-194205d9b4a9
 	github.com/golang/protobuf v1.5.3
+	github.com/google/btree v1.0.0
 	github.com/gorilla/handlers v1.5.1
 	github.com/hashicorp/memberlist v0.2.4
 	github.com/spf13/viper v1.8.1
@@ -66,7 +67,6 @@ require (
 	github.com/golang/glog v1.0.0 // indirect
 	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
 	github.com/golang/snappy v0.0.4 // indirect
-	github.com/google/btree v1.0.0 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.2.3 // indirect
 	github.com/googleapis/gax-go/v2 v2.7.1 // indirect
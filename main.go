@@ -18,11 +18,11 @@ import (
 	"metechain/pkg/controller"
 	"metechain/pkg/logger"
 	"metechain/pkg/p2p"
-	"metechain/pkg/storage/store/pb"
+	"metechain/pkg/storage/store/engine"
+	"metechain/pkg/transaction"
 	"metechain/pkg/txpool"
 	"metechain/pkg/util/ntp"
 
-	"github.com/cockroachdb/pebble"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid"
 
@@ -44,14 +44,6 @@ var GreamHost *string
 const Version = "version: matechain v0.0.0"
 
 func init() {
-	if err := ntp.UpdateTimeFromNtp(); err != nil {
-		panic(fmt.Errorf("failed to set time:%s", err))
-	}
-
-	if err := logger.InitLogger(logger.DefaultConfig()); err != nil {
-		panic(err)
-	}
-
 	// if err := logger.RewriteStderrFile("runtime_err"); err != nil {
 	// 	panic(err)
 	// }
@@ -65,6 +57,22 @@ func init() {
 
 	flag.Parse()
 
+	logCfg := logger.DefaultConfig()
+	// offline commands such as "export" may write their output to stdout
+	logCfg.Stderr = flag.NArg() > 0
+	if err := logger.InitLogger(logCfg); err != nil {
+		panic(err)
+	}
+
+	// offline commands such as "db migrate" need neither ntp nor mining
+	if flag.NArg() > 0 {
+		return
+	}
+
+	if err := ntp.UpdateTimeFromNtp(); err != nil {
+		panic(fmt.Errorf("failed to set time:%s", err))
+	}
+
 	if err := miner.SetConf(*avlNum, *cycle); err != nil {
 		fmt.Println("-b 0x12345678", err)
 		os.Exit(1)
@@ -77,16 +85,18 @@ func init() {
 }
 
 func main() {
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var nodeName string
 	id, _ := uuid.NewV4()
 	nodeName = id.String()
 
-	plog := logger.Logger.Named("pebble").Sugar()
-	db, err := pebble.Open("pebble.db", &pebble.Options{Logger: plog})
-	if err != nil {
-		panic(err)
-	}
-
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic(err)
@@ -102,12 +112,15 @@ func main() {
 	// mineraddr, _ := address.StringToAddress(cfg.MinerConfig.MiningAddr)
 	mineraddr := common.HexToAddress(cfg.MinerConfig.MiningAddr)
 	cfg.ChainCfg.Miner = &mineraddr
-	b, err := blockchain.New(pb.New(db), cfg.ChainCfg)
+
+	db, err := engine.Open(cfg.StorageCfg, logger.Logger)
+	if err != nil {
+		panic(err)
+	}
+	b, err := blockchain.New(db, cfg.ChainCfg)
 	if err != nil {
 		panic(err)
 	}
-
-	pool, err := txpool.NewPool(txpool.Config{b, logger.Logger})
 
 	//contract server
 	/* 	logger.Info("metemashk", zap.Int64("chain ID", cfg.ChainCfg.ChainId))
@@ -136,6 +149,24 @@ func main() {
 
 	}
 
+	cfg.TxPoolCfg.BlockChain = b
+	cfg.TxPoolCfg.Logger = logger.Logger
+	if node != nil {
+		// the local transactions reloaded from the journal are sent again
+		cfg.TxPoolCfg.Broadcast = func(st *transaction.SignedTransaction) {
+			data, err := st.Serialize()
+			if err != nil {
+				logger.Error("serialize journaled transaction", zap.Error(err))
+				return
+			}
+			node.SendMessage(p2p.PayloadMessageType, append([]byte{controller.TypeTransaction}, data...))
+		}
+	}
+	pool, err := txpool.NewPool(*cfg.TxPoolCfg)
+	if err != nil {
+		panic(err)
+	}
+
 	cbc := consensus.New(b)
 
 	cfg.MinerConfig.NoMining = *NoMining
@@ -210,6 +241,7 @@ func main() {
 		ctrlC := make(chan os.Signal)
 		signal.Notify(ctrlC, os.Interrupt, syscall.SIGTERM)
 		<-ctrlC
+		pool.Close()
 		b.Close()
 	}
 }
@@ -18,6 +18,17 @@ const (
 	Precision = int64(18)
 )
 
+const (
+	// Version1 blocks commit by proof of work to their transactions only
+	Version1 = uint64(1)
+	// Version2 blocks also commit to the state root, the receipts root, the
+	// gas used, the miner and the difficulty
+	Version2 = uint64(2)
+	// Version3 blocks carry the base fee per gas and their transactions pay
+	// for the gas they use, up to the gas limit of the block
+	Version3 = uint64(3)
+)
+
 // var MinerRewardNumber = big.NewInt(50).
 
 // Block Struct
@@ -37,6 +48,8 @@ type Block struct {
 	Nonce            uint64   `json:"nonce"`            //区块nonce
 	GasLimit         uint64   `json:"gasLimit"`
 	GasUsed          *big.Int `json:"gasUsed"`
+	ReceiptsRoot     []byte   `json:"receiptsroot"` // since Version2
+	BaseFee          *big.Int `json:"baseFee"`      // since Version3
 }
 
 type BlockHead struct {
@@ -47,6 +60,14 @@ type BlockHead struct {
 	GenesisHash string
 }
 
+// ReorgEvent is sent when the main chain switches to another branch
+type ReorgEvent struct {
+	// Dropped are the blocks disconnected from the main chain, by height
+	Dropped []*Block
+	// Added are the blocks of the branch connected instead, by height
+	Added []*Block
+}
+
 func (b *Block) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 	scratch := make([]byte, 8)
@@ -271,6 +292,26 @@ func (b *Block) UnmarshalCBOR(r io.Reader) error {
 		b.GasUsed = new(big.Int).SetBytes(data)
 	}
 
+	// receiptsroot
+	if b.Version >= Version2 {
+		data, err := transaction.UnmarshalByteString(br)
+		if err != nil {
+			return fmt.Errorf("receipts root:%w", err)
+		}
+
+		b.ReceiptsRoot = data
+	}
+
+	// basefee
+	if b.Version >= Version3 {
+		data, err := transaction.UnmarshalByteString(br)
+		if err != nil {
+			return fmt.Errorf("base fee:%w", err)
+		}
+
+		b.BaseFee = new(big.Int).SetBytes(data)
+	}
+
 	return nil
 }
 
@@ -427,6 +468,33 @@ func (b *Block) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// receiptsroot
+	if b.Version >= Version2 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajByteString, uint64(len(b.ReceiptsRoot))); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(b.ReceiptsRoot); err != nil {
+			return err
+		}
+	}
+
+	// basefee
+	if b.Version >= Version3 {
+		if b.BaseFee == nil {
+			return fmt.Errorf("base fee cannot be nil")
+		}
+
+		data := b.BaseFee.Bytes()
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajByteString, uint64(len(data))); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -466,7 +534,8 @@ func (b *Block) SetHash() error {
 	return nil
 }
 
-//SetHash hash the block data
+// MinerHash is the hash the proof of work is done on. From Version2 on it
+// commits to the result of executing the block as well.
 func (b *Block) MinerHash() []byte {
 	heightBytes := miscellaneous.E64func(b.Height)
 	var txs [][]byte
@@ -480,7 +549,15 @@ func (b *Block) MinerHash() []byte {
 	txsBytes := bytes.Join(txs, []byte{})
 	timeBytes := miscellaneous.E64func(b.Timestamp)
 	nonce := miscellaneous.E64func(uint64(b.Nonce))
-	blockBytes := bytes.Join([][]byte{heightBytes, b.PrevHash, txsBytes, timeBytes, nonce, b.Root}, []byte{})
+	parts := [][]byte{heightBytes, b.PrevHash, txsBytes, timeBytes, nonce, b.Root}
+	if b.Version >= Version2 {
+		parts = append(parts, b.SnapRoot, b.ReceiptsRoot, common.LeftPadBytes(b.GasUsed.Bytes(), 32),
+			b.Miner.Bytes(), common.LeftPadBytes(b.GlobalDifficulty.Bytes(), 32))
+	}
+	if b.Version >= Version3 {
+		parts = append(parts, common.LeftPadBytes(b.BaseFee.Bytes(), 32))
+	}
+	blockBytes := bytes.Join(parts, []byte{})
 	hash := sha3.Sum256(blockBytes)
 
 	return hash[:]
@@ -28,6 +28,13 @@ func (bc *Blockchain) NewTransaction() store.Transaction {
 
 // AddBlock add blocks to blockchain
 func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Transaction) error {
+	return bc.addTempBlock(block, DBTransaction, false)
+}
+
+// addTempBlock adds the block to DBTransaction. If seal is set the block
+// takes the roots and gas used of executing it instead of having to commit to
+// them.
+func (bc *Blockchain) addTempBlock(block *block.Block, DBTransaction store.Transaction, seal bool) error {
 	logger.Debug("AddTempBlock", zap.Uint64("blockHeight", block.Height), zap.String("hash", hex.EncodeToString(block.Hash)))
 
 	var err error
@@ -85,7 +92,19 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 	}
 
 	var blockGasU = new(big.Int)
+	gasPool := block.GasLimit
+	receipts := make([]*transaction.Receipt, 0, len(block.Transactions))
 	for index, tx := range block.Transactions {
+		if gasMetered(block) {
+			receipt, err := bc.applyTransaction(DBTransaction, block, tx, index, &gasPool)
+			if err != nil {
+				logger.Error("Failed to apply transaction", zap.Error(err), zap.String("hash", tx.HashToString()))
+				return err
+			}
+			receipts = append(receipts, receipt)
+			blockGasU.Add(blockGasU, receipt.GasUsed)
+			continue
+		}
 		if tx.Transaction.IsCoinBaseTransaction() {
 			txHash := tx.Hash()
 			if err = setTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), txHash, height, uint64(index)); err != nil {
@@ -97,6 +116,7 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 			//	gas := tx.Transaction.GasLimit * tx.Transaction.GasPrice
 			// tx.GasUsed = tx.Transaction.GasLimit * tx.Transaction.GasPrice
 			tx.GasUsed = new(big.Int).Mul(tx.GasLimit, tx.GasPrice)
+			receipts = append(receipts, transferReceipt(tx))
 			// blockGasU += tx.GasUsed
 			blockGasU.Add(blockGasU, tx.GasUsed)
 			if err = setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
@@ -116,7 +136,7 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 				return err
 			}
 
-			gasLeft, err := bc.handleContractTransaction(block, DBTransaction, tx, index)
+			gasLeft, receipt, err := bc.handleContractTransaction(block, DBTransaction, tx, index, contractGasLimit(tx), tx.GasPrice)
 			if err != nil {
 				logger.Error("Failed to HandleContractTransaction", zap.Error(err), zap.String("hash", transaction.HashToString(txHash)))
 				return err
@@ -130,6 +150,8 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 			}
 
 			tx.GasUsed = new(big.Int).Sub(new(big.Int).SetUint64(evmcfg.GasLimit), gasLeft)
+			receipt.GasUsed.Set(tx.GasUsed)
+			receipts = append(receipts, receipt)
 			blockGasU.Add(blockGasU, tx.GasUsed)
 
 			if err = setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
@@ -169,6 +191,7 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 			// tx.GasUsed = tx.Transaction.GasLimit * tx.Transaction.GasPrice
 			// blockGasU += tx.GasUsed
 			tx.GasUsed = new(big.Int).Mul(tx.GasLimit, tx.GasPrice)
+			receipts = append(receipts, transferReceipt(tx))
 			blockGasU.Add(blockGasU, tx.GasUsed)
 
 			if err = setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
@@ -191,7 +214,15 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 		return err
 	}
 
-	if block.Height != 1 {
+	receiptsRoot, err := deriveReceipts(receipts)
+	if err != nil {
+		logger.Error("Failed to derive receipts", zap.Error(err))
+		return err
+	}
+
+	if seal {
+		block.SnapRoot, block.ReceiptsRoot = comHash.Bytes(), receiptsRoot
+	} else if block.Height != 1 {
 		oldSnapRootkey, err := DBTransaction.Get(SnapRootKey)
 		if err != nil {
 			return err
@@ -201,10 +232,8 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 		logger.Info("AddTempBlock", zap.String("b.SnapRootkey", hex.EncodeToString(block.SnapRoot)))
 		logger.Info("AddTempBlock", zap.String("newSnapRootkey", hex.EncodeToString(comHash.Bytes())))
 
-		if bytes.Equal(oldSnapRootkey, block.SnapRoot) {
-			block.SnapRoot = comHash.Bytes()
-		} else if !bytes.Equal(comHash.Bytes(), block.SnapRoot) {
-			return fmt.Errorf("SnapRoot not equal")
+		if err := verifyRoots(block, oldSnapRootkey, comHash.Bytes(), receiptsRoot, blockGasU); err != nil {
+			return err
 		}
 	} else {
 		block.SnapRoot = comHash.Bytes()
@@ -223,6 +252,11 @@ func (bc *Blockchain) AddTempBlock(block *block.Block, DBTransaction store.Trans
 		return err
 	}
 
+	if err = setReceipts(DBTransaction, block, receipts); err != nil {
+		logger.Error("Failed to set receipts", zap.Error(err))
+		return err
+	}
+
 	{
 		comHashWrite, err := factCommit(bc.sdb, true)
 		if err != nil {
@@ -332,14 +366,14 @@ func (bc *Blockchain) DeleteTempBlockTest(height uint64, DBTransaction store.Tra
 
 		for i, tx := range block.Transactions {
 			if tx.IsCoinBaseTransaction() {
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
 				}
 			} else if tx.IsEvmContractTransaction() {
 				//新链无绑定地址接口，暂忽略setBindingKey
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
@@ -356,13 +390,13 @@ func (bc *Blockchain) DeleteTempBlockTest(height uint64, DBTransaction store.Tra
 					}
 				}
 
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
 				}
 
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
@@ -389,6 +423,10 @@ func (bc *Blockchain) DeleteTempBlockTest(height uint64, DBTransaction store.Tra
 			logger.Error("Failed to Del height and hash", zap.Error(err))
 			return err
 		}
+		if err = deleteReceipts(DBTransaction, block.Hash); err != nil {
+			logger.Error("Failed to Del receipts", zap.Error(err))
+			return err
+		}
 
 		DBTransaction.Set(SnapRootKey, sn)
 		DBTransaction.Set(HeightKey, miscellaneous.E64func(dH-1))
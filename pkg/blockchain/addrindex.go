@@ -0,0 +1,258 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"metechain/pkg/block"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// MaxAddrTxsLimit caps the page size of GetTransactionsByAddress.
+const MaxAddrTxsLimit = 1000
+
+// positionLen is the length of a position in the address index and of a
+// cursor: the big-endian height of the block and index in the block.
+const positionLen = 12
+
+var (
+	// AddrTxPrefix prefixes the ordered index of transactions by address:
+	// AddrTxPrefix + address + position -> transaction hash.
+	AddrTxPrefix = []byte("addrTxs")
+	// AddrTxIndexedKey is set once the blocks stored before the index existed
+	// are indexed.
+	AddrTxIndexedKey = []byte("addrTxsIndexed")
+)
+
+// ErrCursor is returned for a cursor that was not returned by
+// GetTransactionsByAddress.
+var ErrCursor = errors.New("invalid cursor")
+
+// Direction orders the pages of GetTransactionsByAddress.
+type Direction int
+
+const (
+	// Descending returns the newest transactions first.
+	Descending Direction = iota
+	// Ascending returns the oldest transactions first.
+	Ascending
+)
+
+func position(height, index uint64) []byte {
+	pos := make([]byte, positionLen)
+	binary.BigEndian.PutUint64(pos, height)
+	binary.BigEndian.PutUint32(pos[8:], uint32(index))
+	return pos
+}
+
+func addrTxKey(addr []byte, height, index uint64) []byte {
+	key := append(append([]byte{}, AddrTxPrefix...), addr...)
+	return append(key, position(height, index)...)
+}
+
+// setAddrTx adds the transaction at height and index to the history of addr
+func setAddrTx(DBTransaction store.Transaction, addr, hash []byte, height, index uint64) error {
+	return DBTransaction.Set(addrTxKey(addr, height, index), hash)
+}
+
+// delAddrTx removes the transaction at height and index from the history of addr
+func delAddrTx(DBTransaction store.Transaction, addr []byte, height, index uint64) error {
+	return DBTransaction.Del(addrTxKey(addr, height, index))
+}
+
+// txAddrs returns the addresses whose history holds tx, as AddBlock indexes them
+func txAddrs(tx *transaction.FinishedTransaction) [][]byte {
+	switch {
+	case tx.IsCoinBaseTransaction():
+		return [][]byte{tx.Transaction.To.Bytes()}
+	case tx.IsEvmContractTransaction():
+		return [][]byte{tx.Transaction.From.Bytes()}
+	}
+	return [][]byte{tx.Transaction.From.Bytes(), tx.Transaction.To.Bytes()}
+}
+
+// indexAddrTxs adds the blocks stored before the address index existed to it
+func (bc *Blockchain) indexAddrTxs() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if _, err := bc.db.Get(AddrTxIndexedKey); err == nil {
+		return nil
+	} else if err != store.NotExist {
+		return err
+	}
+	maxH, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return err
+	}
+
+	tx := bc.db.NewTransaction()
+	defer func() { tx.Cancel() }()
+	for h := uint64(0); h <= maxH; h++ {
+		b, err := bc.blockAt(h)
+		if err == store.NotExist {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("index block %d: %v", h, err)
+		}
+		for i, t := range b.Transactions {
+			for _, addr := range txAddrs(t) {
+				if err := setAddrTx(tx, addr, t.Hash(), h, uint64(i)); err != nil {
+					return err
+				}
+			}
+		}
+		if h%1000 == 999 {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			tx = bc.db.NewTransaction()
+			logger.Info("index address transactions", zap.Uint64("height", h), zap.Uint64("max height", maxH))
+		}
+	}
+	if err := tx.Set(AddrTxIndexedKey, []byte{1}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// blockAt get the block of the height, genesis included
+func (bc *Blockchain) blockAt(height uint64) (*block.Block, error) {
+	hash, err := bc.getHash(height)
+	if err != nil {
+		return nil, err
+	}
+	data, err := bc.readBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	return block.Deserialize(data)
+}
+
+// addrTx is an entry of the address index.
+type addrTx struct {
+	pos  []byte
+	hash []byte
+}
+
+// GetTransactionsByAddress returns a page of at most limit transactions sent or
+// received by addr, in the direction dir starting at cursor, and the cursor of
+// the next page, which is nil after the last page. An empty cursor starts at
+// the newest or the oldest transaction.
+func (bc *Blockchain) GetTransactionsByAddress(addr common.Address, cursor []byte, limit int, dir Direction) ([]*transaction.FinishedTransaction, []byte, error) {
+	if len(cursor) != 0 && len(cursor) != positionLen {
+		return nil, nil, ErrCursor
+	}
+	if limit <= 0 || limit > MaxAddrTxsLimit {
+		limit = MaxAddrTxsLimit
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	maxH, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, nil, err
+	}
+	// blocks above the tip that New rolled back are still indexed until
+	// their height is used again
+	top := position(maxH, 1<<32-1)
+
+	prefix := append(append([]byte{}, AddrTxPrefix...), addr.Bytes()...)
+	var entries []addrTx
+	if dir == Ascending {
+		entries, err = bc.addrTxsWindow(prefix, cursor, top, limit+1)
+	} else {
+		if len(cursor) == 0 || bytes.Compare(cursor, top) > 0 {
+			cursor = top
+		}
+		entries, err = bc.addrTxsBefore(prefix, cursor, limit+1)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next []byte
+	if len(entries) > limit {
+		next = entries[limit].pos
+		entries = entries[:limit]
+	}
+	txs := make([]*transaction.FinishedTransaction, 0, len(entries))
+	var b *block.Block
+	for _, e := range entries {
+		height := binary.BigEndian.Uint64(e.pos)
+		index := binary.BigEndian.Uint32(e.pos[8:])
+		if b == nil || b.Height != height {
+			if b, err = bc.blockAt(height); err != nil {
+				return nil, nil, err
+			}
+		}
+		// the entry is left over from a rolled back block that the block
+		// now at its height replaced
+		if int(index) >= len(b.Transactions) || !bytes.Equal(b.Transactions[index].Hash(), e.hash) {
+			continue
+		}
+		tx := b.Transactions[index]
+		tx.BlockNum = b.Height
+		txs = append(txs, tx)
+	}
+	return txs, next, nil
+}
+
+// addrTxsBefore returns up to n entries of the index under prefix, newest
+// first, starting at the position cursor. The iterators of the store only go
+// forward, so it scans a window of heights below the cursor and doubles the
+// window until it holds n entries or reaches genesis.
+func (bc *Blockchain) addrTxsBefore(prefix, cursor []byte, n int) ([]addrTx, error) {
+	end := binary.BigEndian.Uint64(cursor)
+	for span := uint64(n); ; span *= 2 {
+		var from uint64
+		if end > span {
+			from = end - span
+		}
+		entries, err := bc.addrTxsWindow(prefix, position(from, 0), cursor, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) >= n || from == 0 {
+			for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+				entries[i], entries[j] = entries[j], entries[i]
+			}
+			if len(entries) > n {
+				entries = entries[:n]
+			}
+			return entries, nil
+		}
+	}
+}
+
+// addrTxsWindow returns the entries under prefix between the positions from
+// and to, both included, oldest first. It stops after n entries unless n is 0.
+func (bc *Blockchain) addrTxsWindow(prefix, from, to []byte, n int) ([]addrTx, error) {
+	itr := bc.db.NewIterator(prefix, from)
+	defer itr.Release()
+
+	var entries []addrTx
+	for (n == 0 || len(entries) < n) && itr.Next() {
+		key := itr.Key()
+		if len(key) != len(prefix)+positionLen {
+			continue
+		}
+		pos := key[len(prefix):]
+		if bytes.Compare(pos, to) > 0 {
+			break
+		}
+		entries = append(entries, addrTx{
+			pos:  append([]byte{}, pos...),
+			hash: append([]byte{}, itr.Value()...),
+		})
+	}
+	return entries, itr.Error()
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"metechain/pkg/storage/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// minerTxs returns the hashes of the transactions of the miner up to height,
+// oldest first, read from the blocks
+func minerTxs(t *testing.T, bc *Blockchain, height uint64) [][]byte {
+	var hashes [][]byte
+	for h := uint64(0); h <= height; h++ {
+		b, err := bc.blockAt(h)
+		assert.NoError(t, err)
+		for _, tx := range b.Transactions {
+			for _, addr := range txAddrs(tx) {
+				if bytes.Equal(addr, chainCfg.Miner.Bytes()) {
+					hashes = append(hashes, tx.Hash())
+				}
+			}
+		}
+	}
+	return hashes
+}
+
+func TestGetTransactionsByAddress(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	miner := *chainCfg.Miner
+
+	want := minerTxs(t, bc, 3)
+	assert.True(len(want) >= 4)
+
+	var got [][]byte
+	var cursor []byte
+	for {
+		txs, next, err := bc.GetTransactionsByAddress(miner, cursor, 3, Ascending)
+		assert.NoError(err)
+		assert.True(len(txs) <= 3)
+		for _, tx := range txs {
+			got = append(got, tx.Hash())
+		}
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(want, got)
+
+	got, cursor = nil, nil
+	for {
+		txs, next, err := bc.GetTransactionsByAddress(miner, cursor, 1, Descending)
+		assert.NoError(err)
+		assert.Len(txs, 1)
+		got = append([][]byte{txs[0].Hash()}, got...)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(want, got)
+
+	txs, next, err := bc.GetTransactionsByAddress(miner, nil, 0, Descending)
+	assert.NoError(err)
+	assert.Nil(next)
+	assert.Len(txs, len(want))
+	assert.Equal(uint64(3), txs[0].BlockNum)
+
+	_, _, err = bc.GetTransactionsByAddress(miner, []byte{1}, 1, Ascending)
+	assert.Equal(ErrCursor, err)
+
+	// drop the index, New builds it again from the blocks and rolls block 3 back
+	itr := db.NewIterator(AddrTxPrefix, nil)
+	var keys [][]byte
+	for itr.Next() {
+		keys = append(keys, append([]byte{}, itr.Key()...))
+	}
+	itr.Release()
+	assert.NotEmpty(keys)
+	for _, key := range keys {
+		assert.NoError(db.Del(key))
+	}
+	_, err = db.Get(AddrTxIndexedKey)
+	assert.Equal(store.NotExist, err)
+
+	bc, err = New(db, chainCfg)
+	assert.NoError(err)
+	txs, _, err = bc.GetTransactionsByAddress(miner, nil, 0, Ascending)
+	assert.NoError(err)
+	got = nil
+	for _, tx := range txs {
+		got = append(got, tx.Hash())
+	}
+	assert.Equal(minerTxs(t, bc, 2), got)
+}
@@ -0,0 +1,372 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"metechain/pkg/block"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultFreezerDepth is the number of blocks below the tip kept in the
+	// database when FreezerConfig.Depth is 0.
+	DefaultFreezerDepth = 90000
+
+	// minFreezerDepth keeps the blocks that New and reorganizations touch.
+	minFreezerDepth = 128
+	// freezeInterval is the number of blocks between two freeze runs.
+	freezeInterval = 64
+	// freezeBatch is the number of blocks moved in one go.
+	freezeBatch = 1000
+)
+
+// FrozenKey holds the number of blocks moved to the freezer. Their blocks,
+// receipts and blooms are gone from the database, the hashes of the blocks
+// and of their transactions map to their height in the freezer instead. The
+// height index of the main chain stays.
+var FrozenKey = []byte("ancientFrozen")
+
+// frozenRef returns the height in the freezer the value v of a block or
+// transaction hash refers to. Blocks and transaction lookups that are not
+// frozen are longer.
+func frozenRef(v []byte) (uint64, bool) {
+	if len(v) != 8 {
+		return 0, false
+	}
+	h, err := miscellaneous.D64func(v)
+	return h, err == nil
+}
+
+// AncientReader reads the items of a freezer, see bgdb.Freezer.
+type AncientReader interface {
+	Ancient(kind string, number uint64) ([]byte, error)
+}
+
+// FreezerConfig moves blocks older than Depth from the database to
+// append-only files in Dir.
+type FreezerConfig struct {
+	Dir   string `yaml:"dir"`
+	Depth uint64 `yaml:"depth"`
+}
+
+func (cfg *FreezerConfig) depth() uint64 {
+	switch {
+	case cfg.Depth == 0:
+		return DefaultFreezerDepth
+	case cfg.Depth < minFreezerDepth:
+		return minFreezerDepth
+	}
+	return cfg.Depth
+}
+
+// FrozenHeight returns the number of blocks of db that were moved to a
+// freezer, all heights below it.
+func FrozenHeight(db store.DB) (uint64, error) {
+	v, err := db.Get(FrozenKey)
+	if err == store.NotExist {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return miscellaneous.D64func(v)
+}
+
+// openFreezer opens the freezer of cfg. Blocks a crash left in both the
+// freezer and the database are removed from the database.
+func (bc *Blockchain) openFreezer(cfg *FreezerConfig) error {
+	if len(cfg.Dir) == 0 {
+		return fmt.Errorf("freezer: no directory")
+	}
+	f, err := bgdb.NewFreezer(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	bc.cdb = bgdb.NewDatabaseWithFreezer(bc.db, f)
+
+	n, err := bc.cdb.Ancients()
+	if err != nil {
+		return err
+	}
+	frozen, err := FrozenHeight(bc.db)
+	if err != nil {
+		return err
+	}
+	if frozen > n {
+		return fmt.Errorf("freezer: holds %d blocks, the database lost %d", n, frozen)
+	}
+	var blocks []*block.Block
+	for h := frozen; h < n; h++ {
+		data, err := bc.cdb.Ancient(bgdb.FreezerBodiesTable, h)
+		if err != nil {
+			return err
+		}
+		b, err := block.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, b)
+	}
+	if len(blocks) > 0 {
+		return bc.dropFrozen(blocks, n)
+	}
+	return nil
+}
+
+// maybeFreeze starts a freeze run in the background every freezeInterval
+// blocks, unless one is still running. The caller holds bc.mu.
+func (bc *Blockchain) maybeFreeze(height uint64) {
+	if bc.ChainCfg.Freezer == nil || height%freezeInterval != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&bc.freezing, 0, 1) {
+		return
+	}
+	bc.background(func() {
+		defer atomic.StoreInt32(&bc.freezing, 0)
+		n, err := bc.Freeze()
+		if err != nil {
+			logger.Error("failed to freeze blocks", zap.Error(err))
+			return
+		}
+		if n > 0 {
+			logger.Info("froze blocks", zap.Int("blocks", n), zap.Uint64("height", height))
+		}
+	})
+}
+
+// Freeze moves the main chain blocks more than the configured depth below
+// the tip to the freezer and returns how many were moved. A run stops early
+// once the chain is closing.
+func (bc *Blockchain) Freeze() (int, error) {
+	if bc.ChainCfg.Freezer == nil {
+		return 0, nil
+	}
+	var moved int
+	for !bc.closing() {
+		n, err := bc.freezeBatch(bc.ChainCfg.Freezer.depth())
+		moved += n
+		if err != nil || n == 0 {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// freezeBatch moves up to freezeBatch blocks to the freezer. They are read
+// under the read lock of the chain and appended without it; the write lock
+// is only taken to drop them from the database.
+func (bc *Blockchain) freezeBatch(depth uint64) (int, error) {
+	blocks, err := bc.frozenCandidates(depth)
+	if err != nil || len(blocks) == 0 {
+		return 0, err
+	}
+	frozen := blocks[0].Height
+	if err := bc.appendAncients(blocks); err != nil {
+		if terr := bc.cdb.TruncateAncients(frozen); terr != nil {
+			logger.Error("failed to truncate the freezer", zap.Error(terr), zap.Uint64("items", frozen))
+		}
+		return 0, err
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	// a reorganization may have replaced the blocks in the meantime
+	dropped := make([]*block.Block, 0, len(blocks))
+	for _, b := range blocks {
+		hash, err := bc.getHash(b.Height)
+		if err == nil && !bytes.Equal(hash, b.Hash) {
+			err = fmt.Errorf("block %d left the main chain", b.Height)
+		}
+		if err != nil {
+			if terr := bc.cdb.TruncateAncients(frozen); terr != nil {
+				logger.Error("failed to truncate the freezer", zap.Error(terr), zap.Uint64("items", frozen))
+			}
+			return 0, err
+		}
+		dropped = append(dropped, b.Block)
+	}
+	return len(dropped), bc.dropFrozen(dropped, frozen+uint64(len(dropped)))
+}
+
+// ancientBlock is a block to freeze with its encoding and its encoded
+// receipts, nil if it has none.
+type ancientBlock struct {
+	*block.Block
+	body, receipts []byte
+}
+
+// frozenCandidates returns the next main chain blocks at least depth below
+// the tip that are not frozen yet.
+func (bc *Blockchain) frozenCandidates(depth uint64) ([]ancientBlock, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	tip, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := bc.cdb.Ancients()
+	if err != nil {
+		return nil, err
+	}
+	if tip < depth {
+		return nil, nil
+	}
+	var blocks []ancientBlock
+	for h := frozen; h <= tip-depth && len(blocks) < freezeBatch; h++ {
+		hash, err := bc.getHash(h)
+		if err != nil {
+			return nil, fmt.Errorf("block hash %d: %w", h, err)
+		}
+		data, err := bc.db.Get(hash)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", h, err)
+		}
+		b, err := block.Deserialize(data)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", h, err)
+		}
+		receipts, err := bc.db.Get(receiptKey(hash))
+		if err != nil && err != store.NotExist {
+			return nil, fmt.Errorf("receipts %d: %w", h, err)
+		}
+		blocks = append(blocks, ancientBlock{Block: b, body: data, receipts: receipts})
+	}
+	return blocks, nil
+}
+
+// appendAncients writes blocks to the freezer and syncs it.
+func (bc *Blockchain) appendAncients(blocks []ancientBlock) error {
+	for _, b := range blocks {
+		if err := bc.cdb.AppendAncient(b.Height, b.Hash, nil, b.body, b.receipts, nil); err != nil {
+			return fmt.Errorf("freeze block %d: %w", b.Height, err)
+		}
+	}
+	return bc.cdb.Sync()
+}
+
+// dropFrozen removes blocks with their receipts and blooms from the
+// database, leaves the height they have in the freezer under their hash and
+// the hashes of their transactions, and records that the first frozen blocks
+// are in the freezer.
+func (bc *Blockchain) dropFrozen(blocks []*block.Block, frozen uint64) error {
+	tx := bc.db.NewTransaction()
+	defer tx.Cancel()
+	for _, b := range blocks {
+		ref := miscellaneous.E64func(b.Height)
+		if err := tx.Set(b.Hash, ref); err != nil {
+			return err
+		}
+		if err := deleteReceipts(tx, b.Hash); err != nil {
+			return err
+		}
+		for _, t := range b.Transactions {
+			hash := t.Hash()
+			// a transaction hash a later block took over keeps its lookup
+			v, err := tx.Get(hash)
+			if err == store.NotExist {
+				continue
+			} else if err != nil {
+				return err
+			}
+			var txindex TxIndex
+			if json.Unmarshal(v, &txindex) != nil || txindex.Height != b.Height {
+				continue
+			}
+			if err := tx.Set(hash, ref); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Set(FrozenKey, miscellaneous.E64func(frozen)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// readBlock returns the encoded block hash from the database or the freezer.
+func (bc *Blockchain) readBlock(hash []byte) ([]byte, error) {
+	return ReadBlock(bc.db, bc.cdb, hash)
+}
+
+// ReadBlock returns the encoded block hash from db or, if it was moved to the
+// freezer, from ancients. ancients may be nil if no block of db is frozen.
+func ReadBlock(db store.DB, ancients AncientReader, hash []byte) ([]byte, error) {
+	data, err := db.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := frozenRef(data)
+	if !ok {
+		return data, nil
+	}
+	if err := checkFrozen(ancients, hash, h); err != nil {
+		return nil, err
+	}
+	return ancients.Ancient(bgdb.FreezerBodiesTable, h)
+}
+
+// readReceipts returns the encoded receipts of the block b from db or, if
+// they were moved to the freezer, from ancients. It returns store.NotExist
+// if none are kept for b.
+func readReceipts(db store.DB, ancients AncientReader, b *block.Block) ([]byte, error) {
+	data, err := db.Get(receiptKey(b.Hash))
+	if err != store.NotExist {
+		return data, err
+	}
+	frozen, err := FrozenHeight(db)
+	if err != nil {
+		return nil, err
+	}
+	if b.Height >= frozen || checkFrozen(ancients, b.Hash, b.Height) != nil {
+		return nil, store.NotExist
+	}
+	data, err = ancients.Ancient(bgdb.FreezerReceiptTable, b.Height)
+	if err == nil && len(data) == 0 {
+		return nil, store.NotExist
+	}
+	return data, err
+}
+
+// checkFrozen checks that the block at height h of the freezer ancients has
+// the hash.
+func checkFrozen(ancients AncientReader, hash []byte, h uint64) error {
+	if ancients == nil {
+		return fmt.Errorf("block %x is in the freezer", hash)
+	}
+	frozen, err := ancients.Ancient(bgdb.FreezerHashTable, h)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(frozen, hash) {
+		return fmt.Errorf("%x is no block of the freezer", hash)
+	}
+	return nil
+}
+
+// frozenTxIndex returns the position of the transaction hash in the frozen
+// block at height h.
+func (bc *Blockchain) frozenTxIndex(hash []byte, h uint64) (TxIndex, error) {
+	data, err := bc.cdb.Ancient(bgdb.FreezerBodiesTable, h)
+	if err != nil {
+		return TxIndex{}, err
+	}
+	b, err := block.Deserialize(data)
+	if err != nil {
+		return TxIndex{}, err
+	}
+	for i, tx := range b.Transactions {
+		if bytes.Equal(tx.Hash(), hash) {
+			return TxIndex{Height: h, Index: uint64(i)}, nil
+		}
+	}
+	return TxIndex{}, fmt.Errorf("transaction %x is not in block %d", hash, h)
+}
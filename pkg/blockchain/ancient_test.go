@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	cfg := *chainCfg
+	cfg.Freezer = &FreezerConfig{Dir: t.TempDir()}
+
+	// New rolls the tip back to 3, blocks 0 and 1 are two blocks below it
+	bc, err := New(db, &cfg)
+	assert.NoError(err)
+	b1, err := bc.GetBlockByHeight(1)
+	assert.NoError(err)
+	n, err := bc.freezeBatch(2)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	n, err = bc.freezeBatch(2)
+	assert.NoError(err)
+	assert.Zero(n)
+
+	// the block and its transactions are found by their height in the
+	// freezer, the receipts and the bloom are gone from the database
+	for _, hash := range [][]byte{b1.Hash, b1.Transactions[0].Hash()} {
+		v, err := db.Get(hash)
+		assert.NoError(err)
+		h, ok := frozenRef(v)
+		assert.True(ok)
+		assert.Equal(uint64(1), h)
+	}
+	_, err = db.Get(receiptKey(b1.Hash))
+	assert.Equal(store.NotExist, err)
+	_, err = db.Get(bloomKey(b1.Hash))
+	assert.Equal(store.NotExist, err)
+	frozen, err := FrozenHeight(db)
+	assert.NoError(err)
+	assert.Equal(uint64(2), frozen)
+
+	check := func(bc *Blockchain) {
+		b, err := bc.GetBlockByHeight(1)
+		assert.NoError(err)
+		assert.Equal(b1.Hash, b.Hash)
+		b, err = bc.GetBlockByHash(b1.Hash)
+		assert.NoError(err)
+		assert.Equal(uint64(1), b.Height)
+		_, err = bc.GetBlockByHash(block.GenesisHash)
+		assert.NoError(err)
+		tx, err := bc.GetTransactionByHash(b1.Transactions[0].Hash())
+		assert.NoError(err)
+		assert.Equal(uint64(1), tx.BlockNum)
+		r, err := bc.GetReceipt(tx.Hash())
+		assert.NoError(err)
+		assert.Equal(tx.Hash(), r.TxHash)
+		_, err = bc.GetBlockBloom(b1.Hash)
+		assert.NoError(err)
+		// a transaction hash is no block
+		_, err = bc.GetBlockByHash(tx.Hash())
+		assert.Error(err)
+	}
+	check(bc)
+	// the frozen blocks are checked too, they can not be without the freezer
+	var checked []uint64
+	problems, err := Check(db, bc.cdb, func(h uint64) { checked = append(checked, h) })
+	assert.NoError(err)
+	assert.Empty(problems)
+	assert.Equal([]uint64{0, 1, 2, 3}, checked)
+	_, err = Check(db, nil, nil)
+	assert.Error(err)
+	assert.Error(bc.DeleteBlock(1))
+
+	// the indexes of the freezer are kept in the database across restarts
+	assert.NoError(bc.cdb.Close())
+	bc, err = New(db, &cfg)
+	assert.NoError(err)
+	check(bc)
+	assert.NoError(bc.cdb.Close())
+}
@@ -0,0 +1,144 @@
+// Package archive reads and writes portable block archives: a header with
+// the format version and the genesis hash, followed by length-prefixed blocks
+// in the CBOR encoding of block.Block.Serialize.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"metechain/pkg/block"
+)
+
+// Version is the archive format version written by this package.
+const Version = 1
+
+// maxRecord bounds the length prefix so a corrupt archive can not make the
+// reader allocate without limit.
+const maxRecord = 64 << 20
+
+var magic = []byte("metechain-blocks")
+
+var (
+	ErrNotArchive = errors.New("archive: not a block archive")
+	ErrVersion    = errors.New("archive: unsupported version")
+)
+
+// Header starts every archive.
+type Header struct {
+	Version     uint32
+	GenesisHash []byte
+}
+
+// Writer appends blocks to an archive.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter writes the archive header for genesis to w.
+func NewWriter(w io.Writer, genesis []byte) (*Writer, error) {
+	aw := &Writer{bufio.NewWriter(w)}
+	if _, err := aw.w.Write(magic); err != nil {
+		return nil, err
+	}
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], Version)
+	if _, err := aw.w.Write(v[:]); err != nil {
+		return nil, err
+	}
+	if err := aw.writeRecord(genesis); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// Write appends b to the archive.
+func (aw *Writer) Write(b *block.Block) error {
+	data, err := b.Serialize()
+	if err != nil {
+		return err
+	}
+	return aw.writeRecord(data)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (aw *Writer) Flush() error {
+	return aw.w.Flush()
+}
+
+func (aw *Writer) writeRecord(data []byte) error {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	if _, err := aw.w.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := aw.w.Write(data)
+	return err
+}
+
+// Reader reads blocks from an archive.
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+}
+
+// NewReader reads and checks the archive header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	ar := &Reader{r: bufio.NewReader(r)}
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(ar.r, m); err != nil || !bytes.Equal(m, magic) {
+		return nil, ErrNotArchive
+	}
+	var v [4]byte
+	if _, err := io.ReadFull(ar.r, v[:]); err != nil {
+		return nil, ErrNotArchive
+	}
+	ar.header.Version = binary.BigEndian.Uint32(v[:])
+	if ar.header.Version != Version {
+		return nil, fmt.Errorf("%w %d", ErrVersion, ar.header.Version)
+	}
+	genesis, err := ar.readRecord()
+	if err != nil {
+		return nil, fmt.Errorf("archive: genesis hash: %w", err)
+	}
+	ar.header.GenesisHash = genesis
+	return ar, nil
+}
+
+// Header returns the archive header.
+func (ar *Reader) Header() Header {
+	return ar.header
+}
+
+// Next returns the next block, or io.EOF after the last one.
+func (ar *Reader) Next() (*block.Block, error) {
+	data, err := ar.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	return block.Deserialize(data)
+}
+
+func (ar *Reader) readRecord() ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(ar.r, n[:]); err != nil {
+		// a clean end of the archive is io.EOF, a torn length io.ErrUnexpectedEOF
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > maxRecord {
+		return nil, fmt.Errorf("archive: record of %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(ar.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+
+	"metechain/pkg/block"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBlock(h uint64) *block.Block {
+	miner := common.HexToAddress("0x01")
+	return &block.Block{
+		Height:           h,
+		PrevHash:         []byte{byte(h - 1)},
+		Hash:             []byte{byte(h)},
+		Root:             []byte{},
+		SnapRoot:         []byte{},
+		Miner:            &miner,
+		GlobalDifficulty: big.NewInt(1),
+		GasUsed:          big.NewInt(0),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	aw, err := NewWriter(&buf, []byte("genesis"))
+	assert.NoError(err)
+	for h := uint64(1); h <= 3; h++ {
+		assert.NoError(aw.Write(testBlock(h)))
+	}
+	assert.NoError(aw.Flush())
+
+	ar, err := NewReader(&buf)
+	assert.NoError(err)
+	assert.Equal(Header{Version: Version, GenesisHash: []byte("genesis")}, ar.Header())
+	for h := uint64(1); h <= 3; h++ {
+		b, err := ar.Next()
+		assert.NoError(err)
+		assert.Equal(h, b.Height)
+		assert.Equal([]byte{byte(h)}, b.Hash)
+	}
+	_, err = ar.Next()
+	assert.Equal(io.EOF, err)
+}
+
+func TestReaderErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewReader(bytes.NewReader([]byte("not an archive at all")))
+	assert.Equal(ErrNotArchive, err)
+
+	var buf bytes.Buffer
+	aw, err := NewWriter(&buf, []byte("genesis"))
+	assert.NoError(err)
+	assert.NoError(aw.Write(testBlock(1)))
+	assert.NoError(aw.Flush())
+
+	data := buf.Bytes()
+	ar, err := NewReader(bytes.NewReader(data[:len(data)-1]))
+	assert.NoError(err)
+	_, err = ar.Next()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+
+	data[len(magic)+3] = Version + 1
+	_, err = NewReader(bytes.NewReader(data))
+	assert.ErrorIs(err, ErrVersion)
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"metechain/pkg/block"
+	"metechain/pkg/blockchain"
+	"metechain/pkg/consensus"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var ErrGenesis = errors.New("archive: genesis hash does not match the chain")
+
+// GenesisHash identifies the chain of bc. The Hash field of every genesis
+// block is block.GenesisHash, so the hash is taken over its encoding instead.
+func GenesisHash(bc *blockchain.Blockchain) ([]byte, error) {
+	hash, err := bc.GetHash(blockchain.InitHeight)
+	if err != nil {
+		return nil, err
+	}
+	b, err := bc.GetBlockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	h := sha3.Sum256(data)
+	return h[:], nil
+}
+
+// Export writes the main chain blocks from height from to height to,
+// inclusive, as an archive to w.
+func Export(bc *blockchain.Blockchain, w io.Writer, from, to uint64) (int, error) {
+	if from < 1 || from > to {
+		return 0, fmt.Errorf("archive: bad height range %d-%d", from, to)
+	}
+	genesis, err := GenesisHash(bc)
+	if err != nil {
+		return 0, err
+	}
+	aw, err := NewWriter(w, genesis)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for h := from; h <= to; h++ {
+		b, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return n, fmt.Errorf("archive: block %d: %w", h, err)
+		}
+		if err := aw.Write(b); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, aw.Flush()
+}
+
+// Import adds the blocks of the archive in r to bc and returns how many were
+// added. Blocks already on the main chain are skipped. Every block goes
+// through consensus.BlockChain.ProcessBlock, unless trusted is set, in which
+// case it is added with AddBlock without further validation.
+func Import(bc *blockchain.Blockchain, r io.Reader, trusted bool, progress func(*block.Block)) (int, error) {
+	ar, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	genesis, err := GenesisHash(bc)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(genesis, ar.Header().GenesisHash) {
+		return 0, ErrGenesis
+	}
+
+	cbc := consensus.New(bc)
+	var n int
+	for {
+		b, err := ar.Next()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if onMainChain(bc, b) {
+			continue
+		}
+		if trusted {
+			if err := bc.AddBlock(b); err != nil {
+				return n, fmt.Errorf("archive: block %d %x: %w", b.Height, b.Hash, err)
+			}
+		} else {
+			cbc.ProcessBlock(b, b.GlobalDifficulty)
+			if !onMainChain(bc, b) {
+				return n, fmt.Errorf("archive: block %d %x rejected", b.Height, b.Hash)
+			}
+		}
+		n++
+		if progress != nil {
+			progress(b)
+		}
+	}
+}
+
+// onMainChain reports whether b is on the main chain of bc. The height index
+// can hold entries above the tip, New rolls the tip back without removing them.
+func onMainChain(bc *blockchain.Blockchain, b *block.Block) bool {
+	tip, err := bc.GetMaxBlockHeight()
+	if err != nil || b.Height > tip {
+		return false
+	}
+	main, err := bc.IsMainChainBlock(b.Hash)
+	return err == nil && main
+}
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/blockchain"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/mem"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "archive")
+	if err != nil {
+		panic(err)
+	}
+	cfg := logger.DefaultConfig()
+	cfg.FileName = filepath.Join(dir, "debug.log")
+	if err := logger.InitLogger(cfg); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var (
+	chainOnce sync.Once
+	chainDB   store.DB
+	chainCfg  *blockchain.ChainConfig
+)
+
+// testChains returns a chain with four blocks on top of genesis and a copy
+// of it cut back to genesis. All chains share one genesis block, which can
+// only be created once per process.
+func testChains(t *testing.T) (*blockchain.Blockchain, *blockchain.Blockchain) {
+	assert := assert.New(t)
+	chainOnce.Do(func() {
+		miner := common.HexToAddress("0x01")
+		chainCfg = &blockchain.ChainConfig{ChainId: 1, GasLimit: blockchain.MINGASLIMIT, GasPrice: 1, Miner: &miner, Version2Height: new(uint64), Version3Height: new(uint64)}
+		chainDB = mem.New()
+		assert.NoError(chainDB.Set(blockchain.SnapRootKey, types.EmptyRootHash.Bytes()))
+		bc, err := blockchain.New(chainDB, chainCfg)
+		assert.NoError(err)
+		for i := 0; i < 4; i++ {
+			b, err := bc.NewBlock(nil, &miner)
+			assert.NoError(err)
+			assert.NoError(bc.ExecuteBlock(b))
+			assert.NoError(b.SetHash())
+			assert.NoError(bc.AddBlock(b))
+		}
+	})
+
+	// New rolls the tip of an existing chain back by one block
+	src, err := blockchain.New(copyDB(t, chainDB), chainCfg)
+	assert.NoError(err)
+	dst, err := blockchain.New(copyDB(t, chainDB), chainCfg)
+	assert.NoError(err)
+	assert.NoError(dst.DeleteBlock(1))
+	h, err := dst.GetMaxBlockHeight()
+	assert.NoError(err)
+	assert.Equal(uint64(0), h)
+	return src, dst
+}
+
+func copyDB(t *testing.T, src store.DB) store.DB {
+	db := mem.New()
+	itr := src.NewIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		assert.NoError(t, db.Set(itr.Key(), itr.Value()))
+	}
+	return db
+}
+
+func TestExportImport(t *testing.T) {
+	assert := assert.New(t)
+	src, dst := testChains(t)
+
+	var buf bytes.Buffer
+	n, err := Export(src, &buf, 1, 3)
+	assert.NoError(err)
+	assert.Equal(3, n)
+	data := buf.Bytes()
+
+	// the blocks are not mined, full validation takes the first one only
+	n, err = Import(dst, bytes.NewReader(data), false, nil)
+	assert.Error(err)
+	assert.Equal(1, n)
+
+	var heights []uint64
+	n, err = Import(dst, bytes.NewReader(data), true, func(b *block.Block) { heights = append(heights, b.Height) })
+	assert.NoError(err)
+	assert.Equal(2, n)
+	assert.Equal([]uint64{2, 3}, heights)
+
+	want, err := src.Tip()
+	assert.NoError(err)
+	got, err := dst.Tip()
+	assert.NoError(err)
+	assert.Equal(want.Hash, got.Hash)
+
+	// everything is on the main chain already
+	n, err = Import(dst, bytes.NewReader(data), true, nil)
+	assert.NoError(err)
+	assert.Equal(0, n)
+}
+
+func TestImportOtherGenesis(t *testing.T) {
+	assert := assert.New(t)
+	src, _ := testChains(t)
+
+	var buf bytes.Buffer
+	aw, err := NewWriter(&buf, []byte("some other chain"))
+	assert.NoError(err)
+	assert.NoError(aw.Flush())
+	_, err = Import(src, &buf, true, nil)
+	assert.Equal(ErrGenesis, err)
+}
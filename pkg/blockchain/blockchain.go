@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/event"
 	"go.uber.org/zap"
 )
 
@@ -46,6 +47,16 @@ type ChainConfig struct {
 	GasLimit  uint64 `yaml:"gaslimit"`
 	GasPrice  uint64 `yaml:"gasprice"`
 	Miner     *common.Address
+	// Prune prunes old state, nil keeps all of it
+	Prune *PruneConfig `yaml:"prune"`
+	// Freezer moves old blocks out of the database, nil keeps them
+	Freezer *FreezerConfig `yaml:"freezer"`
+	// Version2Height is the height from which blocks are block.Version2 and
+	// commit to the state and receipts, nil keeps them at block.Version1
+	Version2Height *uint64 `yaml:"version2height"`
+	// Version3Height is the height from which blocks are block.Version3 and
+	// meter gas, nil keeps them at the version before
+	Version3Height *uint64 `yaml:"version3height"`
 }
 
 var (
@@ -56,10 +67,20 @@ var (
 // New create blockchain object
 func New(bgs store.DB, cfg *ChainConfig) (*Blockchain, error) {
 	//bgs := bg.New(db)
-	cdb := bgdb.NewBadgerDatabase(bgs)
-	sdb := state.NewDatabase(cdb)
-
-	bc := &Blockchain{db: bgs, ChainCfg: cfg}
+	bc := &Blockchain{
+		db:              bgs,
+		cdb:             bgdb.NewBadgerDatabase(bgs),
+		ChainCfg:        cfg,
+		bloomBitsBlocks: BloomBitsBlocks,
+		bloomConfirms:   BloomConfirms,
+		quit:            make(chan struct{}),
+	}
+	if cfg.Freezer != nil {
+		if err := bc.openFreezer(cfg.Freezer); err != nil {
+			return nil, fmt.Errorf("openFreezer:%w", err)
+		}
+	}
+	sdb := state.NewDatabase(bc.cdb)
 
 	if err := bc.fallbackOneBleck(); err != nil {
 		return nil, fmt.Errorf("fallbackOneBleck:%w", err)
@@ -81,6 +102,11 @@ func New(bgs store.DB, cfg *ChainConfig) (*Blockchain, error) {
 
 	bc.evm = evm.NewEvm(bc.sdb, cfg.ChainId, cfg.GasLimit, new(big.Int).SetUint64(cfg.GasPrice))
 
+	if err := bc.indexAddrTxs(); err != nil {
+		return nil, fmt.Errorf("indexAddrTxs:%w", err)
+	}
+	bc.startBloomIndex()
+
 	// 初始化创世块并添加
 	a, err := bc.GetMaxBlockHeight()
 	if err != nil {
@@ -143,12 +169,46 @@ func (bc *Blockchain) fallbackBlock(h uint64) error {
 
 }
 
+// Close stops the runs in the background and waits for them, then closes the
+// database.
 func (bc *Blockchain) Close() {
+	bc.mu.Lock()
+	if !bc.closing() {
+		close(bc.quit)
+	}
+	bc.mu.Unlock()
+	// the runs take the lock, they are waited for without it
+	bc.wg.Wait()
+
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
+	bc.cdb.Close()
 	bc.db.Close()
 }
 
+// closing reports whether Close was called
+func (bc *Blockchain) closing() bool {
+	select {
+	case <-bc.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// background runs fn in a goroutine Close waits for, unless the chain is
+// closing. The caller holds bc.mu, or has not shared bc yet.
+func (bc *Blockchain) background(fn func()) {
+	if bc.closing() {
+		return
+	}
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+		fn()
+	}()
+}
+
 func (bc *Blockchain) SyncLock() {
 	bc.mu.Lock()
 }
@@ -172,7 +232,7 @@ func (bc *Blockchain) Tip() (*block.Block, error) {
 		return nil, err
 	}
 
-	blockData, err := bc.db.Get(hash)
+	blockData, err := bc.readBlock(hash)
 	if err != nil {
 		return nil, err
 	}
@@ -297,7 +357,7 @@ func (bc *Blockchain) getBlockByHeight(height uint64) (*block.Block, error) {
 		return nil, err
 	}
 	//Then get the block through hash
-	blockData, err := bc.db.Get(hash)
+	blockData, err := bc.readBlock(hash)
 	if err != nil {
 		return nil, err
 	}
@@ -332,7 +392,7 @@ func (bc *Blockchain) GetBlockByHash(hash []byte) (*block.Block, error) {
 
 // getBlockByHeight get the block corresponding to the block height
 func (bc *Blockchain) getBlockByHash(hash []byte) (*block.Block, error) {
-	blockData, err := bc.db.Get(hash)
+	blockData, err := bc.readBlock(hash)
 	if err != nil {
 		return nil, err
 	}
@@ -349,15 +409,8 @@ func (bc *Blockchain) GetTransactionByHash(hash []byte) (*transaction.FinishedTr
 
 // getTransactionByHash get the transaction corresponding to the transaction hash
 func (bc *Blockchain) getTransactionByHash(hash []byte) (*transaction.FinishedTransaction, error) {
-	Hi, err := bc.db.Get(hash)
-	if err != nil {
-		//logger.Error("failed to get hash", zap.Error(err))
-		return nil, err
-	}
-	var txindex TxIndex
-	err = json.Unmarshal(Hi, &txindex)
+	txindex, err := bc.getTxIndex(hash)
 	if err != nil {
-		logger.Error("Failed to unmarshal bytes", zap.Error(err), zap.String("hash", hex.EncodeToString(hash)), zap.Any("tx", Hi))
 		return nil, err
 	}
 	b, err := bc.getBlockByHeight(txindex.Height)
@@ -372,6 +425,25 @@ func (bc *Blockchain) getTransactionByHash(hash []byte) (*transaction.FinishedTr
 	return *tx, nil
 }
 
+// getTxIndex get the height and position of the transaction hash
+func (bc *Blockchain) getTxIndex(hash []byte) (TxIndex, error) {
+	var txindex TxIndex
+	Hi, err := bc.db.Get(hash)
+	if err != nil {
+		//logger.Error("failed to get hash", zap.Error(err))
+		return txindex, err
+	}
+	// the lookups of frozen transactions keep only the height
+	if h, ok := frozenRef(Hi); ok {
+		return bc.frozenTxIndex(hash, h)
+	}
+	if err = json.Unmarshal(Hi, &txindex); err != nil {
+		logger.Error("Failed to unmarshal bytes", zap.Error(err), zap.String("hash", hex.EncodeToString(hash)), zap.Any("tx", Hi))
+		return txindex, err
+	}
+	return txindex, nil
+}
+
 // NewBlock create a new block for the blockchain
 func (bc *Blockchain) NewBlock(txs []*transaction.SignedTransaction, minaddr *common.Address) (*block.Block, error) {
 	//logger.Info("start to new block")
@@ -395,6 +467,19 @@ func (bc *Blockchain) NewBlock(txs []*transaction.SignedTransaction, minaddr *co
 		prevHash = block.GenesisHash
 	}
 
+	parent, err := bc.GetBlockByHash(prevHash)
+	if err != nil {
+		logger.Error("failed to get parent block", zap.Error(err), zap.Uint64("previous height", prevHeight))
+		return nil, err
+	}
+	version := bc.blockVersion(height)
+	// older blocks do not meter gas and take all of txs
+	var baseFee *big.Int
+	if version >= block.Version3 {
+		baseFee = CalcBaseFee(parent)
+		txs = packTransactions(txs, bc.ChainCfg.GasLimit, baseFee)
+	}
+
 	// Currency distribution
 	txs = distr(txs, minaddr, height)
 
@@ -432,7 +517,7 @@ func (bc *Blockchain) NewBlock(txs []*transaction.SignedTransaction, minaddr *co
 		PrevHash:         prevHash,
 		Transactions:     ftxs,
 		Root:             root,
-		Version:          1,
+		Version:          version,
 		Timestamp:        timestamp,
 		UsedTime:         0,
 		Miner:            minaddr,
@@ -441,6 +526,7 @@ func (bc *Blockchain) NewBlock(txs []*transaction.SignedTransaction, minaddr *co
 		Nonce:            1,
 		GasLimit:         bc.ChainCfg.GasLimit,
 		GasUsed:          gasUsed,
+		BaseFee:          baseFee,
 	}
 
 	return block, nil
@@ -610,6 +696,10 @@ func (bc *Blockchain) AddLoseBlock1(blk *block.Block) error {
 		logger.Error("Failed to set block", zap.Error(err))
 		return err
 	}
+	if err := setBlockBloom(DBTransaction, blk); err != nil {
+		logger.Error("Failed to set block bloom", zap.Error(err))
+		return err
+	}
 
 	if err := DBTransaction.Commit(); err != nil {
 		logger.Error("commit db", zap.Error(err), zap.Uint64("block number", blk.Height))
@@ -715,8 +805,21 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 	}
 
 	var blockGasU = new(big.Int)
+	gasPool := block.GasLimit
+	receipts := make([]*transaction.Receipt, 0, len(block.Transactions))
 	for index, tx := range block.Transactions {
 		logger.Info("block :", zap.String("hash", tx.HashToString()), zap.String("tx", tx.String()))
+		if gasMetered(block) {
+			receipt, err := bc.applyTransaction(DBTransaction, block, tx, index, &gasPool)
+			if err != nil {
+				logger.Error("Failed to apply transaction", zap.Error(err), zap.String("hash", tx.HashToString()))
+				REVERT = err
+				return err
+			}
+			receipts = append(receipts, receipt)
+			blockGasU.Add(blockGasU, receipt.GasUsed)
+			continue
+		}
 		if tx.Transaction.IsCoinBaseTransaction() {
 			txHash := tx.Hash()
 			if err := setTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), txHash, height, uint64(index)); err != nil {
@@ -727,6 +830,7 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 			}
 
 			tx.GasUsed = new(big.Int).Mul(tx.GasLimit, tx.GasPrice)
+			receipts = append(receipts, transferReceipt(tx))
 			blockGasU.Add(blockGasU, tx.GasUsed)
 			if err := setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
 				logger.Error("Failed to set Minerfee", zap.Error(err), zap.String("from address", block.Miner.String()), zap.String("fee", tx.GasUsed.String()))
@@ -748,7 +852,7 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 				return err
 			}
 
-			gasLeft, err := bc.handleContractTransaction(block, DBTransaction, tx, index)
+			gasLeft, receipt, err := bc.handleContractTransaction(block, DBTransaction, tx, index, contractGasLimit(tx), tx.GasPrice)
 			if err != nil {
 				logger.Error("Failed to HandleContractTransaction", zap.Error(err), zap.String("hash", transaction.HashToString(txHash)))
 				REVERT = err
@@ -764,6 +868,14 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 			}
 
 			tx.GasUsed = new(big.Int).Sub(big.NewInt(int64(evmcfg.GasLimit)), gasLeft)
+			receipt.GasUsed.Set(tx.GasUsed)
+			receipts = append(receipts, receipt)
+			// blocks before block.Version2 keep the gas used they were
+			// always stored with, without the contract transactions. Later
+			// ones commit to the gas used of executing them, which counts them.
+			if commitsRoots(block) {
+				blockGasU.Add(blockGasU, tx.GasUsed)
+			}
 
 			if err := setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
 				logger.Error("Failed to set Minerfee", zap.Error(err), zap.String("hash", transaction.HashToString(txHash)), zap.String("gasUsed", tx.GasUsed.String()))
@@ -808,6 +920,7 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 			// tx.GasUsed = tx.Transaction.GasLimit * tx.Transaction.GasPrice
 			// blockGasU += tx.GasUsed
 			tx.GasUsed = new(big.Int).Mul(tx.GasLimit, tx.GasPrice)
+			receipts = append(receipts, transferReceipt(tx))
 			blockGasU.Add(blockGasU, tx.GasUsed)
 
 			if err := setMinerFee(bc, *block.Miner, tx.GasUsed); err != nil {
@@ -835,6 +948,13 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 	}
 	logger.Info("sub factcommit", zap.Float64("second", time.Since(t0).Seconds()))
 
+	receiptsRoot, err := deriveReceipts(receipts)
+	if err != nil {
+		logger.Error("Failed to derive receipts", zap.Error(err))
+		REVERT = err
+		return err
+	}
+
 	if block.Height == 0 {
 		block.SnapRoot = comHash.Bytes()
 	} else {
@@ -848,11 +968,9 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 		logger.Info("AddBlock", zap.String("b.SnapRootkey", hex.EncodeToString(block.SnapRoot)))
 		logger.Info("AddBlock", zap.String("newSnapRootkey", hex.EncodeToString(comHash.Bytes())))
 
-		if bytes.Equal(oldSnapRootkey, block.SnapRoot) {
-			block.SnapRoot = comHash.Bytes()
-		} else if !bytes.Equal(comHash.Bytes(), block.SnapRoot) {
-			REVERT = fmt.Errorf("SnapRoot not equal")
-			return REVERT
+		if err := verifyRoots(block, oldSnapRootkey, comHash.Bytes(), receiptsRoot, blockGasU); err != nil {
+			REVERT = err
+			return err
 		}
 	}
 
@@ -871,6 +989,12 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 		return err
 	}
 
+	if err := setReceipts(DBTransaction, block, receipts); err != nil {
+		logger.Error("Failed to set receipts", zap.Error(err))
+		REVERT = err
+		return err
+	}
+
 	if err := DBTransaction.Set(append(SnapRootPrefix, miscellaneous.E64func(height)...), comHash.Bytes()); err != nil {
 		logger.Error("Failed to set height and hash", zap.Error(err))
 		REVERT = err
@@ -884,6 +1008,9 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 		REVERT = err
 		return err
 	}
+	bc.maybePrune(height)
+	bc.maybeFreeze(height)
+	bc.maybeIndexBloom(height)
 
 	/* 	time.Sleep(200 * time.Millisecond) */
 	return nil
@@ -892,9 +1019,23 @@ func (bc *Blockchain) AddBlock(block *block.Block) error {
 //ReorganizeChain reorganizes the block chain by disconnecting the
 //nodes in the main list and connecting the nodes in the branch  list
 //len(hashs) means main list need recover block lenght include tip  block,
-
+//the subscribers get the blocks disconnected and connected once it is done
 func (bc *Blockchain) ReorganizeChain(hashs [][]byte, delHeight uint64) error {
+	ev, err := bc.reorganizeChain(hashs, delHeight)
+	if err != nil {
+		return err
+	}
+	bc.reorgFeed.Send(ev)
+	return nil
+}
+
+// SubscribeReorg subscribes ch to the reorganizations of the main chain
+func (bc *Blockchain) SubscribeReorg(ch chan<- block.ReorgEvent) event.Subscription {
+	return bc.reorgFeed.Subscribe(ch)
+}
 
+func (bc *Blockchain) reorganizeChain(hashs [][]byte, delHeight uint64) (block.ReorgEvent, error) {
+	var ev block.ReorgEvent
 	var errf int
 
 	bc.mu.Lock()
@@ -906,7 +1047,7 @@ func (bc *Blockchain) ReorganizeChain(hashs [][]byte, delHeight uint64) error {
 	root, err := getSnapRoot(bc.db)
 	if err != nil {
 		logger.Error("getSnapRoot   err", zap.Error(err))
-		return err
+		return ev, err
 	}
 
 	defer func() {
@@ -921,13 +1062,29 @@ func (bc *Blockchain) ReorganizeChain(hashs [][]byte, delHeight uint64) error {
 		}
 	}()
 
+	// the blocks of the main chain from delHeight on are disconnected
+	dbHeight, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return ev, err
+	}
+	if err := bc.checkRewind(delHeight, dbHeight); err != nil {
+		return ev, err
+	}
+	for h := delHeight; h <= dbHeight; h++ {
+		b, err := bc.getBlockByHeight(h)
+		if err != nil {
+			return ev, err
+		}
+		ev.Dropped = append(ev.Dropped, b)
+	}
+
 	//len(hashs) number of blocks to be rolled back
 	num := len(hashs)
 	err = bc.DeleteTempBlockTest(delHeight, db)
 	if err != nil {
 		errf = -1
 		logger.Error("DeleteTempBlock   err", zap.Error(err))
-		return err
+		return ev, err
 	}
 
 	for num > 0 {
@@ -935,12 +1092,16 @@ func (bc *Blockchain) ReorganizeChain(hashs [][]byte, delHeight uint64) error {
 		block, err := bc.getBlockByHash(hashs[num])
 		if err != nil {
 			errf = -1
-			return err
+			return ev, err
 		}
 
 		if err := bc.checkBlockRegular(block, bc.db, db); err != nil {
 			errf = -1
-			return err
+			return ev, err
+		}
+		if err := bc.checkNonces(block); err != nil {
+			errf = -1
+			return ev, err
 		}
 		// if err := difficultDetection(block, bc.db, db); err != nil {
 		// 	errf = -1
@@ -951,17 +1112,18 @@ func (bc *Blockchain) ReorganizeChain(hashs [][]byte, delHeight uint64) error {
 		if err != nil {
 			errf = -1
 			logger.Error("ReorganizeChain.AddTempBlock err", zap.Error(err))
-			return err
+			return ev, err
 		}
+		ev.Added = append(ev.Added, block)
 	}
 
 	if err := db.Commit(); err != nil {
 		errf = -1
 		logger.Error("commmit  err", zap.Error(err))
-		return err
+		return ev, err
 	}
 
-	return nil
+	return ev, nil
 }
 
 func updateDifficulty(height uint64, coinbaseAddr *common.Address, tx store.Transaction) (*big.Int, error) {
@@ -1021,6 +1183,16 @@ func (bc *Blockchain) DeleteBlock(height uint64) error {
 		logger.SugarLogger.Infof("Wrong height to delete,[%v] should <= current height[%v]", height, dbHeight)
 		return nil
 	}
+	frozen, err := FrozenHeight(bc.db)
+	if err != nil {
+		return err
+	}
+	if height < frozen {
+		return fmt.Errorf("block %d is in the freezer, the first block that can be deleted is %d", height, frozen)
+	}
+	if err := bc.checkRewind(height, dbHeight); err != nil {
+		return err
+	}
 
 	for dH := dbHeight; dH >= height; dH-- {
 
@@ -1030,19 +1202,25 @@ func (bc *Blockchain) DeleteBlock(height uint64) error {
 		block, err := bc.getBlockByHeight(dH)
 		if err != nil {
 			logger.Error("failed to get block", zap.Error(err))
-			return err
+			DBTransaction.Cancel()
+			// a block that can not be read is dropped from the height index
+			// only, so a damaged chain can still be cut back past it
+			if err := dropBrokenBlock(bc.db, dH); err != nil {
+				return err
+			}
+			continue
 		}
 
 		for i, tx := range block.Transactions {
 			if tx.IsCoinBaseTransaction() {
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
 				}
 			} else if tx.IsEvmContractTransaction() {
 				//新链无绑定地址接口，暂忽略setBindingKey
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
@@ -1059,13 +1237,13 @@ func (bc *Blockchain) DeleteBlock(height uint64) error {
 					}
 				}
 
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.From.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
 				}
 
-				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, uint64(i)); err != nil {
+				if err := deleteTxbyaddrKV(DBTransaction, tx.Transaction.To.Bytes(), *tx, dH, uint64(i)); err != nil {
 					logger.Error("Failed to del transaction", zap.Error(err), zap.String("from address", tx.Transaction.From.String()),
 						zap.String("to address", tx.Transaction.To.String()), zap.String("amount", tx.Amount.String()))
 					return err
@@ -1095,15 +1273,18 @@ func (bc *Blockchain) DeleteBlock(height uint64) error {
 			logger.Error("Failed to Del block", zap.Error(err))
 			return err
 		}
+		if err = deleteReceipts(DBTransaction, hash); err != nil {
+			logger.Error("Failed to Del receipts", zap.Error(err))
+			return err
+		}
 
 		//previous set block into into evm
-		previousbBlock, err := bc.getBlockByHeight(dH - 1)
-		if err != nil {
+		if previousbBlock, err := bc.getBlockByHeight(dH - 1); err != nil {
 			logger.Error("failed to get block", zap.Error(err))
-			return err
+		} else {
+			previousMiner := *previousbBlock.Miner
+			bc.evm.SetBlockInfo(previousbBlock.Height, previousbBlock.Timestamp, previousMiner, previousbBlock.GlobalDifficulty)
 		}
-		previousMiner := *previousbBlock.Miner
-		bc.evm.SetBlockInfo(previousbBlock.Height, previousbBlock.Timestamp, previousMiner, previousbBlock.GlobalDifficulty)
 
 		DBTransaction.Set(SnapRootKey, sn)
 		DBTransaction.Set(HeightKey, miscellaneous.E64func(dH-1))
@@ -1135,6 +1316,37 @@ func (bc *Blockchain) DeleteBlock(height uint64) error {
 	return nil
 }
 
+// dropBrokenBlock removes height h from the height and snapshot indexes and
+// rolls HeightKey and SnapRootKey back to h-1.
+func dropBrokenBlock(db store.DB, h uint64) error {
+	DBTransaction := db.NewTransaction()
+	defer DBTransaction.Cancel()
+
+	sn, err := DBTransaction.Get(append(SnapRootPrefix, miscellaneous.E64func(h-1)...))
+	if err != nil {
+		logger.Error("Failed to DBTransaction.Get", zap.Error(err))
+		return err
+	}
+	if hash, err := DBTransaction.Get(append(HeightPrefix, miscellaneous.E64func(h)...)); err == nil {
+		if err := DBTransaction.Del(hash); err != nil {
+			return err
+		}
+	}
+	if err := DBTransaction.Del(append(HeightPrefix, miscellaneous.E64func(h)...)); err != nil {
+		return err
+	}
+	if err := DBTransaction.Del(append(SnapRootPrefix, miscellaneous.E64func(h)...)); err != nil {
+		return err
+	}
+	if err := DBTransaction.Set(SnapRootKey, sn); err != nil {
+		return err
+	}
+	if err := DBTransaction.Set(HeightKey, miscellaneous.E64func(h-1)); err != nil {
+		return err
+	}
+	return DBTransaction.Commit()
+}
+
 // distr coin out test
 func distr(txs []*transaction.SignedTransaction, minaddr *common.Address, height uint64) []*transaction.SignedTransaction {
 	total := GetMinerAmount(height)
@@ -1166,11 +1378,11 @@ func setTxbyaddrKV(DBTransaction store.Transaction, addr []byte, hash []byte, he
 	}
 	DBTransaction.Set(hash, tdex)
 	logger.Info("hash set", zap.String("hash", hex.EncodeToString(hash)), zap.Any("josn data", tdex))
-	return err
+	return setAddrTx(DBTransaction, addr, hash, height, index)
 }
 
 // deleteTxbyaddrKV delete transaction data by address and corresponding kV
-func deleteTxbyaddrKV(DBTransaction store.Transaction, addr []byte, tx transaction.FinishedTransaction, index uint64) error {
+func deleteTxbyaddrKV(DBTransaction store.Transaction, addr []byte, tx transaction.FinishedTransaction, height, index uint64) error {
 	txHash := tx.Hash()
 	err := DBTransaction.Mdel(addr, txHash)
 	if err != nil {
@@ -1182,7 +1394,7 @@ func deleteTxbyaddrKV(DBTransaction store.Transaction, addr []byte, tx transacti
 		logger.Error("deleteTxbyaddrKV Del err ", zap.Error(err))
 		return err
 	}
-	return err
+	return delAddrTx(DBTransaction, addr, height, index)
 }
 
 func (bc *Blockchain) DifficultDetection(b *block.Block) error {
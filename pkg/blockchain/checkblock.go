@@ -5,45 +5,403 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"time"
 
 	"metechain/pkg/block"
+	"metechain/pkg/logger"
 	"metechain/pkg/storage/store"
+	"metechain/pkg/transaction"
 
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/sha3"
 )
 
+const (
+	// MaxBlockTransactions is the most transactions a block can carry
+	MaxBlockTransactions = 101
+	// MedianTimeBlocks is the number of past blocks whose median timestamp a
+	// new block must not be older than
+	MedianTimeBlocks = 11
+	// MaxFutureBlockTime is how far ahead of the local clock a block can be
+	MaxFutureBlockTime = 2 * time.Minute
+)
+
+// RejectReason is why a block failed validation
+type RejectReason uint8
+
+const (
+	RejectUnknown RejectReason = iota
+	// header
+	RejectHash
+	RejectDifficulty
+	RejectTimestamp
+	RejectVersion
+	RejectGas
+	// body
+	RejectTxCount
+	RejectTxRoot
+	RejectSignature
+	RejectDuplicateTx
+	RejectCoinbase
+	// state
+	RejectNonce
+	RejectState
+)
+
+var rejectReasons = [...]string{
+	RejectUnknown:     "unknown",
+	RejectHash:        "hash",
+	RejectDifficulty:  "difficulty",
+	RejectTimestamp:   "timestamp",
+	RejectVersion:     "version",
+	RejectGas:         "gas",
+	RejectTxCount:     "transaction count",
+	RejectTxRoot:      "transaction root",
+	RejectSignature:   "signature",
+	RejectDuplicateTx: "duplicate transaction",
+	RejectCoinbase:    "coinbase",
+	RejectNonce:       "nonce",
+	RejectState:       "state",
+}
+
+func (r RejectReason) String() string {
+	if int(r) < len(rejectReasons) {
+		return rejectReasons[r]
+	}
+	return fmt.Sprintf("reason %d", r)
+}
+
+// BlockError is the error of a block that failed validation, peers that
+// send such blocks can be scored on its Reason
+type BlockError struct {
+	Reason RejectReason
+	Err    error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("invalid block, %s: %v", e.Reason, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// reject returns err as a BlockError with the reason, unless it is one already
+func reject(reason RejectReason, err error) error {
+	var berr *BlockError
+	if err == nil || errors.As(err, &berr) {
+		return err
+	}
+	return &BlockError{Reason: reason, Err: err}
+}
+
+func rejectf(reason RejectReason, format string, args ...interface{}) error {
+	return &BlockError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// Rejection returns why err rejected a block, if it did
+func Rejection(err error) (RejectReason, bool) {
+	var berr *BlockError
+	if errors.As(err, &berr) {
+		return berr.Reason, true
+	}
+	return RejectUnknown, false
+}
+
+// CheckBlockRegular validates the block b, which extends the tip, in layers:
+// the header, the body and then the state of the tip. The error of a block
+// that is not valid is a *BlockError.
 func (bc *Blockchain) CheckBlockRegular(b *block.Block) error {
 	tx := bc.NewTransaction()
 	defer tx.Cancel()
-	return bc.checkBlockRegular(b, bc.db, tx)
+	if err := bc.checkBlockRegular(b, bc.db, tx); err != nil {
+		return err
+	}
+	return bc.checkBlockState(b)
 }
 
+// checkBlockRegular checks what does not need the state, the header and the
+// body of the block b
 func (bc *Blockchain) checkBlockRegular(b *block.Block, db store.DB, tx store.Transaction) error {
-	if len(b.Transactions) > 101 {
-		return errors.New("Too many transactions")
+	if err := bc.checkHeader(b, db, tx); err != nil {
+		return err
+	}
+	return checkBody(b)
+}
+
+// checkHeader checks the version, which is not below the one of the parent
+// and is the one configured for its height, the hash, proof of work,
+// timestamp and gas of the block b
+func (bc *Blockchain) checkHeader(b *block.Block, db store.DB, tx store.Transaction) error {
+	if b.Version < block.Version1 || b.Version > block.Version3 {
+		return rejectf(RejectVersion, "unknown version %d", b.Version)
+	}
+	// a lower version would skip checks the parent is held to
+	if b.Height > InitHeight+1 {
+		parent, err := getBlockByHeight(b.Height-1, tx)
+		if err != nil {
+			return err
+		}
+		if b.Version < parent.Version {
+			return rejectf(RejectVersion, "version %d below version %d of the parent", b.Version, parent.Version)
+		}
+	}
+	// roots are committed to and gas is metered from the heights the chain
+	// configures on, not before
+	if want := bc.blockVersion(b.Height); b.Version != want {
+		return rejectf(RejectVersion, "version %d at height %d, want %d", b.Version, b.Height, want)
+	}
+
+	// older blocks are changed after they are hashed
+	if b.Version >= block.Version2 {
+		if err := checkBlockHash(b); err != nil {
+			return reject(RejectHash, err)
+		}
 	}
 
 	// checkout Difficulty
 	if err := difficultDetection(b, db, tx); err != nil {
+		return reject(RejectDifficulty, err)
+	}
+
+	if err := checkTimestamp(b, tx, time.Now()); err != nil {
 		return err
 	}
 
+	if b.GasLimit != bc.ChainCfg.GasLimit {
+		return rejectf(RejectGas, "gas limit %d, want %d", b.GasLimit, bc.ChainCfg.GasLimit)
+	}
+	if gasMetered(b) {
+		return checkBaseFee(b, tx)
+	}
 	for _, tx := range b.Transactions {
 		if tx.IsCoinBaseTransaction() {
 			continue
 		}
 
 		if err := checkGas(tx.GasLimit, tx.GasPrice); err != nil {
+			return reject(RejectGas, err)
+		}
+	}
+
+	return nil
+}
+
+// checkBaseFee checks the base fee of the block b against the one its parent
+// sets and that it does not use more gas than its gas limit. The gas of its
+// transactions is checked when they are executed.
+func checkBaseFee(b *block.Block, tx store.Transaction) error {
+	if b.GasUsed == nil || !b.GasUsed.IsUint64() || b.GasUsed.Uint64() > b.GasLimit {
+		return rejectf(RejectGas, "gas used %v above the gas limit %d", b.GasUsed, b.GasLimit)
+	}
+
+	// the genesis block does not meter gas
+	parent := &block.Block{}
+	if b.Height > InitHeight+1 {
+		prev, err := getBlockByHeight(b.Height-1, tx)
+		if err != nil {
+			return err
+		}
+		parent = prev
+	}
+	if baseFee := CalcBaseFee(parent); b.BaseFee == nil || b.BaseFee.Cmp(baseFee) != 0 {
+		return rejectf(RejectGas, "base fee %v, want %v", b.BaseFee, baseFee)
+	}
+	return nil
+}
+
+// checkTimestamp rejects the block b if it is older than the median of the
+// timestamps of the blocks before it or too far ahead of now
+func checkTimestamp(b *block.Block, tx store.Transaction, now time.Time) error {
+	if max := uint64(now.Add(MaxFutureBlockTime).Unix()); b.Timestamp > max {
+		return rejectf(RejectTimestamp, "timestamp %d is ahead of %d", b.Timestamp, max)
+	}
+
+	var times []uint64
+	for h := b.Height; h > InitHeight+1 && len(times) < MedianTimeBlocks; h-- {
+		prev, err := getBlockByHeight(h-1, tx)
+		if err != nil {
+			return err
+		}
+		times = append(times, prev.Timestamp)
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if median := times[len(times)/2]; b.Timestamp < median {
+		return rejectf(RejectTimestamp, "timestamp %d is before the median %d of the past blocks", b.Timestamp, median)
+	}
+	return nil
+}
+
+// checkBody checks the transactions of the block b: their number, that there
+// is exactly one coinbase paying the reward to the miner, that none repeats,
+// their Merkle root and their signatures
+func checkBody(b *block.Block) error {
+	if len(b.Transactions) > MaxBlockTransactions {
+		return rejectf(RejectTxCount, "%d transactions, at most %d", len(b.Transactions), MaxBlockTransactions)
+	}
+
+	var coinbase int
+	seen := make(map[string]struct{}, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		if tx.IsCoinBaseTransaction() {
+			coinbase++
+			if reward := GetMinerAmount(b.Height); tx.Amount == nil || tx.Amount.Cmp(reward) != 0 {
+				return rejectf(RejectCoinbase, "coinbase amount %v, want %v", tx.Amount, reward)
+			}
+			if tx.To == nil || b.Miner == nil || *tx.To != *b.Miner {
+				return rejectf(RejectCoinbase, "coinbase does not pay the miner %v", b.Miner)
+			}
+		}
+
+		hash := string(tx.Hash())
+		if _, ok := seen[hash]; ok {
+			return rejectf(RejectDuplicateTx, "transaction %s repeats", tx.HashToString())
+		}
+		seen[hash] = struct{}{}
+	}
+	if coinbase != 1 {
+		return rejectf(RejectCoinbase, "%d coinbase transactions", coinbase)
+	}
+
+	root, err := txRoot(b.Transactions)
+	if err != nil {
+		return reject(RejectTxRoot, err)
+	}
+	if !bytes.Equal(root, b.Root) {
+		return rejectf(RejectTxRoot, "transaction root %x, recomputed %x", b.Root, root)
+	}
+
+	for _, tx := range b.Transactions {
+		if tx.IsCoinBaseTransaction() {
+			continue
+		}
+		if err := tx.VerifySign(); err != nil {
+			return rejectf(RejectSignature, "transaction %s: %v", tx.HashToString(), err)
+		}
+	}
+	return nil
+}
+
+// checkBlockState checks the block b against the state of the tip: the nonces
+// of its transactions and, for blocks that commit to them, the roots of
+// executing it
+func (bc *Blockchain) checkBlockState(b *block.Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if err := bc.checkNonces(b); err != nil {
+		return err
+	}
+
+	if b.Version < block.Version2 {
+		return nil
+	}
+	// executing sets the gas used of the transactions, b keeps its own
+	executed := *b
+	executed.Transactions = make([]*transaction.FinishedTransaction, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		data, err := tx.Serialize()
+		if err != nil {
+			return err
+		}
+		if executed.Transactions[i], err = transaction.DeserializeFinishedTransaction(data); err != nil {
 			return err
 		}
 	}
+	if err := bc.executeBlock(&executed); err != nil {
+		return reject(RejectState, err)
+	}
+	return verifyRoots(b, nil, executed.SnapRoot, executed.ReceiptsRoot, executed.GasUsed)
+}
+
+// checkNonces rejects the block b unless the transactions of each sender take
+// the nonces following on its one on chain in turn, without reusing or
+// skipping any
+func (bc *Blockchain) checkNonces(b *block.Block) error {
+	next := make(map[common.Address]uint64)
+	for _, tx := range b.Transactions {
+		if tx.IsCoinBaseTransaction() || tx.From == nil {
+			continue
+		}
+		n, ok := next[*tx.From]
+		if !ok {
+			n = stateNonce(bc.sdb, *tx.From)
+		}
+		if tx.Nonce != n {
+			return rejectf(RejectNonce, "transaction %s has nonce %d, %s is at %d", tx.HashToString(), tx.Nonce, tx.From, n)
+		}
+		next[*tx.From] = n + 1
+	}
+	return nil
+}
+
+// ExecuteBlock executes the block b on top of the tip without adding it, and
+// sets the state root, receipts root and gas used of b to the executed ones.
+// A miner commits to them, and to the difficulty, before sealing the block.
+func (bc *Blockchain) ExecuteBlock(b *block.Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.executeBlock(b)
+}
 
+func (bc *Blockchain) executeBlock(b *block.Block) error {
+	rollroot, err := getSnapRoot(bc.db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := bc.rollState(rollroot); err != nil {
+			logger.Error("rollState Failed", zap.Error(err))
+		}
+	}()
+
+	DBTransaction := bc.db.NewTransaction()
+	defer DBTransaction.Cancel()
+	return bc.addTempBlock(b, DBTransaction, true)
+}
+
+// commitsRoots reports whether the block b commits to its state root, receipts
+// root and gas used, as blocks do from block.Version2 on
+func commitsRoots(b *block.Block) bool {
+	return b.Version >= block.Version2
+}
+
+// verifyRoots checks the state root, receipts root and gas used the block b
+// commits to against the ones of executing it on the state prevRoot. Blocks
+// before block.Version2 do not commit to them, they take the executed ones
+// unless their SnapRoot is already another one than prevRoot.
+func verifyRoots(b *block.Block, prevRoot, snapRoot, receiptsRoot []byte, gasUsed *big.Int) error {
+	if b.Version < block.Version2 {
+		if bytes.Equal(prevRoot, b.SnapRoot) {
+			b.SnapRoot = snapRoot
+		} else if !bytes.Equal(snapRoot, b.SnapRoot) {
+			return rejectf(RejectState, "SnapRoot not equal")
+		}
+		b.GasUsed = gasUsed
+		return nil
+	}
+
+	if !bytes.Equal(b.SnapRoot, snapRoot) {
+		return rejectf(RejectState, "state root %x, executed %x", b.SnapRoot, snapRoot)
+	}
+	if !bytes.Equal(b.ReceiptsRoot, receiptsRoot) {
+		return rejectf(RejectState, "receipts root %x, executed %x", b.ReceiptsRoot, receiptsRoot)
+	}
+	if b.GasUsed == nil || b.GasUsed.Cmp(gasUsed) != 0 {
+		return rejectf(RejectState, "gas used %v, executed %v", b.GasUsed, gasUsed)
+	}
 	return nil
 }
 
+// checkBlockHash checks that the block b hashes to its hash, as SetHash
+// computed it before b had one
 func checkBlockHash(b *block.Block) error {
 	copyB := *b
-	copyB.GasUsed = new(big.Int)
 	copyB.Hash = []byte{}
 	data, err := copyB.Serialize()
 	if err != nil {
@@ -0,0 +1,265 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"metechain/pkg/block"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockRoots(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	miner := *chainCfg.Miner
+
+	b, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.Equal(block.Version3, b.Version)
+	unsealed := b.MinerHash()
+	assert.NoError(bc.ExecuteBlock(b))
+	assert.NotEqual(unsealed, b.MinerHash())
+	assert.NoError(b.SetHash())
+	assert.NoError(bc.checkBlockState(b))
+
+	data, err := b.Serialize()
+	assert.NoError(err)
+	decoded, err := block.Deserialize(data)
+	assert.NoError(err)
+	assert.Equal(b.ReceiptsRoot, decoded.ReceiptsRoot)
+	assert.Equal(b.MinerHash(), decoded.MinerHash())
+
+	other := common.HexToAddress("0x02")
+	for _, tamper := range []func(b *block.Block){
+		func(b *block.Block) { b.SnapRoot = make([]byte, 32) },
+		func(b *block.Block) { b.ReceiptsRoot = make([]byte, 32) },
+		func(b *block.Block) { b.GasUsed = new(big.Int).Add(b.GasUsed, big.NewInt(1)) },
+		func(b *block.Block) { b.Miner = &other },
+		func(b *block.Block) { b.GlobalDifficulty = big.NewInt(1) },
+	} {
+		bad := *b
+		tamper(&bad)
+		assert.NotEqual(b.MinerHash(), bad.MinerHash())
+	}
+	for _, tamper := range []func(b *block.Block){
+		func(b *block.Block) { b.SnapRoot = make([]byte, 32) },
+		func(b *block.Block) { b.ReceiptsRoot = make([]byte, 32) },
+		func(b *block.Block) { b.GasUsed = new(big.Int).Add(b.GasUsed, big.NewInt(1)) },
+	} {
+		bad := *b
+		tamper(&bad)
+		assert.Error(bc.checkBlockState(&bad))
+		assert.Error(bc.AddBlock(&bad))
+	}
+
+	// the older version does not commit to the roots
+	v1 := *b
+	v1.Version = block.Version1
+	unsealed = v1.MinerHash()
+	v1.SnapRoot = make([]byte, 32)
+	assert.Equal(unsealed, v1.MinerHash())
+
+	assert.NoError(bc.AddBlock(b))
+	receipts, err := bc.GetBlockReceipts(b.Hash)
+	assert.NoError(err)
+	root, err := receiptsRoot(receipts)
+	assert.NoError(err)
+	assert.Equal(b.ReceiptsRoot, root)
+
+	problems, err := Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Empty(problems)
+}
+
+func TestCheckBlock(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	miner := *chainCfg.Miner
+
+	b, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.NoError(bc.ExecuteBlock(b))
+	assert.NoError(b.SetHash())
+
+	rejected := func(want RejectReason, err error) {
+		reason, ok := Rejection(err)
+		assert.True(ok, "%v", err)
+		assert.Equal(want, reason, "%v", err)
+	}
+
+	assert.NoError(checkBlockHash(b))
+	bad := *b
+	bad.Nonce++
+	assert.Error(checkBlockHash(&bad))
+
+	tx := bc.NewTransaction()
+	defer tx.Cancel()
+	now := time.Unix(int64(b.Timestamp), 0)
+	assert.NoError(checkTimestamp(b, tx, now))
+	bad = *b
+	bad.Timestamp = uint64(now.Add(MaxFutureBlockTime).Unix()) + 1
+	rejected(RejectTimestamp, checkTimestamp(&bad, tx, now))
+	bad.Timestamp = 1
+	rejected(RejectTimestamp, checkTimestamp(&bad, tx, now))
+
+	bad = *b
+	bad.Version = block.Version3 + 1
+	rejected(RejectVersion, bc.checkHeader(&bad, db, tx))
+	bad.Version = block.Version3
+	bad.Hash = make([]byte, 32)
+	rejected(RejectHash, bc.checkHeader(&bad, db, tx))
+
+	assert.NoError(checkBody(b))
+	coinbase := *b.Transactions[0]
+	assert.True(coinbase.IsCoinBaseTransaction())
+	for want, tamper := range map[RejectReason]func(b *block.Block){
+		RejectCoinbase:    func(b *block.Block) { b.Transactions = nil },
+		RejectDuplicateTx: func(b *block.Block) { b.Transactions = append(b.Transactions, b.Transactions[0]) },
+		RejectTxRoot:      func(b *block.Block) { b.Root = make([]byte, 32) },
+		RejectTxCount: func(b *block.Block) {
+			b.Transactions = make([]*transaction.FinishedTransaction, MaxBlockTransactions+1)
+		},
+	} {
+		bad := *b
+		tamper(&bad)
+		rejected(want, checkBody(&bad))
+	}
+	other := common.HexToAddress("0x02")
+	paid := coinbase
+	paid.Amount = new(big.Int).Add(coinbase.Amount, big.NewInt(1))
+	bad = *b
+	bad.Transactions = []*transaction.FinishedTransaction{&paid}
+	rejected(RejectCoinbase, checkBody(&bad))
+	bad.Miner = &other
+	bad.Transactions = []*transaction.FinishedTransaction{&coinbase}
+	rejected(RejectCoinbase, checkBody(&bad))
+
+	from := common.HexToAddress("0x03")
+	transfer := func(nonce uint64) *transaction.FinishedTransaction {
+		st := &transaction.SignedTransaction{Transaction: transaction.Transaction{
+			From: &from, To: &other, Amount: new(big.Int), Nonce: nonce,
+			GasLimit: big.NewInt(1), GasFeeCap: big.NewInt(1), GasPrice: big.NewInt(1), Type: transaction.TransferTransaction,
+		}}
+		return transaction.NewFinishedTransaction(st, new(big.Int), b.Height)
+	}
+	bad = *b
+	bad.Transactions = append([]*transaction.FinishedTransaction{transfer(0)}, b.Transactions...)
+	root, err := txRoot(bad.Transactions)
+	assert.NoError(err)
+	bad.Root = root
+	rejected(RejectSignature, checkBody(&bad))
+
+	// the nonces of an account start at 1
+	bad.Transactions = []*transaction.FinishedTransaction{transfer(1), transfer(2), &coinbase}
+	assert.NoError(bc.checkNonces(&bad))
+	bad.Transactions = []*transaction.FinishedTransaction{transfer(0), &coinbase}
+	rejected(RejectNonce, bc.checkNonces(&bad))
+	bad.Transactions = []*transaction.FinishedTransaction{transfer(1), transfer(1), &coinbase}
+	rejected(RejectNonce, bc.checkNonces(&bad))
+	// nor can they leave a gap
+	bad.Transactions = []*transaction.FinishedTransaction{transfer(1), transfer(3), &coinbase}
+	rejected(RejectNonce, bc.checkNonces(&bad))
+
+	// checking the state leaves the transactions of the block alone
+	txs := b.Transactions
+	gasUsed := b.Transactions[0].GasUsed
+	assert.NoError(bc.checkBlockState(b))
+	assert.Equal(txs, b.Transactions)
+	assert.True(gasUsed == b.Transactions[0].GasUsed)
+
+	bad = *b
+	bad.SnapRoot = make([]byte, 32)
+	rejected(RejectState, bc.checkBlockState(&bad))
+	assert.NoError(bc.AddBlock(b))
+}
+
+func TestVersionDowngrade(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	miner := *chainCfg.Miner
+
+	parent, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	parent.Version = block.Version2
+	assert.NoError(bc.ExecuteBlock(parent))
+	assert.NoError(parent.SetHash())
+	assert.NoError(bc.AddBlock(parent))
+
+	// a block of an older version would skip the hash and roots checks
+	b, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	b.Version = block.Version1
+	tx := bc.NewTransaction()
+	defer tx.Cancel()
+	err = bc.checkHeader(b, db, tx)
+	reason, ok := Rejection(err)
+	assert.True(ok, "%v", err)
+	assert.Equal(RejectVersion, reason)
+	assert.Contains(err.Error(), "parent")
+}
+
+func TestVersionHeight(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	tip, err := New(db, chainCfg)
+	assert.NoError(err)
+	height, err := tip.GetMaxBlockHeight()
+	assert.NoError(err)
+	miner := *chainCfg.Miner
+	tx := tip.NewTransaction()
+	defer tx.Cancel()
+	rejected := func(err error) {
+		reason, ok := Rejection(err)
+		assert.True(ok, "%v", err)
+		assert.Equal(RejectVersion, reason, "%v", err)
+	}
+
+	// without forks blocks stay at the first version
+	cfg := *chainCfg
+	cfg.Version2Height, cfg.Version3Height = nil, nil
+	bc, err := New(db, &cfg)
+	assert.NoError(err)
+	b, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.Equal(block.Version1, b.Version)
+	b.Version = block.Version2
+	rejected(bc.checkHeader(b, db, tx))
+
+	// before the fork blocks do not meter gas
+	fork := height + 2
+	cfg.Version2Height, cfg.Version3Height = new(uint64), &fork
+	bc, err = New(db, &cfg)
+	assert.NoError(err)
+	b, err = bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.Equal(block.Version2, b.Version)
+	assert.Nil(b.BaseFee)
+	b.Version = block.Version3
+	rejected(bc.checkHeader(b, db, tx))
+
+	// nor are their transactions packed by gas
+	st := &transaction.SignedTransaction{Transaction: transaction.Transaction{
+		From: &miner, To: &miner, Amount: new(big.Int), Type: transaction.TransferTransaction,
+		GasLimit: new(big.Int), GasFeeCap: new(big.Int), GasPrice: new(big.Int),
+	}}
+	b, err = bc.NewBlock([]*transaction.SignedTransaction{st}, &miner)
+	assert.NoError(err)
+	assert.Len(b.Transactions, 2)
+
+	// after it they must
+	b, err = tip.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.Equal(block.Version3, b.Version)
+	b.Version = block.Version2
+	rejected(tip.checkHeader(b, db, tx))
+}
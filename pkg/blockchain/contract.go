@@ -89,7 +89,11 @@ func (bc *Blockchain) CallSmartContract(contractAddr, origin, callInput, value s
 			bc.evm.SetBlockInfo(currB.Height, currB.Timestamp, miner, currB.GlobalDifficulty)
 		}
 	}
+	return bc.callSmartContract(bc.evm, contractAddr, origin, callInput, value)
+}
 
+// callSmartContract runs the call on e, whose block info is already set
+func (bc *Blockchain) callSmartContract(e *evm.Evm, contractAddr, origin, callInput, value string) (string, string, error) {
 	vl := big.NewInt(0)
 	if len(value) > 0 {
 		if res, ok := big.NewInt(0).SetString(value, 16); ok {
@@ -97,10 +101,10 @@ func (bc *Blockchain) CallSmartContract(contractAddr, origin, callInput, value s
 		}
 	}
 
-	bc.evm.SetConfig(vl, new(big.Int).SetUint64(bc.ChainCfg.GasPrice), MAXGASLIMIT, common.HexToAddress(origin))
+	e.SetConfig(vl, new(big.Int).SetUint64(bc.ChainCfg.GasPrice), MAXGASLIMIT, common.HexToAddress(origin))
 
 	if len(Check0x(callInput)) > 0 && len(contractAddr) > 0 {
-		ret, gasleft, err := bc.evm.Call(common.HexToAddress(contractAddr), common.HexToAddress(origin), common.Hex2Bytes(Check0x(callInput)))
+		ret, gasleft, err := e.Call(common.HexToAddress(contractAddr), common.HexToAddress(origin), common.Hex2Bytes(Check0x(callInput)))
 		if err != nil {
 			logger.Info("call contract", zap.String("ret", common.Bytes2Hex(ret)), zap.Error(err))
 			return common.Bytes2Hex(ret), "", err
@@ -146,70 +150,57 @@ func (bc *Blockchain) GetLogByHeight(height uint64) []*evmtypes.Log {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	var evmlog []*evmtypes.Log
 	block, err := bc.getBlockByHeight(height)
 	if err != nil {
 		logger.Error("getBlockByHeight err", zap.Error(err))
 		return nil
 	}
 
-	for _, tx := range block.Transactions {
-		if len(tx.Input) == 0 {
-			continue
-		}
-		evmtx, err := transaction.DecodeEvmData(tx.Input)
-		if err != nil {
-			fmt.Println("DecodeEvmData err", err)
-			return nil
-		}
-
-		for _, log := range evmtx.Logs {
-			evmlog = append(evmlog, log)
-		}
-
+	evmlog, err := bc.blockLogs(block)
+	if err != nil {
+		logger.Error("blockLogs err", zap.Error(err))
+		return nil
 	}
 
 	return evmlog
 }
 
-//handle contract transaction
-func (bc *Blockchain) handleContractTransaction(block *block.Block, DBTransaction store.Transaction, tx *transaction.FinishedTransaction, index int) (*big.Int, error) {
-	var gasLeft, gasLimit = new(big.Int), new(big.Int)
+// contractGasLimit is the gas limit of the evm for the contract transaction
+// tx of a block before block.Version3, in which gas is priced in fee
+func contractGasLimit(tx *transaction.FinishedTransaction) uint64 {
 	// gasLimit = tx.Transaction.GasLimit * tx.Transaction.GasPrice
-
-	gasLimit.Mul(tx.GasLimit, tx.GasPrice)
+	gasLimit := new(big.Int).Mul(tx.GasLimit, tx.GasPrice)
 	// if gasLimit == 0 {
 	if gasLimit.Cmp(big.NewInt(0)) == 0 {
 		// gasLimit = MAXGASLIMIT
 		gasLimit = Limit.MaxGasLimit()
 	}
+	return gasLimit.Uint64()
+}
+
+//handle contract transaction
+func (bc *Blockchain) handleContractTransaction(block *block.Block, DBTransaction store.Transaction, tx *transaction.FinishedTransaction, index int, gasLimit uint64, gasPrice *big.Int) (*big.Int, *transaction.Receipt, error) {
+	var gasLeft = new(big.Int)
 	evmC, err := transaction.DecodeEvmData(tx.Input)
 	if err != nil {
 		logger.Error("DecodeEvmData input error:", zap.Error(err))
-		return gasLeft, err
+		return gasLeft, nil, err
 	}
 
 	eth_tx, err := transaction.DecodeEthData(evmC.EthData)
 	if err != nil {
 		logger.Error("DecodeEvmData input error:", zap.Error(err))
-		return gasLeft, err
+		return gasLeft, nil, err
 	}
 
-	logger.Info("handleContractTransaction info", zap.Uint64("eth_tx gasprice", eth_tx.GasPrice().Uint64()), zap.String("gaslimit", gasLimit.String()), zap.String("tx.gasprice", tx.GasPrice.String()),
+	logger.Info("handleContractTransaction info", zap.Uint64("eth_tx gasprice", eth_tx.GasPrice().Uint64()), zap.Uint64("gaslimit", gasLimit), zap.String("gasprice", gasPrice.String()),
 		zap.String("origin", evmC.Origin.Hex()), zap.Uint64("nonce", tx.Transaction.Nonce), zap.Uint64("eth_tx.Value()", eth_tx.Value().Uint64()))
 
 	TxValue := eth_tx.Value().Div(eth_tx.Value(), Uint64ToBigInt(ETHDECIMAL))
-	bc.evm.SetConfig(TxValue, tx.GasPrice, gasLimit.Uint64(), evmC.Origin)
+	bc.evm.SetConfig(TxValue, gasPrice, gasLimit, evmC.Origin)
 	bc.evm.Prepare(common.BytesToHash(tx.Hash()), common.BytesToHash(block.Hash), index)
 
-	defer func() {
-		input, err := transaction.EncodeEvmData(evmC)
-		if err != nil {
-			logger.Error("EncodeEvmData error", zap.Error(err))
-			return
-		}
-		tx.Input = input
-	}()
+	receipt := transaction.NewReceipt(tx.Hash(), transaction.ReceiptStatusFailed)
 
 	switch evmC.Operation {
 	case "create", "Create":
@@ -221,43 +212,40 @@ func (bc *Blockchain) handleContractTransaction(block *block.Block, DBTransactio
 		// if gasLeft < MINGASLIMIT {
 		// 	gasLeft = MINGASLIMIT
 		// }
-		if gasLeft.Cmp(Limit.MinGasLimit()) == -1 {
+		if !gasMetered(block) && gasLeft.Cmp(Limit.MinGasLimit()) == -1 {
 			gasLeft = Limit.MinGasLimit()
 		}
 
 		if err != nil {
 			logger.Error("faile to create contract", zap.Error(fmt.Errorf("hash:%v,gasLeft:%v,error:%v", hex.EncodeToString(tx.Hash()), gasLeft, err))) //zap.String("name", evmC.ContractName), zap.Uint64("gasLeft", gasLeft), zap.Error(callErr))
-			evmC.Ret = common.Bytes2Hex(ret)
-			evmC.Status = false
+			receipt.RevertReason = revertReason(ret, err)
 			//callErr = err
-			return gasLeft, nil
+			return gasLeft, receipt, nil
 		}
-		evmC.ContractAddr = contractAddr
-		logger.Info("Create contract successfully", zap.String("contract address:", evmC.ContractAddr.Hex()), zap.String("gasLeft", gasLeft.String()), zap.String("ret:", evmC.Ret), zap.String("origin", evmC.Origin.Hex()))
+		receipt.ContractAddress = contractAddr
+		logger.Info("Create contract successfully", zap.String("contract address:", contractAddr.Hex()), zap.String("gasLeft", gasLeft.String()), zap.String("origin", evmC.Origin.Hex()))
 	case "call", "Call":
 		bc.evm.SetNonce(evmC.Origin, tx.Transaction.Nonce+1)
 		ret, left, err := bc.evm.Call(evmC.ContractAddr, evmC.Origin, evmC.CallInput)
 
 		// gasLeft = left
 		gasLeft = Uint64ToBigInt(left)
-		if gasLeft.Cmp(Limit.MinGasLimit()) == -1 {
+		if !gasMetered(block) && gasLeft.Cmp(Limit.MinGasLimit()) == -1 {
 			gasLeft = Limit.MinGasLimit()
 		}
 
 		if err != nil {
 			logger.Error("faile to Call", zap.String("hash", hex.EncodeToString(tx.Hash())), zap.String("gasLeft", gasLeft.String()), zap.Error(err))
-			evmC.Ret = common.Bytes2Hex(ret)
-			evmC.Status = false
+			receipt.RevertReason = revertReason(ret, err)
 			//callErr = err
-			return gasLeft, nil
+			return gasLeft, receipt, nil
 		}
-		evmC.Ret = common.Bytes2Hex(ret)
-		logger.Info("Call contract successfully", zap.String("contract address:", evmC.ContractAddr.Hex()), zap.String("gasLeft", gasLeft.String()), zap.String("ret:", evmC.Ret), zap.String("origin", evmC.Origin.Hex()))
+		logger.Info("Call contract successfully", zap.String("contract address:", evmC.ContractAddr.Hex()), zap.String("gasLeft", gasLeft.String()), zap.String("ret:", common.Bytes2Hex(ret)), zap.String("origin", evmC.Origin.Hex()))
 	}
-	evmC.Status = true
-	evmC.Logs = bc.evm.GetLogs(common.BytesToHash(tx.Hash()), common.BytesToHash(block.Hash))
+	receipt.Status = transaction.ReceiptStatusSuccessful
+	receipt.Logs = bc.evm.GetLogs(common.BytesToHash(tx.Hash()), common.BytesToHash(block.Hash))
 
-	return gasLeft, nil
+	return gasLeft, receipt, nil
 }
 
 //get storage at address
@@ -0,0 +1,203 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"metechain/pkg/block"
+)
+
+const (
+	// MaxFeeHistory is the most blocks FeeHistory reports on
+	MaxFeeHistory = 1024
+
+	// SuggestTipCap samples the tipSamples lowest tips of each of the latest
+	// tipBlocks blocks and suggests the tipPercentile of them
+	tipBlocks     = 20
+	tipSamples    = 3
+	tipPercentile = 60
+)
+
+// FeeHistory is the fee market of a range of blocks
+type FeeHistory struct {
+	OldestBlock uint64
+	// BaseFee of each block and of the block after the newest one
+	BaseFee      []*big.Int
+	GasUsedRatio []float64
+	// Reward are the tips paid at the requested percentiles of the gas used
+	// by each block
+	Reward [][]*big.Int
+}
+
+// txTip is the tip per gas a transaction paid the miner and the gas it used
+type txTip struct {
+	tip     *big.Int
+	gasUsed uint64
+}
+
+// blockTips returns the tips the transactions of the block b paid, lowest
+// first. Transactions of blocks before block.Version3 paid their gas price.
+func (bc *Blockchain) blockTips(b *block.Block) ([]txTip, error) {
+	receipts, err := bc.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(b.Transactions) {
+		return nil, fmt.Errorf("%d receipts of %d transactions in block %d", len(receipts), len(b.Transactions), b.Height)
+	}
+
+	baseFee := new(big.Int)
+	if b.BaseFee != nil {
+		baseFee = b.BaseFee
+	}
+	tips := make([]txTip, 0, len(receipts))
+	for i, r := range receipts {
+		tx := b.Transactions[i]
+		if tx.IsCoinBaseTransaction() {
+			continue
+		}
+		price := r.EffectiveGasPrice
+		if price == nil {
+			price = tx.GasPrice
+		}
+		tips = append(tips, txTip{tip: new(big.Int).Sub(price, baseFee), gasUsed: r.GasUsed.Uint64()})
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].tip.Cmp(tips[j].tip) < 0 })
+	return tips, nil
+}
+
+// GetBaseFee get the base fee of the next block
+func (bc *Blockchain) GetBaseFee() (*big.Int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	h, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	b, err := bc.blockAt(h)
+	if err != nil {
+		return nil, err
+	}
+	return CalcBaseFee(b), nil
+}
+
+// SuggestTipCap suggests a tip that gets a transaction into one of the next
+// blocks, from the lowest tips paid in the latest blocks. Without any it is
+// the gas price of the chain config.
+func (bc *Blockchain) SuggestTipCap() (*big.Int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	h, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	var tips []*big.Int
+	for n := uint64(0); n < tipBlocks && n <= h; n++ {
+		b, err := bc.blockAt(h - n)
+		if err != nil {
+			return nil, err
+		}
+		blockTips, err := bc.blockTips(b)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(blockTips) && i < tipSamples; i++ {
+			tips = append(tips, blockTips[i].tip)
+		}
+	}
+	if len(tips) == 0 {
+		return new(big.Int).SetUint64(bc.ChainCfg.GasPrice), nil
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	return tips[(len(tips)-1)*tipPercentile/100], nil
+}
+
+// FeeHistory get the fee history of count blocks up to the newest one. The
+// rewards are the tips at the percentiles, which ascend from 0 to 100, of the
+// gas used by each block.
+func (bc *Blockchain) FeeHistory(count, newest uint64, percentiles []float64) (*FeeHistory, error) {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, fmt.Errorf("invalid reward percentile %v", p)
+		}
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	h, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	if newest > h {
+		return nil, fmt.Errorf("block %d is ahead of the chain at %d", newest, h)
+	}
+	if count > MaxFeeHistory {
+		count = MaxFeeHistory
+	}
+	if count > newest+1 {
+		count = newest + 1
+	}
+	if count == 0 {
+		return &FeeHistory{}, nil
+	}
+
+	history := &FeeHistory{OldestBlock: newest + 1 - count}
+	var b *block.Block
+	for height := history.OldestBlock; height <= newest; height++ {
+		if b, err = bc.blockAt(height); err != nil {
+			return nil, err
+		}
+
+		baseFee, ratio := new(big.Int), float64(0)
+		if gasMetered(b) {
+			baseFee.Set(b.BaseFee)
+			if b.GasLimit > 0 {
+				ratio = float64(b.GasUsed.Uint64()) / float64(b.GasLimit)
+			}
+		}
+		history.BaseFee = append(history.BaseFee, baseFee)
+		history.GasUsedRatio = append(history.GasUsedRatio, ratio)
+
+		if len(percentiles) == 0 {
+			continue
+		}
+		tips, err := bc.blockTips(b)
+		if err != nil {
+			return nil, err
+		}
+		history.Reward = append(history.Reward, rewards(tips, percentiles))
+	}
+	history.BaseFee = append(history.BaseFee, CalcBaseFee(b))
+	return history, nil
+}
+
+// rewards returns the tips at the percentiles of the gas used by the tips,
+// which are the lowest first
+func rewards(tips []txTip, percentiles []float64) []*big.Int {
+	reward := make([]*big.Int, len(percentiles))
+	if len(tips) == 0 {
+		for i := range reward {
+			reward[i] = new(big.Int)
+		}
+		return reward
+	}
+
+	var total uint64
+	for _, t := range tips {
+		total += t.gasUsed
+	}
+	i, sum := 0, tips[0].gasUsed
+	for j, p := range percentiles {
+		threshold := uint64(float64(total) * p / 100)
+		for sum < threshold && i < len(tips)-1 {
+			i++
+			sum += tips[i].gasUsed
+		}
+		reward[j] = new(big.Int).Set(tips[i].tip)
+	}
+	return reward
+}
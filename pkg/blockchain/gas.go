@@ -0,0 +1,207 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// InitialBaseFee is the base fee of the first block.Version3 block
+var InitialBaseFee = new(big.Int).SetUint64(params.InitialBaseFee)
+
+// gasMetered reports whether the transactions of the block b pay for the gas
+// they use, as they do from block.Version3 on
+func gasMetered(b *block.Block) bool {
+	return b.Version >= block.Version3
+}
+
+// blockVersion is the version of the block at the height, block.Version1
+// until the chain configures a later one from there on
+func (bc *Blockchain) blockVersion(height uint64) uint64 {
+	if fork := bc.ChainCfg.Version3Height; fork != nil && height >= *fork {
+		return block.Version3
+	}
+	if fork := bc.ChainCfg.Version2Height; fork != nil && height >= *fork {
+		return block.Version2
+	}
+	return block.Version1
+}
+
+// CalcBaseFee returns the base fee of the block after parent. As in EIP-1559
+// it moves by up to 1/8 a block towards filling half of the block gas limit.
+func CalcBaseFee(parent *block.Block) *big.Int {
+	if !gasMetered(parent) || parent.BaseFee == nil {
+		return new(big.Int).Set(InitialBaseFee)
+	}
+
+	target := new(big.Int).SetUint64(parent.GasLimit / params.ElasticityMultiplier)
+	used := parent.GasUsed
+	if used == nil {
+		used = new(big.Int)
+	}
+	if target.Sign() == 0 || used.Cmp(target) == 0 {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+
+	denominator := big.NewInt(params.BaseFeeChangeDenominator)
+	if used.Cmp(target) > 0 {
+		delta := new(big.Int).Sub(used, target)
+		delta.Mul(delta, parent.BaseFee).Div(delta, target).Div(delta, denominator)
+		if delta.Sign() == 0 {
+			delta.SetInt64(1)
+		}
+		return delta.Add(delta, parent.BaseFee)
+	}
+
+	delta := new(big.Int).Sub(target, used)
+	delta.Mul(delta, parent.BaseFee).Div(delta, target).Div(delta, denominator)
+	baseFee := new(big.Int).Sub(parent.BaseFee, delta)
+	if baseFee.Sign() < 0 {
+		baseFee.SetInt64(0)
+	}
+	return baseFee
+}
+
+// packTransactions returns the transactions of txs that fit in a block with
+// the gas limit and the base fee, in their order. Once a transaction of a
+// sender is left out so are its later ones, their nonces would not follow.
+func packTransactions(txs []*transaction.SignedTransaction, gasLimit uint64, baseFee *big.Int) []*transaction.SignedTransaction {
+	packed := make([]*transaction.SignedTransaction, 0, len(txs))
+	skipped := make(map[common.Address]struct{})
+	for _, tx := range txs {
+		if _, ok := skipped[*tx.From]; ok {
+			continue
+		}
+		intrinsic, err := tx.IntrinsicGas()
+		if err != nil || !tx.GasLimit.IsUint64() || tx.GasLimit.Uint64() < intrinsic ||
+			tx.GasLimit.Uint64() > gasLimit || tx.FeeCap().Cmp(baseFee) < 0 {
+			skipped[*tx.From] = struct{}{}
+			continue
+		}
+		gasLimit -= tx.GasLimit.Uint64()
+		packed = append(packed, tx)
+	}
+	return packed
+}
+
+// applyTransaction executes the transaction tx of the block b, which meters
+// gas. The payer buys the gas limit of tx at its effective gas price up front
+// and gets back what tx did not use. Of the price of the gas used the base fee
+// is burnt and the tip is paid to the miner. gp is the gas left in the block,
+// tx takes what it used from it.
+func (bc *Blockchain) applyTransaction(DBTransaction store.Transaction, b *block.Block, tx *transaction.FinishedTransaction, index int, gp *uint64) (*transaction.Receipt, error) {
+	txHash := tx.Hash()
+	if tx.IsCoinBaseTransaction() {
+		if err := setTxbyaddrKV(DBTransaction, tx.To.Bytes(), txHash, b.Height, uint64(index)); err != nil {
+			return nil, err
+		}
+		tx.GasUsed = new(big.Int)
+		if err := bc.setToAccount(b, &tx.Transaction); err != nil {
+			return nil, err
+		}
+		return transferReceipt(tx), nil
+	}
+
+	if err := setTxbyaddrKV(DBTransaction, tx.From.Bytes(), txHash, b.Height, uint64(index)); err != nil {
+		return nil, err
+	}
+	if !tx.IsEvmContractTransaction() {
+		if err := setTxbyaddrKV(DBTransaction, tx.To.Bytes(), txHash, b.Height, uint64(index)); err != nil {
+			return nil, err
+		}
+	}
+
+	intrinsic, err := tx.IntrinsicGas()
+	if err != nil {
+		return nil, reject(RejectGas, err)
+	}
+	if !tx.GasLimit.IsUint64() || tx.GasLimit.Uint64() < intrinsic {
+		return nil, rejectf(RejectGas, "transaction %s has gas limit %v, below its intrinsic gas %d", tx.HashToString(), tx.GasLimit, intrinsic)
+	}
+	gasLimit := tx.GasLimit.Uint64()
+	if gasLimit > *gp {
+		return nil, rejectf(RejectGas, "transaction %s has gas limit %d, the block has %d left", tx.HashToString(), gasLimit, *gp)
+	}
+	price, err := tx.EffectiveGasPrice(b.BaseFee)
+	if err != nil {
+		return nil, rejectf(RejectGas, "transaction %s: %v", tx.HashToString(), err)
+	}
+
+	payer := *tx.From
+	if tx.Type == transaction.WithdrawToEthTransaction {
+		kaddr, err := getBindingmeteAddress(DBTransaction, tx.From.Hex())
+		if err != nil {
+			return nil, err
+		}
+		payer = *kaddr
+	}
+	if err := buyGas(bc, payer, new(big.Int).Mul(tx.GasLimit, price)); err != nil {
+		return nil, rejectf(RejectState, "transaction %s: %v", tx.HashToString(), err)
+	}
+
+	var receipt *transaction.Receipt
+	used := intrinsic
+	if tx.IsEvmContractTransaction() {
+		refund := bc.evm.GetRefund()
+		gasLeft, r, err := bc.handleContractTransaction(b, DBTransaction, tx, index, gasLimit-intrinsic, price)
+		if err != nil {
+			return nil, err
+		}
+		receipt = r
+		used = gasLimit - gasLeft.Uint64()
+		if refund = bc.evm.GetRefund() - refund; refund > used/params.RefundQuotientEIP3529 {
+			refund = used / params.RefundQuotientEIP3529
+		}
+		used -= refund
+	} else {
+		// update nonce,txs in block must be ordered
+		if err := setNonce(bc.sdb, *tx.From, tx.Nonce+1); err != nil {
+			return nil, err
+		}
+		// the gas is paid for already
+		moved := *tx
+		moved.GasUsed = new(big.Int)
+		if err := setAccount(bc, &moved); err != nil {
+			return nil, rejectf(RejectState, "transaction %s: %v", tx.HashToString(), err)
+		}
+		receipt = transferReceipt(&moved)
+	}
+	*gp -= used
+
+	// give back the gas tx did not use
+	balance, err := bc.getBalance(payer)
+	if err != nil {
+		return nil, err
+	}
+	unused := new(big.Int).SetUint64(gasLimit - used)
+	if err := setBalance(bc.sdb, payer, unused.Add(balance, unused.Mul(unused, price))); err != nil {
+		return nil, err
+	}
+	tx.GasUsed = new(big.Int).SetUint64(used)
+	tip := new(big.Int).Sub(price, b.BaseFee)
+	if err := setMinerFee(bc, *b.Miner, tip.Mul(tip, tx.GasUsed)); err != nil {
+		return nil, err
+	}
+
+	receipt.GasUsed.Set(tx.GasUsed)
+	receipt.EffectiveGasPrice = price
+	return receipt, nil
+}
+
+// buyGas takes the cost of the gas a transaction may use from the payer
+func buyGas(bc *Blockchain, payer common.Address, cost *big.Int) error {
+	balance, err := bc.getBalance(payer)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("not sufficient funds, %s has %s, gas costs %s", payer, balance, cost)
+	}
+	return setBalance(bc.sdb, payer, new(big.Int).Sub(balance, cost))
+}
@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcBaseFee(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(InitialBaseFee, CalcBaseFee(&block.Block{Version: block.Version2}))
+
+	parent := &block.Block{Version: block.Version3, GasLimit: 800, GasUsed: big.NewInt(400), BaseFee: big.NewInt(8000)}
+	assert.Equal(big.NewInt(8000), CalcBaseFee(parent))
+	parent.GasUsed = big.NewInt(800)
+	assert.Equal(big.NewInt(9000), CalcBaseFee(parent))
+	parent.GasUsed = new(big.Int)
+	assert.Equal(big.NewInt(7000), CalcBaseFee(parent))
+
+	// it always goes up when the block is more than half full
+	parent.GasUsed, parent.BaseFee = big.NewInt(401), big.NewInt(1)
+	assert.Equal(big.NewInt(2), CalcBaseFee(parent))
+}
+
+func TestPackTransactions(t *testing.T) {
+	assert := assert.New(t)
+	a, b, c := common.HexToAddress("0x0a"), common.HexToAddress("0x0b"), common.HexToAddress("0x0c")
+	newTx := func(from common.Address, gasLimit, feeCap int64) *transaction.SignedTransaction {
+		return &transaction.SignedTransaction{Transaction: transaction.Transaction{
+			From: &from, To: &from, Amount: new(big.Int), Type: transaction.TransferTransaction,
+			GasLimit: big.NewInt(gasLimit), GasFeeCap: big.NewInt(feeCap), GasPrice: big.NewInt(1),
+		}}
+	}
+
+	txs := []*transaction.SignedTransaction{
+		newTx(a, 21000, 10),
+		newTx(b, 21000, 9),  // below the base fee
+		newTx(b, 21000, 10), // after a transaction that is left out
+		newTx(c, 20999, 10), // below the intrinsic gas
+		newTx(a, 30000, 10), // above the gas left
+		newTx(c, 21000, 10),
+	}
+	packed := packTransactions(txs, 50000, big.NewInt(10))
+	assert.Equal([]*transaction.SignedTransaction{txs[0]}, packed)
+
+	txs = append(txs[:3], txs[5])
+	packed = packTransactions(txs, 50000, big.NewInt(10))
+	assert.Equal([]*transaction.SignedTransaction{txs[0], txs[3]}, packed)
+}
+
+func TestApplyTransaction(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	from := *chainCfg.Miner
+	miner, to := common.HexToAddress("0x05"), common.HexToAddress("0x06")
+
+	nonce, err := bc.GetNonce(&from)
+	assert.NoError(err)
+	tip := big.NewInt(2)
+	st := &transaction.SignedTransaction{Transaction: transaction.Transaction{
+		From: &from, To: &to, Amount: big.NewInt(7), Nonce: nonce, Type: transaction.TransferTransaction,
+		GasLimit: big.NewInt(30000), GasFeeCap: new(big.Int).Mul(InitialBaseFee, big.NewInt(2)), GasPrice: tip,
+	}}
+	b, err := bc.NewBlock([]*transaction.SignedTransaction{st}, &miner)
+	assert.NoError(err)
+	assert.Len(b.Transactions, 2)
+
+	parent, err := bc.GetBlockByHash(b.PrevHash)
+	assert.NoError(err)
+	assert.Equal(CalcBaseFee(parent), b.BaseFee)
+
+	// the gas of the transaction is checked when it is executed
+	bad := *b
+	short := *b.Transactions[0]
+	short.GasLimit = big.NewInt(20000)
+	bad.Transactions = []*transaction.FinishedTransaction{&short, b.Transactions[1]}
+	reason, ok := Rejection(bc.ExecuteBlock(&bad))
+	assert.True(ok)
+	assert.Equal(RejectGas, reason)
+
+	balance, err := bc.GetBalance(&from)
+	assert.NoError(err)
+	before := new(big.Int).Set(balance)
+	assert.NoError(bc.ExecuteBlock(b))
+	assert.NoError(b.SetHash())
+	assert.Equal(big.NewInt(21000), b.GasUsed)
+
+	tx := bc.NewTransaction()
+	defer tx.Cancel()
+	assert.NoError(checkBaseFee(b, tx))
+	bad = *b
+	bad.BaseFee = new(big.Int).Add(b.BaseFee, big.NewInt(1))
+	reason, _ = Rejection(checkBaseFee(&bad, tx))
+	assert.Equal(RejectGas, reason)
+
+	data, err := b.Serialize()
+	assert.NoError(err)
+	decoded, err := block.Deserialize(data)
+	assert.NoError(err)
+	assert.Equal(b.BaseFee, decoded.BaseFee)
+	assert.NoError(bc.AddBlock(b))
+
+	price := new(big.Int).Add(b.BaseFee, tip)
+	fee := new(big.Int).Mul(big.NewInt(21000), price)
+	receipts, err := bc.GetBlockReceipts(b.Hash)
+	assert.NoError(err)
+	assert.Equal(big.NewInt(21000), receipts[0].GasUsed)
+	assert.Equal(price, receipts[0].EffectiveGasPrice)
+
+	balance, err = bc.GetBalance(&from)
+	assert.NoError(err)
+	assert.Equal(new(big.Int).Sub(before, new(big.Int).Add(fee, big.NewInt(7))), balance)
+	balance, err = bc.GetBalance(&to)
+	assert.NoError(err)
+	assert.Equal(big.NewInt(7), balance)
+	balance, err = bc.GetBalance(&miner)
+	assert.NoError(err)
+	// the base fee is burnt, the miner only gets the tip
+	assert.Equal(new(big.Int).Add(GetMinerAmount(b.Height), new(big.Int).Mul(big.NewInt(21000), tip)), balance)
+
+	// the block used less than half of its gas limit
+	assert.Equal(-1, CalcBaseFee(b).Cmp(b.BaseFee))
+
+	baseFee, err := bc.GetBaseFee()
+	assert.NoError(err)
+	assert.Equal(CalcBaseFee(b), baseFee)
+	suggested, err := bc.SuggestTipCap()
+	assert.NoError(err)
+	assert.Equal(tip, suggested)
+
+	history, err := bc.FeeHistory(2, b.Height, []float64{0, 50, 100})
+	assert.NoError(err)
+	assert.Equal(b.Height-1, history.OldestBlock)
+	assert.Equal([]*big.Int{parent.BaseFee, b.BaseFee, CalcBaseFee(b)}, history.BaseFee)
+	assert.Equal([]float64{0, 21000 / float64(b.GasLimit)}, history.GasUsedRatio)
+	assert.Equal([][]*big.Int{
+		{new(big.Int), new(big.Int), new(big.Int)},
+		{tip, tip, tip},
+	}, history.Reward)
+
+	_, err = bc.FeeHistory(1, b.Height+1, nil)
+	assert.Error(err)
+	_, err = bc.FeeHistory(1, b.Height, []float64{50, 10})
+	assert.Error(err)
+}
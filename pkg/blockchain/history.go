@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"metechain/pkg/contract/evm"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// ErrStatePruned is returned for blocks whose state is no longer stored,
+// because it was pruned or the chain was imported from a snapshot above them.
+var ErrStatePruned = errors.New("state of the block is pruned")
+
+// BlockRef names the block whose state is read: by Hash if it is set, by
+// Height otherwise.
+type BlockRef struct {
+	Height uint64
+	Hash   []byte
+}
+
+// refHeight returns the height of the block ref names in the current chain.
+func (bc *Blockchain) refHeight(ref BlockRef) (uint64, error) {
+	maxH, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return 0, err
+	}
+	if len(ref.Hash) == 0 {
+		if ref.Height > maxH {
+			return 0, fmt.Errorf("block %d is above the chain height %d", ref.Height, maxH)
+		}
+		return ref.Height, nil
+	}
+
+	b, err := bc.getBlockByHash(ref.Hash)
+	if err != nil {
+		return 0, err
+	}
+	// a block of a side chain may still be stored, its state is not
+	hash, err := bc.getHash(b.Height)
+	if err != nil && err != store.NotExist {
+		return 0, err
+	}
+	if b.Height > maxH || !bytes.Equal(hash, ref.Hash) {
+		return 0, fmt.Errorf("block %x is not in the chain", ref.Hash)
+	}
+	return b.Height, nil
+}
+
+// stateAt opens the state at the root stored for the block ref names. The
+// state is never committed, changes to it are dropped with it. The caller
+// must hold bc.mu.
+func (bc *Blockchain) stateAt(ref BlockRef) (*state.StateDB, uint64, error) {
+	height, err := bc.refHeight(ref)
+	if err != nil {
+		return nil, 0, err
+	}
+	pruned, err := PrunedHeight(bc.db)
+	if err != nil {
+		return nil, 0, err
+	}
+	if height < pruned {
+		return nil, 0, fmt.Errorf("block %d: %w", height, ErrStatePruned)
+	}
+	root, err := bc.db.Get(append(SnapRootPrefix, miscellaneous.E64func(height)...))
+	if err == store.NotExist {
+		return nil, 0, fmt.Errorf("block %d: %w", height, ErrStatePruned)
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	st, err := state.New(common.BytesToHash(root), bc.sdb.Database(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("state of block %d: %v", height, err)
+	}
+	return st, height, nil
+}
+
+// GetBalanceAt get the balance of the address at the block
+func (bc *Blockchain) GetBalanceAt(address *common.Address, ref BlockRef) (*big.Int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, _, err := bc.stateAt(ref)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetBalance(*address), st.Error()
+}
+
+// GetNonceAt get the nonce of the address at the block
+func (bc *Blockchain) GetNonceAt(address *common.Address, ref BlockRef) (uint64, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, _, err := bc.stateAt(ref)
+	if err != nil {
+		return 0, err
+	}
+	return stateNonce(st, *address), st.Error()
+}
+
+// GetCodeAt get contract code by address at the block
+func (bc *Blockchain) GetCodeAt(contractAddr string, ref BlockRef) ([]byte, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, _, err := bc.stateAt(ref)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetCode(common.HexToAddress(contractAddr)), st.Error()
+}
+
+// GetStorageAtBlock get storage at address at the block
+func (bc *Blockchain) GetStorageAtBlock(addr, hash string, ref BlockRef) (common.Hash, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, _, err := bc.stateAt(ref)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return st.GetState(common.HexToAddress(addr), common.HexToHash(hash)), st.Error()
+}
+
+// CallSmartContractAt call contract with out transaction on the state of the block
+func (bc *Blockchain) CallSmartContractAt(contractAddr, origin, callInput, value string, ref BlockRef) (string, string, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, height, err := bc.stateAt(ref)
+	if err != nil {
+		return "", "", err
+	}
+	e := evm.NewEvm(st, bc.ChainCfg.ChainId, bc.ChainCfg.GasLimit, new(big.Int).SetUint64(bc.ChainCfg.GasPrice))
+	if height > 0 {
+		b, err := bc.getBlockByHeight(height)
+		if err != nil {
+			return "", "", err
+		}
+		e.SetBlockInfo(b.Height, b.Timestamp, *b.Miner, b.GlobalDifficulty)
+	}
+	return bc.callSmartContract(e, contractAddr, origin, callInput, value)
+}
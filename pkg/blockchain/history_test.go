@@ -0,0 +1,52 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"metechain/pkg/storage/miscellaneous"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateAt(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	miner := chainCfg.Miner
+
+	// the miner is paid for every block, its balance grows with the height
+	b0, err := bc.GetBalanceAt(miner, BlockRef{Height: 0})
+	assert.NoError(err)
+	b2, err := bc.GetBalanceAt(miner, BlockRef{Height: 2})
+	assert.NoError(err)
+	assert.Equal(1, b2.Cmp(b0))
+	b3, err := bc.GetBalanceAt(miner, BlockRef{Height: 3})
+	assert.NoError(err)
+	assert.Equal(1, b3.Cmp(b2))
+	latest, err := bc.GetBalance(miner)
+	assert.NoError(err)
+	assert.Equal(latest, b3)
+
+	b, err := bc.GetBlockByHeight(2)
+	assert.NoError(err)
+	balance, err := bc.GetBalanceAt(miner, BlockRef{Hash: b.Hash})
+	assert.NoError(err)
+	assert.Equal(b2, balance)
+	nonce, err := bc.GetNonceAt(miner, BlockRef{Hash: b.Hash})
+	assert.NoError(err)
+	assert.Equal(uint64(1), nonce)
+
+	// New rolled block 4 back, it is above the chain now
+	_, err = bc.GetBalanceAt(miner, BlockRef{Height: 4})
+	assert.Error(err)
+	_, err = bc.GetBalanceAt(miner, BlockRef{Hash: []byte("unknown")})
+	assert.Error(err)
+
+	assert.NoError(db.Set(PrunedKey, miscellaneous.E64func(2)))
+	_, err = bc.GetBalanceAt(miner, BlockRef{Height: 1})
+	assert.True(errors.Is(err, ErrStatePruned))
+	_, err = bc.GetBalanceAt(miner, BlockRef{Height: 2})
+	assert.NoError(err)
+}
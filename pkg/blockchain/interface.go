@@ -9,6 +9,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	evmtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 //Blockchains interface specification of blockchain
@@ -29,6 +30,16 @@ type Blockchains interface {
 	GetAvailableBalance(*common.Address) (*big.Int, error)
 	// GetNonce get the nonce of the address
 	GetNonce(*common.Address) (uint64, error)
+	// GetBaseFee get the base fee of the next block
+	GetBaseFee() (*big.Int, error)
+	// SuggestTipCap suggests a tip that gets a transaction into one of the next blocks
+	SuggestTipCap() (*big.Int, error)
+	// FeeHistory get the base fees, gas used ratios and tips at the percentiles of blocks up to the newest one
+	FeeHistory(count, newest uint64, percentiles []float64) (*FeeHistory, error)
+	// GetBalanceAt get the balance of the address at the block
+	GetBalanceAt(*common.Address, BlockRef) (*big.Int, error)
+	// GetNonceAt get the nonce of the address at the block
+	GetNonceAt(*common.Address, BlockRef) (uint64, error)
 	// GetHash get the hash corresponding to the block height
 	GetHash(uint64) ([]byte, error)
 	// GetMaxBlockHeight get maximum block height
@@ -39,6 +50,8 @@ type Blockchains interface {
 	GetBlockByHash([]byte) (*block.Block, error)
 
 	ReorganizeChain([][]byte, uint64) error
+	// SubscribeReorg subscribes to the reorganizations of the main chain
+	SubscribeReorg(chan<- block.ReorgEvent) event.Subscription
 	Tip() (*block.Block, error)
 
 	//get binding mete address by eth address
@@ -47,17 +60,37 @@ type Blockchains interface {
 	GetBindingEthAddress(meteAddr *common.Address) (string, error)
 	//call contract
 	CallSmartContract(contractAddr, origin, callInput, value string) (string, string, error)
+	//call contract on the state of the block
+	CallSmartContractAt(contractAddr, origin, callInput, value string, ref BlockRef) (string, string, error)
 	//get code
 	GetCode(contractAddr string) []byte
+	//get code at the block
+	GetCodeAt(contractAddr string, ref BlockRef) ([]byte, error)
 	//set code
 	SetCode(contractAddr common.Address, code []byte)
 	//get storage by hash
 	GetStorageAt(addr, hash string) common.Hash
+	//get storage by hash at the block
+	GetStorageAtBlock(addr, hash string, ref BlockRef) (common.Hash, error)
 	// GetTransactionByHash get the transaction corresponding to the transaction hash
 	GetTransactionByHash([]byte) (*transaction.FinishedTransaction, error)
+	// GetReceipt get the receipt of the transaction hash
+	GetReceipt([]byte) (*transaction.Receipt, error)
+	// GetBlockReceipts get the receipts of the transactions of the block with the hash
+	GetBlockReceipts([]byte) ([]*transaction.Receipt, error)
+	// GetTransactionProof get the proof that the transaction hash is in its block
+	GetTransactionProof([]byte) (*TxProof, error)
+	// GetTransactionsByAddress get a page of the transactions of the address and the cursor of the next page
+	GetTransactionsByAddress(addr common.Address, cursor []byte, limit int, dir Direction) ([]*transaction.FinishedTransaction, []byte, error)
+	// GetProof get the account and storage proofs of the address at the block height
+	GetProof(common.Address, []common.Hash, uint64) (*AccountProof, error)
 	//Get logs
 	GetLogs() []*evmtypes.Log
 	GetLogByHeight(height uint64) []*evmtypes.Log
+	// FilterLogs get the logs of the blocks in the range matching the addresses and topics
+	FilterLogs(from, to uint64, addresses []common.Address, topics [][]common.Hash) ([]*evmtypes.Log, error)
+	// GetBlockBloom get the logs bloom of the block
+	GetBlockBloom([]byte) (evmtypes.Bloom, error)
 
 	DifficultDetection(b *block.Block) error
 	CheckBlockRegular(b *block.Block) error
@@ -0,0 +1,474 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"metechain/pkg/block"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core/bloombits"
+	evmtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+const (
+	// BloomBitsBlocks is the number of blocks in a section of the bloom index.
+	BloomBitsBlocks = 4096
+	// BloomConfirms is the depth below the tip a section must reach before it
+	// is indexed, so that short reorganizations do not invalidate it.
+	BloomConfirms = 256
+	// MaxLogsRange is the number of blocks FilterLogs scans at most, so that
+	// a query does not hold the chain for long.
+	MaxLogsRange = 10000
+)
+
+var (
+	// BloomPrefix + block hash -> logs bloom of the block
+	BloomPrefix = []byte("logsBloom")
+	// BloomBitsPrefix + bit + section + section head hash -> compressed bit
+	// vector of the bit in the blooms of the section
+	BloomBitsPrefix = []byte("bloomBits")
+	// BloomSectionsKey holds the number of indexed sections
+	BloomSectionsKey = []byte("bloomSections")
+)
+
+func bloomKey(hash []byte) []byte {
+	return append(append([]byte{}, BloomPrefix...), hash...)
+}
+
+func bloomBitsKey(bit uint, section uint64, head []byte) []byte {
+	pos := make([]byte, 10)
+	binary.BigEndian.PutUint16(pos, uint16(bit))
+	binary.BigEndian.PutUint64(pos[2:], section)
+	key := append(append([]byte{}, BloomBitsPrefix...), pos...)
+	return append(key, head...)
+}
+
+// blockLogs collects the logs of the transactions of b
+func (bc *Blockchain) blockLogs(b *block.Block) ([]*evmtypes.Log, error) {
+	receipts, err := bc.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	var logs []*evmtypes.Log
+	for _, r := range receipts {
+		logs = append(logs, r.Logs...)
+	}
+	return logs, nil
+}
+
+// setBlockBloom stores the logs bloom of the block b that was not executed
+// here, from the results its contract transactions carry
+func setBlockBloom(DBTransaction store.Transaction, b *block.Block) error {
+	receipts, err := inputReceipts(b)
+	if err != nil {
+		return err
+	}
+	var logs []*evmtypes.Log
+	for _, r := range receipts {
+		logs = append(logs, r.Logs...)
+	}
+	return DBTransaction.Set(bloomKey(b.Hash), evmtypes.LogsBloom(logs))
+}
+
+// blockBloom returns the logs bloom of the block with the hash. The bloom of
+// a block stored before blooms were kept is computed from its logs.
+func (bc *Blockchain) blockBloom(hash []byte) (evmtypes.Bloom, error) {
+	data, err := bc.db.Get(bloomKey(hash))
+	if err == nil {
+		return evmtypes.BytesToBloom(data), nil
+	} else if err != store.NotExist {
+		return evmtypes.Bloom{}, err
+	}
+
+	data, err = bc.readBlock(hash)
+	if err != nil {
+		return evmtypes.Bloom{}, err
+	}
+	b, err := block.Deserialize(data)
+	if err != nil {
+		return evmtypes.Bloom{}, err
+	}
+	logs, err := bc.blockLogs(b)
+	if err != nil {
+		return evmtypes.Bloom{}, err
+	}
+	return evmtypes.BytesToBloom(evmtypes.LogsBloom(logs)), nil
+}
+
+// GetBlockBloom get the logs bloom of the block
+func (bc *Blockchain) GetBlockBloom(hash []byte) (evmtypes.Bloom, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.blockBloom(hash)
+}
+
+// bloomSections returns the number of sections indexed on the current chain.
+// A section whose head left the chain is indexed again.
+func (bc *Blockchain) bloomSections() (uint64, error) {
+	data, err := bc.db.Get(BloomSectionsKey)
+	if err == store.NotExist {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	n, err := miscellaneous.D64func(data)
+	if err != nil {
+		return 0, err
+	}
+	for ; n > 0; n-- {
+		if _, ok := bc.bloomSectionHead(n - 1); ok {
+			break
+		}
+	}
+	return n, nil
+}
+
+// bloomSectionHead returns the hash of the last block of the section and
+// whether the section is indexed with that head.
+func (bc *Blockchain) bloomSectionHead(section uint64) ([]byte, bool) {
+	head, err := bc.getHash((section+1)*bc.bloomBitsBlocks - 1)
+	if err != nil {
+		return nil, false
+	}
+	_, err = bc.db.Get(bloomBitsKey(0, section, head))
+	return head, err == nil
+}
+
+// maybeIndexBloom starts indexing the blooms in the background once a section
+// is BloomConfirms blocks deep, unless a run is in progress. The caller holds
+// bc.mu.
+func (bc *Blockchain) maybeIndexBloom(height uint64) {
+	if height+1 < bc.bloomConfirms || (height+1-bc.bloomConfirms)%bc.bloomBitsBlocks != 0 {
+		return
+	}
+	bc.startBloomIndex()
+}
+
+func (bc *Blockchain) startBloomIndex() {
+	if !atomic.CompareAndSwapInt32(&bc.bloomIndexing, 0, 1) {
+		return
+	}
+	bc.background(func() {
+		defer atomic.StoreInt32(&bc.bloomIndexing, 0)
+		n, err := bc.IndexBloomBits()
+		if err != nil {
+			logger.Error("failed to index blooms", zap.Error(err))
+			return
+		}
+		if n > 0 {
+			logger.Info("indexed blooms", zap.Int("sections", n))
+		}
+	})
+}
+
+// IndexBloomBits indexes the blooms of every section that is BloomConfirms
+// blocks below the tip and returns how many sections were indexed. It stops
+// early once the chain is closing.
+func (bc *Blockchain) IndexBloomBits() (int, error) {
+	var n int
+	for !bc.closing() {
+		ok, err := bc.indexBloomSection()
+		if err != nil || !ok {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// indexBloomSection indexes the next section if it is deep enough
+func (bc *Blockchain) indexBloomSection() (bool, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	maxH, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return false, err
+	}
+	section, err := bc.bloomSections()
+	if err != nil {
+		return false, err
+	}
+	if maxH+1 < bc.bloomConfirms || (section+1)*bc.bloomBitsBlocks > maxH+1-bc.bloomConfirms {
+		return false, nil
+	}
+
+	gen, err := bloombits.NewGenerator(uint(bc.bloomBitsBlocks))
+	if err != nil {
+		return false, err
+	}
+	var head []byte
+	for i := uint64(0); i < bc.bloomBitsBlocks; i++ {
+		height := section*bc.bloomBitsBlocks + i
+		if head, err = bc.getHash(height); err != nil {
+			return false, fmt.Errorf("block %d: %v", height, err)
+		}
+		bloom, err := bc.blockBloom(head)
+		if err != nil {
+			return false, fmt.Errorf("bloom of block %d: %v", height, err)
+		}
+		if err := gen.AddBloom(uint(i), bloom); err != nil {
+			return false, err
+		}
+	}
+
+	tx := bc.db.NewTransaction()
+	defer tx.Cancel()
+	for bit := uint(0); bit < evmtypes.BloomBitLength; bit++ {
+		bits, err := gen.Bitset(bit)
+		if err != nil {
+			return false, err
+		}
+		if err := tx.Set(bloomBitsKey(bit, section, head), bitutil.CompressBytes(bits)); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Set(BloomSectionsKey, miscellaneous.E64func(section+1)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// bloomIndexes returns the three bits that data sets in a bloom
+func bloomIndexes(data []byte) [3]uint {
+	hash := crypto.Keccak256(data)
+	var idxs [3]uint
+	for i := range idxs {
+		idxs[i] = (uint(hash[2*i])<<8)&2047 + uint(hash[2*i+1])
+	}
+	return idxs
+}
+
+// logFilter matches logs of any of the addresses whose topics match the
+// topics by position. An empty list matches anything.
+type logFilter struct {
+	addresses []common.Address
+	topics    [][]common.Hash
+}
+
+// groups returns the bloom bits of the filter: a bloom matches if in every
+// group all three bits of one entry are set.
+func (f *logFilter) groups() [][][3]uint {
+	var groups [][][3]uint
+	if len(f.addresses) > 0 {
+		var g [][3]uint
+		for _, addr := range f.addresses {
+			g = append(g, bloomIndexes(addr.Bytes()))
+		}
+		groups = append(groups, g)
+	}
+	for _, topics := range f.topics {
+		if len(topics) == 0 {
+			continue
+		}
+		var g [][3]uint
+		for _, topic := range topics {
+			g = append(g, bloomIndexes(topic.Bytes()))
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func (f *logFilter) matchBloom(bloom evmtypes.Bloom) bool {
+	for _, g := range f.groups() {
+		var ok bool
+		for _, idxs := range g {
+			if bloomHas(bloom, idxs) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHas reports whether the three bits are set in the bloom, bit 0 being
+// the lowest bit of its last byte
+func bloomHas(bloom evmtypes.Bloom, idxs [3]uint) bool {
+	for _, idx := range idxs {
+		if bloom[evmtypes.BloomByteLength-1-idx/8]&(1<<(idx%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *logFilter) match(log *evmtypes.Log) bool {
+	if len(f.addresses) > 0 {
+		var ok bool
+		for _, addr := range f.addresses {
+			if log.Address == addr {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.topics) > len(log.Topics) {
+		return false
+	}
+	for i, topics := range f.topics {
+		if len(topics) == 0 {
+			continue
+		}
+		var ok bool
+		for _, topic := range topics {
+			if log.Topics[i] == topic {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomCandidates returns the heights between from and to, both included,
+// whose blooms match the filter. Indexed sections are matched on their bit
+// vectors, the other blocks on their own blooms.
+func (bc *Blockchain) bloomCandidates(from, to uint64, f *logFilter) ([]uint64, error) {
+	groups := f.groups()
+	sections, err := bc.bloomSections()
+	if err != nil {
+		return nil, err
+	}
+
+	var heights []uint64
+	for h := from; h <= to; {
+		section := h / bc.bloomBitsBlocks
+		if section < sections {
+			if head, ok := bc.bloomSectionHead(section); ok {
+				vector, err := bc.matchSection(section, head, groups)
+				if err != nil {
+					return nil, err
+				}
+				start := section * bc.bloomBitsBlocks
+				for ; h <= to && h < start+bc.bloomBitsBlocks; h++ {
+					i := h - start
+					if vector[i/8]&(1<<(7-i%8)) != 0 {
+						heights = append(heights, h)
+					}
+				}
+				continue
+			}
+		}
+
+		hash, err := bc.getHash(h)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", h, err)
+		}
+		bloom, err := bc.blockBloom(hash)
+		if err != nil {
+			return nil, fmt.Errorf("bloom of block %d: %v", h, err)
+		}
+		if f.matchBloom(bloom) {
+			heights = append(heights, h)
+		}
+		h++
+	}
+	return heights, nil
+}
+
+// matchSection returns the bit vector of the blocks of the section whose
+// blooms match the groups
+func (bc *Blockchain) matchSection(section uint64, head []byte, groups [][][3]uint) ([]byte, error) {
+	size := int(bc.bloomBitsBlocks / 8)
+	vectors := make(map[uint][]byte)
+	bitset := func(bit uint) ([]byte, error) {
+		if v, ok := vectors[bit]; ok {
+			return v, nil
+		}
+		data, err := bc.db.Get(bloomBitsKey(bit, section, head))
+		if err != nil {
+			return nil, err
+		}
+		v, err := bitutil.DecompressBytes(data, size)
+		if err != nil {
+			return nil, err
+		}
+		vectors[bit] = v
+		return v, nil
+	}
+
+	result := make([]byte, size)
+	for i := range result {
+		result[i] = 0xff
+	}
+	for _, g := range groups {
+		matched := make([]byte, size)
+		for _, idxs := range g {
+			all := make([]byte, size)
+			copy(all, result)
+			for _, bit := range idxs {
+				v, err := bitset(bit)
+				if err != nil {
+					return nil, err
+				}
+				bitutil.ANDBytes(all, all, v)
+			}
+			bitutil.ORBytes(matched, matched, all)
+		}
+		result = matched
+	}
+	return result, nil
+}
+
+// FilterLogs get the logs of the blocks from from to to, both included,
+// emitted by one of the addresses with topics matching topics by position.
+// An empty list of addresses or topics at a position matches anything.
+// The range spans MaxLogsRange blocks at most.
+func (bc *Blockchain) FilterLogs(from, to uint64, addresses []common.Address, topics [][]common.Hash) ([]*evmtypes.Log, error) {
+	if to >= from && to-from >= MaxLogsRange {
+		return nil, fmt.Errorf("block range %d-%d above %d blocks", from, to, MaxLogsRange)
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	maxH, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	if from > to || to > maxH {
+		return nil, fmt.Errorf("invalid block range %d-%d, chain height %d", from, to, maxH)
+	}
+
+	f := &logFilter{addresses: addresses, topics: topics}
+	heights, err := bc.bloomCandidates(from, to, f)
+	if err != nil {
+		return nil, err
+	}
+	var logs []*evmtypes.Log
+	for _, h := range heights {
+		b, err := bc.blockAt(h)
+		if err != nil {
+			return nil, err
+		}
+		blogs, err := bc.blockLogs(b)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range blogs {
+			if f.match(log) {
+				logs = append(logs, log)
+			}
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,81 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	evmtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomCandidates(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	defer bc.Close()
+	shrink := func(confirms uint64) {
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		bc.bloomBitsBlocks, bc.bloomConfirms = 8, confirms
+	}
+	shrink(1000)
+	miner := *chainCfg.Miner
+	for h := 4; h <= 17; h++ {
+		b, err := bc.NewBlock(nil, &miner)
+		assert.NoError(err)
+		assert.NoError(bc.ExecuteBlock(b))
+		assert.NoError(b.SetHash())
+		assert.NoError(bc.AddBlock(b))
+	}
+
+	addr := common.HexToAddress("0x1234")
+	topic := common.HexToHash("0xabcd")
+	var bloom evmtypes.Bloom
+	bloom.Add(addr.Bytes())
+	bloom.Add(topic.Bytes())
+	want := []uint64{2, 5, 13, 17}
+	for _, h := range want {
+		hash, err := bc.GetHash(h)
+		assert.NoError(err)
+		assert.NoError(db.Set(bloomKey(hash), bloom.Bytes()))
+	}
+
+	match := &logFilter{addresses: []common.Address{addr}, topics: [][]common.Hash{{topic}}}
+	miss := &logFilter{topics: [][]common.Hash{{common.HexToHash("0x01"), common.HexToHash("0x02")}}}
+	check := func() {
+		heights, err := bc.bloomCandidates(0, 17, match)
+		assert.NoError(err)
+		assert.Equal(want, heights)
+		heights, err = bc.bloomCandidates(3, 13, match)
+		assert.NoError(err)
+		assert.Equal([]uint64{5, 13}, heights)
+		heights, err = bc.bloomCandidates(0, 17, miss)
+		assert.NoError(err)
+		assert.Empty(heights)
+	}
+	check()
+
+	// the last two blocks are not in a full section
+	shrink(0)
+	n, err := bc.IndexBloomBits()
+	assert.NoError(err)
+	assert.Equal(2, n)
+	sections, err := bc.bloomSections()
+	assert.NoError(err)
+	assert.Equal(uint64(2), sections)
+	check()
+
+	n, err = bc.IndexBloomBits()
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	logs, err := bc.FilterLogs(0, 17, []common.Address{addr}, nil)
+	assert.NoError(err)
+	assert.Empty(logs)
+	_, err = bc.FilterLogs(0, 18, nil, nil)
+	assert.Error(err)
+	_, err = bc.FilterLogs(1, MaxLogsRange+1, nil, nil)
+	assert.Error(err)
+	assert.Contains(err.Error(), "above")
+}
@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/merkle"
+)
+
+// TxProof proves that a transaction is in a block: Proof leads from Leaf to
+// the Root of Header.
+type TxProof struct {
+	// Header is the block without its transactions
+	Header *block.Block
+	Index  uint64
+	// Leaf is the serialized transaction as it was submitted
+	Leaf  []byte
+	Proof *merkle.Proof
+}
+
+// Verify reports whether the proof holds for the root of its header.
+func (p *TxProof) Verify() bool {
+	return merkle.VerifyProof(p.Header.Root, p.Leaf, p.Proof)
+}
+
+// GetTransactionProof get the proof that the transaction hash is in its block
+func (bc *Blockchain) GetTransactionProof(hash []byte) (*TxProof, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	txindex, err := bc.getTxIndex(hash)
+	if err != nil {
+		return nil, err
+	}
+	b, err := bc.getBlockByHeight(txindex.Height)
+	if err != nil {
+		return nil, err
+	}
+	if txindex.Index >= uint64(len(b.Transactions)) {
+		return nil, fmt.Errorf("transaction %x: index %d of %d", hash, txindex.Index, len(b.Transactions))
+	}
+
+	leaves, err := txLeaves(b.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := merkle.New(sha256.New(), leaves).Proof(int(txindex.Index))
+	if err != nil {
+		return nil, err
+	}
+
+	b.Transactions = nil
+	return &TxProof{
+		Header: b,
+		Index:  txindex.Index,
+		Leaf:   leaves[txindex.Index],
+		Proof:  proof,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package blockchain
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPruneKeep is the number of state roots kept when PruneConfig.Keep is 0.
+	DefaultPruneKeep = 128
+	// DefaultPruneInterval is the number of blocks between two prune runs
+	// when PruneConfig.Interval is 0.
+	DefaultPruneInterval = 64
+
+	// minPruneKeep covers New, which rolls the chain back by one block.
+	minPruneKeep = 2
+	// pruneBatch is the number of trie nodes deleted under one lock of the chain.
+	pruneBatch = 1000
+)
+
+// PrunedKey holds the lowest height whose state is still complete, the state
+// of every height below it may be pruned. It is absent if nothing was pruned.
+var PrunedKey = []byte("statePruned")
+
+// prunedBytes reports the bytes of trie nodes deleted by pruning, under
+// /debug/vars of the pprof listener.
+var prunedBytes = expvar.NewInt("state_pruned_bytes")
+
+// PruneConfig selects how much state history the chain keeps. A nil
+// PruneConfig or Archive keeps every state root.
+type PruneConfig struct {
+	Archive bool `yaml:"archive"`
+	// Keep is the number of most recent state roots kept, it bounds the depth
+	// of reorganizations and DeleteBlock.
+	Keep uint64 `yaml:"keep"`
+	// Interval is the number of blocks between two prune runs.
+	Interval uint64 `yaml:"interval"`
+}
+
+func (cfg *PruneConfig) enabled() bool {
+	return cfg != nil && !cfg.Archive
+}
+
+func (cfg *PruneConfig) keep() uint64 {
+	switch {
+	case cfg.Keep == 0:
+		return DefaultPruneKeep
+	case cfg.Keep < minPruneKeep:
+		return minPruneKeep
+	}
+	return cfg.Keep
+}
+
+func (cfg *PruneConfig) interval() uint64 {
+	if cfg.Interval == 0 {
+		return DefaultPruneInterval
+	}
+	return cfg.Interval
+}
+
+// PrunedHeight returns the height stored under PrunedKey, or 0 if the state
+// of db was never pruned.
+func PrunedHeight(db store.DB) (uint64, error) {
+	v, err := db.Get(PrunedKey)
+	if err == store.NotExist {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return miscellaneous.D64func(v)
+}
+
+// maybePrune starts a prune run in the background every interval blocks,
+// unless one is still running. The caller holds bc.mu.
+func (bc *Blockchain) maybePrune(height uint64) {
+	cfg := bc.ChainCfg.Prune
+	if !cfg.enabled() || height%cfg.interval() != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&bc.pruning, 0, 1) {
+		return
+	}
+	bc.background(func() {
+		defer atomic.StoreInt32(&bc.pruning, 0)
+		n, err := bc.PruneState(cfg.keep())
+		if err != nil {
+			logger.Error("failed to prune state", zap.Error(err))
+			return
+		}
+		logger.Info("pruned state", zap.Uint64("height", height), zap.Int64("bytes", n))
+	})
+}
+
+// PruneState deletes the trie nodes that are not reachable from the state
+// roots of the last keep heights and returns the bytes reclaimed. Nodes are
+// marked without holding the chain lock; every batch of deletions takes the
+// lock and marks the roots again first, so nodes written by blocks added in
+// the meantime survive even if they were unreachable when the run started. A
+// run stops early once the chain is closing.
+func (bc *Blockchain) PruneState(keep uint64) (int64, error) {
+	if keep < minPruneKeep {
+		keep = minPruneKeep
+	}
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(bc.db))
+	seen := make(map[common.Hash]struct{})
+
+	bc.mu.RLock()
+	roots, first, err := bc.keptRoots(keep)
+	bc.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	if err := markRoots(sdb, roots, seen); err != nil {
+		return 0, err
+	}
+
+	var (
+		reclaimed int64
+		batch     []common.Hash
+	)
+	itr := bc.db.NewIterator(nil, nil)
+	defer itr.Release()
+	for !bc.closing() && itr.Next() {
+		if len(itr.Key()) != common.HashLength {
+			continue
+		}
+		h := common.BytesToHash(itr.Key())
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		// block hashes have the same length, trie nodes hash to their key
+		if crypto.Keccak256Hash(itr.Value()) != h {
+			continue
+		}
+		if batch = append(batch, h); len(batch) < pruneBatch {
+			continue
+		}
+		n, err := bc.sweep(sdb, keep, batch, seen)
+		reclaimed += n
+		if err != nil {
+			return reclaimed, err
+		}
+		batch = batch[:0]
+	}
+	n, err := bc.sweep(sdb, keep, batch, seen)
+	reclaimed += n
+	if err != nil {
+		return reclaimed, err
+	}
+
+	if err := bc.db.Set(PrunedKey, miscellaneous.E64func(first)); err != nil {
+		return reclaimed, err
+	}
+	return reclaimed, nil
+}
+
+// sweep deletes the nodes of batch that are still unreachable once the roots
+// kept now are marked.
+func (bc *Blockchain) sweep(sdb state.Database, keep uint64, batch []common.Hash, seen map[common.Hash]struct{}) (int64, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	roots, _, err := bc.keptRoots(keep)
+	if err != nil {
+		return 0, err
+	}
+	if err := markRoots(sdb, roots, seen); err != nil {
+		return 0, err
+	}
+
+	var reclaimed int64
+	tx := bc.db.NewTransaction()
+	defer tx.Cancel()
+	for _, h := range batch {
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		v, err := tx.Get(h[:])
+		if err == store.NotExist {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if err := tx.Del(h[:]); err != nil {
+			return 0, err
+		}
+		reclaimed += int64(len(h) + len(v))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	prunedBytes.Add(reclaimed)
+	return reclaimed, nil
+}
+
+// checkRewind fails unless the chain with the tip can be cut back to below
+// height. The state it returns to may be pruned below PrunedKey, and below the
+// last keep heights by a run in progress. The caller holds bc.mu.
+func (bc *Blockchain) checkRewind(height, tip uint64) error {
+	pruned, err := PrunedHeight(bc.db)
+	if err != nil {
+		return err
+	}
+	if cfg := bc.ChainCfg.Prune; cfg.enabled() && tip+1 > cfg.keep() && tip+1-cfg.keep() > pruned {
+		pruned = tip + 1 - cfg.keep()
+	}
+	if pruned > InitHeight && height <= pruned {
+		return fmt.Errorf("the state below %d may be pruned, the first block that can be removed is %d", pruned, pruned+1)
+	}
+	return nil
+}
+
+// keptRoots returns the current state root and the roots of the last keep
+// heights, and the lowest of those heights. The caller holds bc.mu.
+func (bc *Blockchain) keptRoots(keep uint64) ([]common.Hash, uint64, error) {
+	root, err := getSnapRoot(bc.db)
+	if err != nil {
+		return nil, 0, err
+	}
+	tip, err := bc.getMaxBlockHeight()
+	if err != nil {
+		return nil, 0, err
+	}
+	first := uint64(InitHeight)
+	if tip+1 > keep {
+		first = tip + 1 - keep
+	}
+	roots := []common.Hash{root}
+	for h := first; h <= tip; h++ {
+		v, err := bc.db.Get(append(SnapRootPrefix, miscellaneous.E64func(h)...))
+		if err != nil {
+			return nil, 0, fmt.Errorf("state root %d: %w", h, err)
+		}
+		roots = append(roots, common.BytesToHash(v))
+	}
+	return roots, first, nil
+}
+
+// markRoots adds every node reachable from roots to seen. Roots marked
+// before are skipped at their first node.
+func markRoots(sdb state.Database, roots []common.Hash, seen map[common.Hash]struct{}) error {
+	nop := func(common.Hash, []byte) error { return nil }
+	for _, root := range roots {
+		if err := WalkState(sdb, root, seen, nop, nop); err != nil {
+			return fmt.Errorf("state %x: %w", root, err)
+		}
+	}
+	return nil
+}
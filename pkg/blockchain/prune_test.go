@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"testing"
+
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store/bg/bgdb"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneState(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+
+	// New rolls the tip back to 3
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	before := prunedBytes.Value()
+	n, err := bc.PruneState(2)
+	assert.NoError(err)
+	assert.NotZero(n)
+	assert.Equal(before+n, prunedBytes.Value())
+
+	pruned, err := PrunedHeight(db)
+	assert.NoError(err)
+	assert.Equal(uint64(2), pruned)
+
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(db))
+	for h := uint64(1); h <= 3; h++ {
+		root, err := db.Get(append(SnapRootPrefix, miscellaneous.E64func(h)...))
+		assert.NoError(err)
+		_, err = state.New(common.BytesToHash(root), sdb, nil)
+		if h < pruned {
+			assert.Error(err, "height %d", h)
+		} else {
+			assert.NoError(err, "height %d", h)
+		}
+	}
+	problems, err := Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Empty(problems)
+
+	// the chain grows on from the pruned state, a second run has less to do
+	miner := common.HexToAddress("0x01")
+	b, err := bc.NewBlock(nil, &miner)
+	assert.NoError(err)
+	assert.NoError(bc.ExecuteBlock(b))
+	assert.NoError(b.SetHash())
+	assert.NoError(bc.AddBlock(b))
+	again, err := bc.PruneState(2)
+	assert.NoError(err)
+	assert.Less(again, n)
+	pruned, err = PrunedHeight(db)
+	assert.NoError(err)
+	assert.Equal(uint64(3), pruned)
+
+	// the chain can not be cut back to a pruned state
+	assert.Error(bc.DeleteBlock(3))
+	tip, err := bc.GetMaxBlockHeight()
+	assert.NoError(err)
+	assert.Equal(uint64(4), tip)
+	assert.NoError(bc.DeleteBlock(4))
+	tip, err = bc.GetMaxBlockHeight()
+	assert.NoError(err)
+	assert.Equal(uint64(3), tip)
+}
@@ -0,0 +1,182 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/merkle"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	evmtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReceiptPrefix + block hash -> receipts of the transactions of the block
+var ReceiptPrefix = []byte("receipts")
+
+func receiptKey(hash []byte) []byte {
+	return append(append([]byte{}, ReceiptPrefix...), hash...)
+}
+
+// transferReceipt returns the receipt of a transaction that is not run by the evm
+func transferReceipt(tx *transaction.FinishedTransaction) *transaction.Receipt {
+	r := transaction.NewReceipt(tx.Hash(), transaction.ReceiptStatusSuccessful)
+	r.GasUsed.Set(tx.GasUsed)
+	return r
+}
+
+// deriveReceipts fills in the cumulative gas used and the blooms of the
+// receipts of an executed block, and returns their root
+func deriveReceipts(receipts []*transaction.Receipt) ([]byte, error) {
+	cumulative := new(big.Int)
+	for _, r := range receipts {
+		cumulative.Add(cumulative, r.GasUsed)
+		r.CumulativeGasUsed = new(big.Int).Set(cumulative)
+		r.Bloom = evmtypes.BytesToBloom(evmtypes.LogsBloom(r.Logs))
+	}
+	return receiptsRoot(receipts)
+}
+
+// receiptsRoot returns the Merkle root of the receipts of a block, the
+// zero hash if there are none
+func receiptsRoot(receipts []*transaction.Receipt) ([]byte, error) {
+	if len(receipts) == 0 {
+		return common.Hash{}.Bytes(), nil
+	}
+	list := make([][]byte, 0, len(receipts))
+	for _, r := range receipts {
+		data, err := rlp.EncodeToBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, data)
+	}
+	return merkle.New(sha256.New(), list).GetMtHash(), nil
+}
+
+// setReceipts stores the receipts of the executed block b, made by
+// deriveReceipts, with the logs bloom of the block
+func setReceipts(DBTransaction store.Transaction, b *block.Block, receipts []*transaction.Receipt) error {
+	var logs []*evmtypes.Log
+	for _, r := range receipts {
+		logs = append(logs, r.Logs...)
+	}
+	data, err := transaction.EncodeReceipts(receipts)
+	if err != nil {
+		return err
+	}
+	if err := DBTransaction.Set(receiptKey(b.Hash), data); err != nil {
+		return err
+	}
+	return DBTransaction.Set(bloomKey(b.Hash), evmtypes.LogsBloom(logs))
+}
+
+// deleteReceipts removes the receipts and the logs bloom of the block with
+// the hash, which is taken off the chain
+func deleteReceipts(DBTransaction store.Transaction, hash []byte) error {
+	if err := DBTransaction.Del(receiptKey(hash)); err != nil {
+		return err
+	}
+	return DBTransaction.Del(bloomKey(hash))
+}
+
+// blockReceipts returns the receipts of the block b
+func (bc *Blockchain) blockReceipts(b *block.Block) ([]*transaction.Receipt, error) {
+	data, err := readReceipts(bc.db, bc.cdb, b)
+	if err == nil {
+		return transaction.DecodeReceipts(data)
+	} else if err != store.NotExist {
+		return nil, err
+	}
+	return inputReceipts(b)
+}
+
+// inputReceipts derives the receipts of a block stored before receipts were
+// kept, or not executed by this node, from the results that the contract
+// transactions of such blocks carry in their input.
+func inputReceipts(b *block.Block) ([]*transaction.Receipt, error) {
+	receipts := make([]*transaction.Receipt, 0, len(b.Transactions))
+	cumulative := new(big.Int)
+	for _, tx := range b.Transactions {
+		r := transaction.NewReceipt(tx.Hash(), transaction.ReceiptStatusSuccessful)
+		if tx.IsEvmContractTransaction() && len(tx.Input) > 0 {
+			evmC, err := transaction.DecodeEvmData(tx.Input)
+			if err != nil {
+				return nil, fmt.Errorf("tx %s: %v", tx.HashToString(), err)
+			}
+			if !evmC.Status {
+				r.Status = transaction.ReceiptStatusFailed
+			}
+			if evmC.Operation == CREATECONTRACT || evmC.Operation == "Create" {
+				r.ContractAddress = evmC.ContractAddr
+			}
+			r.Logs = evmC.Logs
+		}
+		if tx.GasUsed != nil {
+			r.GasUsed.Set(tx.GasUsed)
+		}
+		cumulative.Add(cumulative, r.GasUsed)
+		r.CumulativeGasUsed.Set(cumulative)
+		r.Bloom = evmtypes.BytesToBloom(evmtypes.LogsBloom(r.Logs))
+		receipts = append(receipts, r)
+	}
+	return receipts, nil
+}
+
+// GetReceipt get the receipt of the transaction hash
+func (bc *Blockchain) GetReceipt(hash []byte) (*transaction.Receipt, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	txindex, err := bc.getTxIndex(hash)
+	if err != nil {
+		return nil, err
+	}
+	b, err := bc.blockAt(txindex.Height)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := bc.blockReceipts(b)
+	if err != nil {
+		return nil, err
+	}
+	if txindex.Index >= uint64(len(receipts)) || !bytes.Equal(receipts[txindex.Index].TxHash, hash) {
+		return nil, fmt.Errorf("no receipt of transaction %x in block %d", hash, b.Height)
+	}
+	return receipts[txindex.Index], nil
+}
+
+// GetBlockReceipts get the receipts of the transactions of the block with the hash
+func (bc *Blockchain) GetBlockReceipts(hash []byte) ([]*transaction.Receipt, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	data, err := bc.readBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	b, err := block.Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	return bc.blockReceipts(b)
+}
+
+// revertReason returns the reason the contract reverted with, or the error
+// that stopped the evm
+func revertReason(ret []byte, err error) string {
+	if errors.Is(err, vm.ErrExecutionReverted) {
+		if reason, uerr := abi.UnpackRevert(ret); uerr == nil {
+			return reason
+		}
+	}
+	return err.Error()
+}
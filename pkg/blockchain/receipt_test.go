@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	evmtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceipts(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+
+	for h := uint64(0); h <= 3; h++ {
+		b, err := bc.blockAt(h)
+		assert.NoError(err)
+		receipts, err := bc.GetBlockReceipts(b.Hash)
+		assert.NoError(err)
+		assert.Len(receipts, len(b.Transactions))
+
+		cumulative := new(big.Int)
+		for i, tx := range b.Transactions {
+			cumulative.Add(cumulative, tx.GasUsed)
+			r, err := bc.GetReceipt(tx.Hash())
+			assert.NoError(err)
+			assert.Equal(receipts[i], r)
+			assert.Equal(tx.Hash(), r.TxHash)
+			assert.Equal(transaction.ReceiptStatusSuccessful, r.Status)
+			assert.Equal(tx.GasUsed, r.GasUsed)
+			assert.Equal(cumulative, r.CumulativeGasUsed)
+			assert.Empty(r.Logs)
+		}
+	}
+
+	_, err = bc.GetReceipt(make([]byte, 32))
+	assert.Error(err)
+
+	// they go with the block
+	height, err := bc.GetMaxBlockHeight()
+	assert.NoError(err)
+	b, err := bc.blockAt(height)
+	assert.NoError(err)
+	assert.NoError(bc.DeleteBlock(height))
+	_, err = db.Get(receiptKey(b.Hash))
+	assert.Equal(store.NotExist, err)
+	_, err = db.Get(bloomKey(b.Hash))
+	assert.Equal(store.NotExist, err)
+}
+
+func TestInputReceipts(t *testing.T) {
+	assert := assert.New(t)
+	from, to := common.HexToAddress("0x02"), common.Address{}
+	contract := common.HexToAddress("0x03")
+	log := &evmtypes.Log{Address: contract, Topics: []common.Hash{common.HexToHash("0x04")}, Data: []byte{5}}
+
+	evmTx := func(nonce uint64, evmC *transaction.EvmContract) *transaction.FinishedTransaction {
+		input, err := transaction.EncodeEvmData(evmC)
+		assert.NoError(err)
+		st := &transaction.SignedTransaction{Transaction: transaction.Transaction{
+			From: &from, To: &to, Amount: new(big.Int), Nonce: nonce,
+			GasLimit: big.NewInt(1), GasFeeCap: big.NewInt(1), GasPrice: big.NewInt(1),
+			Type: transaction.EvmContractTransaction, Input: input,
+		}}
+		return transaction.NewFinishedTransaction(st, big.NewInt(10), 1)
+	}
+	b := &block.Block{Height: 1, Transactions: []*transaction.FinishedTransaction{
+		evmTx(0, &transaction.EvmContract{Operation: CREATECONTRACT, ContractAddr: contract, Status: true, Logs: []*evmtypes.Log{log}}),
+		evmTx(1, &transaction.EvmContract{Operation: CALLCONTRACT, ContractAddr: contract}),
+	}}
+
+	receipts, err := inputReceipts(b)
+	assert.NoError(err)
+	assert.Len(receipts, 2)
+	assert.Equal(transaction.ReceiptStatusSuccessful, receipts[0].Status)
+	assert.Equal(contract, receipts[0].ContractAddress)
+	assert.Len(receipts[0].Logs, 1)
+	assert.True(receipts[0].Bloom.Test(contract.Bytes()))
+	assert.Equal(transaction.ReceiptStatusFailed, receipts[1].Status)
+	assert.Equal(common.Address{}, receipts[1].ContractAddress)
+	assert.Equal(big.NewInt(20), receipts[1].CumulativeGasUsed)
+
+	data, err := transaction.EncodeReceipts(receipts)
+	assert.NoError(err)
+	decoded, err := transaction.DecodeReceipts(data)
+	assert.NoError(err)
+	assert.Equal(receipts, decoded)
+}
+
+func TestRevertReason(t *testing.T) {
+	assert := assert.New(t)
+
+	reason := "not enough"
+	ret := append([]byte{}, crypto.Keccak256([]byte("Error(string)"))[:4]...)
+	ret = append(ret, common.LeftPadBytes([]byte{0x20}, 32)...)
+	ret = append(ret, common.LeftPadBytes([]byte{byte(len(reason))}, 32)...)
+	ret = append(ret, common.RightPadBytes([]byte(reason), 32)...)
+	assert.Equal(reason, revertReason(ret, vm.ErrExecutionReverted))
+	assert.Equal(vm.ErrExecutionReverted.Error(), revertReason(nil, vm.ErrExecutionReverted))
+	assert.Equal("out of gas", revertReason(ret, errors.New("out of gas")))
+}
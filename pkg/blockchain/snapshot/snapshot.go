@@ -0,0 +1,422 @@
+// Package snapshot exports the state of a chain at one height together with
+// the block headers up to it, and bootstraps an empty database from such an
+// export so a new node only has to sync the blocks above that height.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"metechain/pkg/block"
+	"metechain/pkg/blockchain"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Version is the snapshot format version written by this package.
+const Version = 1
+
+const (
+	kindEnd byte = iota
+	kindChain
+	kindNode
+	kindCode
+)
+
+const (
+	batchKeys  = 1000
+	batchBytes = 4 << 20
+	maxRecord  = 64 << 20
+)
+
+var magic = []byte("metechain-state")
+
+var (
+	ErrNotSnapshot = errors.New("snapshot: not a state snapshot")
+	ErrVersion     = errors.New("snapshot: unsupported version")
+	ErrNotEmpty    = errors.New("snapshot: database already holds a chain")
+	ErrRoot        = errors.New("snapshot: state root does not match the block")
+)
+
+// Header starts every snapshot.
+type Header struct {
+	Version uint32
+	Height  uint64
+	Root    common.Hash
+}
+
+// Stats counts what a snapshot holds.
+type Stats struct {
+	Blocks int
+	Nodes  int
+	Codes  int
+	Bytes  int64
+}
+
+// Export writes the state at height, the state of the block before it and
+// the headers of blocks 0 to height from db to w. Transactions are left out
+// of every block but genesis. A height of 0 exports the tip. Blocks moved to a
+// freezer are read from ancients, which may be nil if there are none.
+func Export(db store.DB, ancients blockchain.AncientReader, w io.Writer, height uint64) (Stats, error) {
+	var stats Stats
+	tip, err := getHeight(db)
+	if err != nil {
+		return stats, err
+	}
+	if height == 0 {
+		height = tip
+	}
+	if height < 1 || height > tip {
+		return stats, fmt.Errorf("snapshot: height %d is not in 1-%d", height, tip)
+	}
+	root, err := getRoot(db, height)
+	if err != nil {
+		return stats, err
+	}
+	parentRoot, err := getRoot(db, height-1)
+	if err != nil {
+		return stats, err
+	}
+
+	sw := &writer{w: bufio.NewWriter(w)}
+	sw.header(Header{Version: Version, Height: height, Root: root})
+
+	for h := uint64(0); h <= height; h++ {
+		k := append(blockchain.HeightPrefix, miscellaneous.E64func(h)...)
+		hash, err := db.Get(k)
+		if err != nil {
+			return stats, fmt.Errorf("snapshot: block hash %d: %w", h, err)
+		}
+		data, err := blockchain.ReadBlock(db, ancients, hash)
+		if err != nil {
+			return stats, fmt.Errorf("snapshot: block %d: %w", h, err)
+		}
+		if h != blockchain.InitHeight {
+			if data, err = stripTransactions(data); err != nil {
+				return stats, fmt.Errorf("snapshot: block %d: %w", h, err)
+			}
+		}
+		sw.record(kindChain, k, hash)
+		sw.record(kindChain, hash, data)
+		stats.Blocks++
+	}
+	for _, h := range []uint64{height - 1, height} {
+		k := append(blockchain.SnapRootPrefix, miscellaneous.E64func(h)...)
+		v, err := db.Get(k)
+		if err != nil {
+			return stats, err
+		}
+		sw.record(kindChain, k, v)
+	}
+
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(db))
+	seen := make(map[common.Hash]struct{})
+	for _, r := range []common.Hash{root, parentRoot} {
+		err := blockchain.WalkState(sdb, r, seen,
+			func(hash common.Hash, blob []byte) error {
+				stats.Nodes++
+				sw.record(kindNode, hash[:], blob)
+				return sw.err
+			},
+			func(hash common.Hash, code []byte) error {
+				stats.Codes++
+				sw.record(kindCode, hash[:], code)
+				return sw.err
+			})
+		if err != nil {
+			return stats, fmt.Errorf("snapshot: state %x: %w", r, err)
+		}
+	}
+
+	sw.end()
+	stats.Bytes = sw.n
+	return stats, sw.err
+}
+
+// Import fills the empty db from the snapshot in r. The state root of the
+// snapshot must match the SnapRoot of its block, and the headers must link up,
+// before HeightKey and SnapRootKey are written to make the chain visible.
+func Import(db store.DB, r io.Reader, progress func(Stats)) (Header, error) {
+	if _, err := db.Get(blockchain.HeightKey); err == nil {
+		return Header{}, ErrNotEmpty
+	} else if err != store.NotExist {
+		return Header{}, err
+	}
+
+	sr := &reader{r: bufio.NewReader(r)}
+	hdr, err := sr.header()
+	if err != nil {
+		return hdr, err
+	}
+
+	var (
+		stats Stats
+		n     int
+		size  int
+		tx    = db.NewTransaction()
+	)
+	defer func() { tx.Cancel() }()
+	for {
+		kind, k, v, err := sr.record()
+		if err != nil {
+			return hdr, err
+		}
+		if kind == kindEnd {
+			break
+		}
+		switch kind {
+		case kindChain:
+			if bytes.Equal(k, blockchain.HeightKey) || bytes.Equal(k, blockchain.SnapRootKey) {
+				return hdr, fmt.Errorf("snapshot: unexpected key %q", k)
+			}
+			if bytes.HasPrefix(k, blockchain.HeightPrefix) {
+				stats.Blocks++
+			}
+		case kindNode, kindCode:
+			if crypto.Keccak256Hash(v) != common.BytesToHash(k) {
+				return hdr, fmt.Errorf("snapshot: %x does not hash to its key", k)
+			}
+			if kind == kindNode {
+				stats.Nodes++
+			} else {
+				stats.Codes++
+				k = append(append([]byte{}, rawdb.CodePrefix...), k...)
+			}
+		default:
+			return hdr, fmt.Errorf("snapshot: unknown record kind %d", kind)
+		}
+		if err := tx.Set(k, v); err != nil {
+			return hdr, err
+		}
+		n, size = n+1, size+len(k)+len(v)
+		stats.Bytes += int64(len(k) + len(v))
+		if n < batchKeys && size < batchBytes {
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			return hdr, err
+		}
+		n, size = 0, 0
+		tx = db.NewTransaction()
+		if progress != nil {
+			progress(stats)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return hdr, err
+	}
+	if progress != nil {
+		progress(stats)
+	}
+
+	if err := checkImport(db, hdr); err != nil {
+		return hdr, err
+	}
+
+	tx = db.NewTransaction()
+	if err := tx.Set(blockchain.SnapRootKey, hdr.Root.Bytes()); err != nil {
+		return hdr, err
+	}
+	if err := tx.Set(blockchain.HeightKey, miscellaneous.E64func(hdr.Height)); err != nil {
+		return hdr, err
+	}
+	if err := tx.Commit(); err != nil {
+		return hdr, err
+	}
+	return hdr, db.Sync()
+}
+
+// checkImport verifies what Import wrote before the chain is made visible.
+func checkImport(db store.DB, hdr Header) error {
+	var prev []byte
+	for h := uint64(0); h <= hdr.Height; h++ {
+		hash, err := db.Get(append(blockchain.HeightPrefix, miscellaneous.E64func(h)...))
+		if err != nil {
+			return fmt.Errorf("snapshot: block hash %d: %w", h, err)
+		}
+		data, err := db.Get(hash)
+		if err != nil {
+			return fmt.Errorf("snapshot: block %d: %w", h, err)
+		}
+		b, err := block.Deserialize(data)
+		if err != nil {
+			return fmt.Errorf("snapshot: block %d: %w", h, err)
+		}
+		if b.Height != h || !bytes.Equal(b.Hash, hash) || (prev != nil && !bytes.Equal(b.PrevHash, prev)) {
+			return fmt.Errorf("snapshot: block %d does not link up", h)
+		}
+		prev = hash
+		if h == hdr.Height && !bytes.Equal(b.SnapRoot, hdr.Root.Bytes()) {
+			return ErrRoot
+		}
+	}
+	root, err := getRoot(db, hdr.Height)
+	if err != nil {
+		return err
+	}
+	if root != hdr.Root {
+		return ErrRoot
+	}
+	parentRoot, err := getRoot(db, hdr.Height-1)
+	if err != nil {
+		return err
+	}
+
+	// every node of both states must be there
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(db))
+	seen := make(map[common.Hash]struct{})
+	nop := func(common.Hash, []byte) error { return nil }
+	for _, r := range []common.Hash{root, parentRoot} {
+		if err := blockchain.WalkState(sdb, r, seen, nop, nop); err != nil {
+			return fmt.Errorf("snapshot: state %x: %w", r, err)
+		}
+	}
+	return nil
+}
+
+func getHeight(db store.DB) (uint64, error) {
+	hb, err := db.Get(blockchain.HeightKey)
+	if err != nil {
+		return 0, err
+	}
+	return miscellaneous.D64func(hb)
+}
+
+func getRoot(db store.DB, h uint64) (common.Hash, error) {
+	v, err := db.Get(append(blockchain.SnapRootPrefix, miscellaneous.E64func(h)...))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("snapshot: state root %d: %w", h, err)
+	}
+	return common.BytesToHash(v), nil
+}
+
+func stripTransactions(data []byte) ([]byte, error) {
+	b, err := block.Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	b.Transactions = nil
+	return b.Serialize()
+}
+
+// writer keeps the first error, so Export checks it once at the end.
+type writer struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (sw *writer) write(p []byte) {
+	if sw.err != nil {
+		return
+	}
+	var n int
+	n, sw.err = sw.w.Write(p)
+	sw.n += int64(n)
+}
+
+func (sw *writer) bytes(p []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(p)))
+	sw.write(n[:])
+	sw.write(p)
+}
+
+func (sw *writer) header(hdr Header) {
+	var buf [12]byte
+	binary.BigEndian.PutUint32(buf[:4], hdr.Version)
+	binary.BigEndian.PutUint64(buf[4:], hdr.Height)
+	sw.write(magic)
+	sw.write(buf[:])
+	sw.write(hdr.Root[:])
+}
+
+func (sw *writer) record(kind byte, k, v []byte) {
+	sw.write([]byte{kind})
+	sw.bytes(k)
+	sw.bytes(v)
+}
+
+func (sw *writer) end() {
+	sw.write([]byte{kindEnd})
+	if sw.err == nil {
+		sw.err = sw.w.Flush()
+	}
+}
+
+type reader struct {
+	r *bufio.Reader
+}
+
+func (sr *reader) header() (Header, error) {
+	var hdr Header
+	buf := make([]byte, len(magic)+12+common.HashLength)
+	if _, err := io.ReadFull(sr.r, buf); err != nil || !bytes.Equal(buf[:len(magic)], magic) {
+		return hdr, ErrNotSnapshot
+	}
+	buf = buf[len(magic):]
+	hdr.Version = binary.BigEndian.Uint32(buf[:4])
+	if hdr.Version != Version {
+		return hdr, fmt.Errorf("%w %d", ErrVersion, hdr.Version)
+	}
+	hdr.Height = binary.BigEndian.Uint64(buf[4:12])
+	hdr.Root = common.BytesToHash(buf[12:])
+	if hdr.Height < 1 {
+		return hdr, ErrNotSnapshot
+	}
+	return hdr, nil
+}
+
+func (sr *reader) record() (byte, []byte, []byte, error) {
+	kind, err := sr.r.ReadByte()
+	if err != nil {
+		return 0, nil, nil, unexpected(err)
+	}
+	if kind == kindEnd {
+		return kind, nil, nil, nil
+	}
+	k, err := sr.bytes()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	v, err := sr.bytes()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return kind, k, v, nil
+}
+
+func (sr *reader) bytes() ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(sr.r, n[:]); err != nil {
+		return nil, unexpected(err)
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > maxRecord {
+		return nil, fmt.Errorf("snapshot: record of %d bytes", size)
+	}
+	p := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, p); err != nil {
+		return nil, unexpected(err)
+	}
+	return p, nil
+}
+
+// unexpected turns io.EOF into io.ErrUnexpectedEOF, a snapshot ends with
+// kindEnd and never at a record boundary.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/blockchain"
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+	"metechain/pkg/storage/store/mem"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "snapshot")
+	if err != nil {
+		panic(err)
+	}
+	cfg := logger.DefaultConfig()
+	cfg.FileName = filepath.Join(dir, "debug.log")
+	if err := logger.InitLogger(cfg); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var (
+	chainOnce sync.Once
+	chainDB   store.DB
+	chainCfg  *blockchain.ChainConfig
+	blocks    []*block.Block
+)
+
+// testChain returns a copy of a chain with four blocks on top of genesis.
+// The genesis block can only be created once per process.
+func testChain(t *testing.T) store.DB {
+	chainOnce.Do(func() {
+		assert := assert.New(t)
+		miner := common.HexToAddress("0x01")
+		chainCfg = &blockchain.ChainConfig{ChainId: 1, GasLimit: blockchain.MINGASLIMIT, GasPrice: 1, Miner: &miner, Version2Height: new(uint64), Version3Height: new(uint64)}
+		chainDB = mem.New()
+		assert.NoError(chainDB.Set(blockchain.SnapRootKey, types.EmptyRootHash.Bytes()))
+		bc, err := blockchain.New(chainDB, chainCfg)
+		assert.NoError(err)
+		for i := 0; i < 4; i++ {
+			b, err := bc.NewBlock(nil, &miner)
+			assert.NoError(err)
+			assert.NoError(bc.ExecuteBlock(b))
+			assert.NoError(b.SetHash())
+			assert.NoError(bc.AddBlock(b))
+			blocks = append(blocks, b)
+		}
+	})
+
+	db := mem.New()
+	itr := chainDB.NewIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		assert.NoError(t, db.Set(itr.Key(), itr.Value()))
+	}
+	return db
+}
+
+func export(t *testing.T, height uint64) []byte {
+	var buf bytes.Buffer
+	stats, err := Export(testChain(t), nil, &buf, height)
+	assert.NoError(t, err)
+	assert.Equal(t, int(height)+1, stats.Blocks)
+	assert.NotZero(t, stats.Nodes)
+	assert.Equal(t, int64(buf.Len()), stats.Bytes)
+	return buf.Bytes()
+}
+
+func TestExportImport(t *testing.T) {
+	assert := assert.New(t)
+	data := export(t, 3)
+
+	db := mem.New()
+	var last Stats
+	hdr, err := Import(db, bytes.NewReader(data), func(s Stats) { last = s })
+	assert.NoError(err)
+	assert.Equal(uint64(3), hdr.Height)
+	assert.Equal(common.BytesToHash(blocks[2].SnapRoot), hdr.Root)
+	assert.Equal(4, last.Blocks)
+
+	root, err := db.Get(blockchain.SnapRootKey)
+	assert.NoError(err)
+	assert.Equal(hdr.Root.Bytes(), root)
+	problems, err := blockchain.Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Empty(problems)
+
+	// New rolls back to height 2, the node then syncs the blocks above it
+	bc, err := blockchain.New(db, chainCfg)
+	assert.NoError(err)
+	for _, b := range blocks[2:] {
+		assert.NoError(bc.AddBlock(b))
+	}
+	h, err := bc.GetMaxBlockHeight()
+	assert.NoError(err)
+	assert.Equal(uint64(4), h)
+	miner := common.HexToAddress("0x01")
+	balance, err := bc.GetBalance(&miner)
+	assert.NoError(err)
+	assert.NotZero(balance.Sign())
+}
+
+func TestImportNotEmpty(t *testing.T) {
+	_, err := Import(testChain(t), bytes.NewReader(export(t, 2)), nil)
+	assert.Equal(t, ErrNotEmpty, err)
+}
+
+func TestImportCorrupt(t *testing.T) {
+	assert := assert.New(t)
+	data := export(t, 2)
+
+	_, err := Import(mem.New(), bytes.NewReader(data[:len(data)-10]), nil)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+
+	_, err = Import(mem.New(), bytes.NewReader([]byte("metechain-block")), nil)
+	assert.Equal(ErrNotSnapshot, err)
+
+	// the last byte before kindEnd belongs to a trie node
+	bad := append([]byte{}, data...)
+	bad[len(bad)-2] ^= 0xff
+	db := mem.New()
+	_, err = Import(db, bytes.NewReader(bad), nil)
+	assert.Error(err)
+	_, err = db.Get(blockchain.HeightKey)
+	assert.Equal(store.NotExist, err)
+}
+
+func TestExportMissingState(t *testing.T) {
+	assert := assert.New(t)
+	db := testChain(t)
+
+	root, err := db.Get(append(blockchain.SnapRootPrefix, miscellaneous.E64func(2)...))
+	assert.NoError(err)
+	assert.NoError(db.Del(root))
+	_, err = Export(db, nil, io.Discard, 3)
+	assert.Error(err)
+}
+
+func TestExportFrozen(t *testing.T) {
+	assert := assert.New(t)
+	want := export(t, 3)
+	db := testChain(t)
+
+	// move blocks 0 and 1 to a freezer the way the chain does
+	f, err := bgdb.NewFreezer(t.TempDir())
+	assert.NoError(err)
+	defer f.Close()
+	for h := uint64(0); h < 2; h++ {
+		hash, err := db.Get(append(blockchain.HeightPrefix, miscellaneous.E64func(h)...))
+		assert.NoError(err)
+		data, err := db.Get(hash)
+		assert.NoError(err)
+		assert.NoError(f.AppendAncient(h, hash, nil, data, nil, nil))
+		assert.NoError(db.Set(hash, miscellaneous.E64func(h)))
+	}
+	assert.NoError(db.Set(blockchain.FrozenKey, miscellaneous.E64func(2)))
+
+	_, err = Export(db, nil, io.Discard, 3)
+	assert.Error(err)
+	var buf bytes.Buffer
+	_, err = Export(db, f, &buf, 3)
+	assert.NoError(err)
+	assert.Equal(want, buf.Bytes())
+}
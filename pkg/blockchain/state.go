@@ -177,11 +177,11 @@ func (bc *Blockchain) GetBalance(address *common.Address) (*big.Int, error) {
 	return bc.getBalance(*address)
 }
 
-// getBalance get the balance of the address
+// getBalance get the balance of the address, a copy callers can change
 func (bc *Blockchain) getBalance(address common.Address) (*big.Int, error) {
 	coAddr := address
 	balance := bc.sdb.GetBalance(coAddr)
-	return balance, nil
+	return new(big.Int).Set(balance), nil
 }
 
 // GetNonce get the nonce of the address
@@ -194,16 +194,19 @@ func (bc *Blockchain) GetNonce(address *common.Address) (uint64, error) {
 
 // getNonce get the nonce of the address
 func (bc *Blockchain) getNonce(address common.Address) (uint64, error) {
-	var initNonce uint64 = 1
+	return stateNonce(bc.sdb, address), nil
+}
 
-	coAddr := address
+// stateNonce get the nonce of the address in sdb, accounts start at nonce 1
+func stateNonce(sdb *state.StateDB, address common.Address) uint64 {
+	var initNonce uint64 = 1
 
-	n := bc.sdb.GetNonce(coAddr)
+	n := sdb.GetNonce(address)
 	if n == 0 {
-		return initNonce, nil
+		return initNonce
 	}
 
-	return n, nil
+	return n
 }
 
 func getSnapRootLock(db store.DB) (common.Hash, error) {
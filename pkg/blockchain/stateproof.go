@@ -0,0 +1,157 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// AccountProof is the state of an account in a block together with the
+// Merkle-Patricia proofs that lead to it from the SnapRoot of the block, as
+// eth_getProof returns it.
+type AccountProof struct {
+	Address      common.Address
+	AccountProof [][]byte
+	Balance      *big.Int
+	CodeHash     common.Hash
+	Nonce        uint64
+	StorageHash  common.Hash
+	StorageProof []StorageProof
+}
+
+// StorageProof is the value of a storage slot and the proof that leads to it
+// from the StorageHash of its account.
+type StorageProof struct {
+	Key   common.Hash
+	Value *big.Int
+	Proof [][]byte
+}
+
+// GetProof get the account and storage proofs of the address at the block height
+func (bc *Blockchain) GetProof(address common.Address, keys []common.Hash, height uint64) (*AccountProof, error) {
+	// the read lock keeps the pruner away from the trie while it is proved
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	st, _, err := bc.stateAt(BlockRef{Height: height})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &AccountProof{
+		Address:      address,
+		Balance:      st.GetBalance(address),
+		CodeHash:     st.GetCodeHash(address),
+		Nonce:        st.GetNonce(address),
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: make([]StorageProof, len(keys)),
+	}
+	if p.AccountProof, err = st.GetProof(address); err != nil {
+		return nil, err
+	}
+
+	storage := st.StorageTrie(address)
+	if storage == nil {
+		// the account does not exist, GetCodeHash returned the zero hash
+		p.CodeHash = common.BytesToHash(emptyCodeHash)
+	} else {
+		p.StorageHash = storage.Hash()
+	}
+	for i, key := range keys {
+		p.StorageProof[i] = StorageProof{Key: key, Value: new(big.Int), Proof: [][]byte{}}
+		if storage == nil {
+			continue
+		}
+		proof, err := st.GetStorageProof(address, key)
+		if err != nil {
+			return nil, err
+		}
+		p.StorageProof[i].Value = st.GetState(address, key).Big()
+		p.StorageProof[i].Proof = proof
+	}
+	if err := st.Error(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// VerifyAccountProof checks that the account and every storage slot of p are
+// proved by the state root, which is the SnapRoot of the block p was taken
+// at. It does not trust anything in p but the proofs.
+func VerifyAccountProof(root common.Hash, p *AccountProof) error {
+	value, err := trie.VerifyProof(root, crypto.Keccak256(p.Address.Bytes()), proofDB(p.AccountProof))
+	if err != nil {
+		return fmt.Errorf("account %s: %v", p.Address, err)
+	}
+
+	acc := state.Account{Balance: new(big.Int), Root: types.EmptyRootHash, CodeHash: emptyCodeHash}
+	if value != nil {
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			return fmt.Errorf("account %s: %v", p.Address, err)
+		}
+	}
+	switch {
+	case p.Balance == nil || acc.Balance.Cmp(p.Balance) != 0:
+		return fmt.Errorf("account %s: balance %v, proved %v", p.Address, p.Balance, acc.Balance)
+	case acc.Nonce != p.Nonce:
+		return fmt.Errorf("account %s: nonce %d, proved %d", p.Address, p.Nonce, acc.Nonce)
+	case acc.Root != p.StorageHash:
+		return fmt.Errorf("account %s: storage hash %s, proved %s", p.Address, p.StorageHash, acc.Root)
+	case !bytes.Equal(acc.CodeHash, p.CodeHash.Bytes()):
+		return fmt.Errorf("account %s: code hash %s, proved %x", p.Address, p.CodeHash, acc.CodeHash)
+	}
+
+	for _, sp := range p.StorageProof {
+		if err := verifyStorageProof(p.StorageHash, sp); err != nil {
+			return fmt.Errorf("account %s: %v", p.Address, err)
+		}
+	}
+	return nil
+}
+
+func verifyStorageProof(root common.Hash, sp StorageProof) error {
+	if sp.Value == nil {
+		return fmt.Errorf("slot %s: no value", sp.Key)
+	}
+	// an empty storage trie has nothing to prove, every slot is zero
+	if root == types.EmptyRootHash && len(sp.Proof) == 0 {
+		if sp.Value.Sign() != 0 {
+			return fmt.Errorf("slot %s: value %v in empty storage", sp.Key, sp.Value)
+		}
+		return nil
+	}
+	value, err := trie.VerifyProof(root, crypto.Keccak256(sp.Key.Bytes()), proofDB(sp.Proof))
+	if err != nil {
+		return fmt.Errorf("slot %s: %v", sp.Key, err)
+	}
+	proved := new(big.Int)
+	if value != nil {
+		var content []byte
+		if err := rlp.DecodeBytes(value, &content); err != nil {
+			return fmt.Errorf("slot %s: %v", sp.Key, err)
+		}
+		proved.SetBytes(content)
+	}
+	if proved.Cmp(sp.Value) != 0 {
+		return fmt.Errorf("slot %s: value %v, proved %v", sp.Key, sp.Value, proved)
+	}
+	return nil
+}
+
+// proofDB indexes the nodes of a proof by their hash, as trie.VerifyProof
+// looks them up.
+func proofDB(proof [][]byte) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProof(t *testing.T) {
+	assert := assert.New(t)
+	bc, err := New(newTestChain(t), chainCfg)
+	assert.NoError(err)
+
+	b, err := bc.GetBlockByHeight(2)
+	assert.NoError(err)
+	root := common.BytesToHash(b.SnapRoot)
+	key := common.HexToHash("0x01")
+
+	p, err := bc.GetProof(*chainCfg.Miner, []common.Hash{key}, 2)
+	assert.NoError(err)
+	assert.NotEmpty(p.AccountProof)
+	assert.Positive(p.Balance.Sign())
+	assert.NoError(VerifyAccountProof(root, p))
+
+	// the balance of the miner grows with every block
+	tip, err := bc.GetProof(*chainCfg.Miner, nil, 3)
+	assert.NoError(err)
+	assert.Equal(1, tip.Balance.Cmp(p.Balance))
+	assert.Error(VerifyAccountProof(root, tip))
+
+	p.Balance = new(big.Int).Add(p.Balance, big.NewInt(1))
+	assert.Error(VerifyAccountProof(root, p))
+
+	// an account that does not exist is proved to be empty
+	p, err = bc.GetProof(common.HexToAddress("0xdead"), []common.Hash{key}, 2)
+	assert.NoError(err)
+	assert.Zero(p.Balance.Sign())
+	assert.Zero(p.StorageProof[0].Value.Sign())
+	assert.NoError(VerifyAccountProof(root, p))
+	p.Nonce = 1
+	assert.Error(VerifyAccountProof(root, p))
+
+	_, err = bc.GetProof(*chainCfg.Miner, nil, 100)
+	assert.Error(err)
+}
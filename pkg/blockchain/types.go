@@ -7,10 +7,12 @@ import (
 
 	"metechain/pkg/contract/evm"
 	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
 	"metechain/pkg/transaction"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 const (
@@ -41,10 +43,26 @@ var (
 type Blockchain struct {
 	mu sync.RWMutex
 	db store.DB
-	//	cdb      *bgdb.Database
+	// cdb serves the freezer, if one is configured
+	cdb      *bgdb.Database
 	sdb      *state.StateDB
 	evm      *evm.Evm
 	ChainCfg *ChainConfig
+	// pruning is set while a prune run is in progress
+	pruning int32
+	// freezing is set while a freeze run is in progress
+	freezing int32
+	// bloomIndexing is set while the bloom index is built
+	bloomIndexing int32
+	// bloomBitsBlocks and bloomConfirms are BloomBitsBlocks and
+	// BloomConfirms, tests shrink them under mu
+	bloomBitsBlocks uint64
+	bloomConfirms   uint64
+	// quit is closed by Close, wg waits for the runs started in the background
+	quit chan struct{}
+	wg   sync.WaitGroup
+	// reorgFeed sends the reorganizations of the main chain
+	reorgFeed event.Feed
 }
 
 var ETHDECIMAL uint64 = 10000000
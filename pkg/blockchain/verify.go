@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"metechain/pkg/block"
+	"metechain/pkg/storage/merkle"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// Problem is an inconsistency found by Check at one height.
+type Problem struct {
+	Height uint64
+	Err    error
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("height %d: %v", p.Height, p.Err)
+}
+
+// Check walks the main chain in db from genesis up to the height stored
+// under HeightKey and returns every inconsistency it finds, ordered by
+// height. For each height it checks that the hash maps to a block that
+// deserializes, that PrevHash links to the previous block, that the Merkle
+// root of the transactions, and of the stored receipts for blocks that commit
+// to them, recomputes and that the state root recorded for the height exists
+// in the state trie. Header-only blocks below a state snapshot have neither,
+// and states below PrunedKey are not checked. Blocks moved to a freezer are
+// read from ancients, which may be nil if there are none. progress, if not
+// nil, is called with every height checked.
+func Check(db store.DB, ancients AncientReader, progress func(height uint64)) ([]Problem, error) {
+	hb, err := db.Get(HeightKey)
+	if err == store.NotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tip, err := miscellaneous.D64func(hb)
+	if err != nil {
+		return nil, fmt.Errorf("height key: %w", err)
+	}
+
+	pruned, err := PrunedHeight(db)
+	if err != nil {
+		return nil, fmt.Errorf("pruned key: %w", err)
+	}
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(db))
+
+	frozen, err := FrozenHeight(db)
+	if err != nil {
+		return nil, fmt.Errorf("frozen key: %w", err)
+	}
+	if frozen > InitHeight && ancients == nil {
+		return nil, fmt.Errorf("the blocks below %d are in a freezer", frozen)
+	}
+	var (
+		problems []Problem
+		prevHash []byte
+	)
+	for h := uint64(InitHeight); h <= tip; h++ {
+		hash, err := checkBlock(db, ancients, sdb, h, prevHash, h >= pruned)
+		if err != nil {
+			problems = append(problems, Problem{Height: h, Err: err})
+		}
+		prevHash = hash
+		if progress != nil {
+			progress(h)
+		}
+	}
+	return problems, nil
+}
+
+// checkBlock checks the block at height h and returns the hash the height
+// maps to, so the next height can check its link even if this block is bad.
+// The state root is only checked if withState is set.
+func checkBlock(db store.DB, ancients AncientReader, sdb state.Database, h uint64, prevHash []byte, withState bool) ([]byte, error) {
+	hash, err := db.Get(append(HeightPrefix, miscellaneous.E64func(h)...))
+	if err != nil {
+		return nil, fmt.Errorf("block hash: %w", err)
+	}
+	data, err := ReadBlock(db, ancients, hash)
+	if err != nil {
+		return hash, fmt.Errorf("block %x: %w", hash, err)
+	}
+	b, err := block.Deserialize(data)
+	if err != nil {
+		return hash, fmt.Errorf("block %x does not deserialize: %w", hash, err)
+	}
+	if b.Height != h {
+		return hash, fmt.Errorf("block %x has height %d", hash, b.Height)
+	}
+	if !bytes.Equal(b.Hash, hash) {
+		return hash, fmt.Errorf("block %x is stored under %x", b.Hash, hash)
+	}
+
+	// the genesis block has neither a parent nor a transaction root
+	if h == InitHeight {
+		return hash, nil
+	}
+
+	if prevHash != nil && !bytes.Equal(b.PrevHash, prevHash) {
+		return hash, fmt.Errorf("previous hash %x, want %x", b.PrevHash, prevHash)
+	}
+
+	// blocks imported from a state snapshot are headers only, every mined
+	// block carries at least the coinbase transaction
+	headerOnly := len(b.Transactions) == 0
+	if !headerOnly {
+		root, err := txRoot(b.Transactions)
+		if err != nil {
+			return hash, err
+		}
+		if !bytes.Equal(root, b.Root) {
+			return hash, fmt.Errorf("transaction root %x, recomputed %x", b.Root, root)
+		}
+		if b.Version >= block.Version2 {
+			if err := checkReceiptsRoot(db, ancients, b); err != nil {
+				return hash, err
+			}
+		}
+	}
+
+	if !withState {
+		return hash, nil
+	}
+	snap, err := db.Get(append(SnapRootPrefix, miscellaneous.E64func(h)...))
+	if err == store.NotExist && headerOnly {
+		return hash, nil
+	} else if err != nil {
+		return hash, fmt.Errorf("state root: %w", err)
+	}
+	if _, err := state.New(common.BytesToHash(snap), sdb, nil); err != nil {
+		return hash, fmt.Errorf("state root %x: %w", snap, err)
+	}
+	return hash, nil
+}
+
+// checkReceiptsRoot checks the receipts root of the block b against the
+// receipts stored for it, if any.
+func checkReceiptsRoot(db store.DB, ancients AncientReader, b *block.Block) error {
+	data, err := readReceipts(db, ancients, b)
+	if err == store.NotExist {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("receipts: %w", err)
+	}
+	receipts, err := transaction.DecodeReceipts(data)
+	if err != nil {
+		return fmt.Errorf("receipts: %w", err)
+	}
+	root, err := receiptsRoot(receipts)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, b.ReceiptsRoot) {
+		return fmt.Errorf("receipts root %x, recomputed %x", b.ReceiptsRoot, root)
+	}
+	return nil
+}
+
+// txRoot recomputes the Merkle root NewBlock builds over the transactions.
+func txRoot(txs []*transaction.FinishedTransaction) ([]byte, error) {
+	list, err := txLeaves(txs)
+	if err != nil {
+		return nil, err
+	}
+	return merkle.New(sha256.New(), list).GetMtHash(), nil
+}
+
+// txLeaves returns the leaves of the Merkle tree over the transactions.
+func txLeaves(txs []*transaction.FinishedTransaction) ([][]byte, error) {
+	list := make([][]byte, 0, len(txs))
+	for _, ft := range txs {
+		st, err := submittedTransaction(ft)
+		if err != nil {
+			return nil, err
+		}
+		data, err := st.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, data)
+	}
+	return list, nil
+}
+
+// submittedTransaction undoes what handleContractTransaction used to write back
+// into the input of an evm contract transaction before the results went to
+// receipts, the root covers the input as it was submitted.
+func submittedTransaction(ft *transaction.FinishedTransaction) (*transaction.SignedTransaction, error) {
+	st := ft.SignedTransaction
+	if !st.Transaction.IsEvmContractTransaction() {
+		return &st, nil
+	}
+	evmC, err := transaction.DecodeEvmData(st.Input)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %x: %w", st.Hash(), err)
+	}
+	evmC.Ret, evmC.Status, evmC.Logs = "", false, nil
+	if evmC.Operation == CREATECONTRACT || evmC.Operation == "Create" {
+		evmC.ContractAddr = common.Address{}
+	}
+	if st.Input, err = transaction.EncodeEvmData(evmC); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"metechain/pkg/logger"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/mem"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "blockchain")
+	if err != nil {
+		panic(err)
+	}
+	cfg := logger.DefaultConfig()
+	cfg.FileName = filepath.Join(dir, "debug.log")
+	if err := logger.InitLogger(cfg); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var (
+	chainOnce sync.Once
+	chainDB   store.DB
+	chainCfg  *ChainConfig
+)
+
+// newTestChain returns a copy of an in-memory chain with four blocks on top
+// of genesis. The chain is built once, the genesis transaction can only be
+// created once per process.
+func newTestChain(t *testing.T) store.DB {
+	chainOnce.Do(func() {
+		assert := assert.New(t)
+		miner := common.HexToAddress("0x01")
+		chainDB = mem.New()
+		chainCfg = &ChainConfig{ChainId: 1, GasLimit: MINGASLIMIT, GasPrice: 1, Miner: &miner, Version2Height: new(uint64), Version3Height: new(uint64)}
+		// AddBlock compares the state against SnapRootKey, even for genesis
+		assert.NoError(chainDB.Set(SnapRootKey, types.EmptyRootHash.Bytes()))
+		bc, err := New(chainDB, chainCfg)
+		assert.NoError(err)
+		for i := 0; i < 4; i++ {
+			b, err := bc.NewBlock(nil, &miner)
+			assert.NoError(err)
+			assert.NoError(bc.ExecuteBlock(b))
+			assert.NoError(b.SetHash())
+			assert.NoError(bc.AddBlock(b))
+		}
+	})
+
+	db := mem.New()
+	itr := chainDB.NewIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		assert.NoError(t, db.Set(itr.Key(), itr.Value()))
+	}
+	return db
+}
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+
+	var checked []uint64
+	problems, err := Check(db, nil, func(h uint64) { checked = append(checked, h) })
+	assert.NoError(err)
+	assert.Empty(problems)
+	assert.Equal([]uint64{0, 1, 2, 3, 4}, checked)
+}
+
+func TestCheckBrokenBlock(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+
+	hash, err := db.Get(append(HeightPrefix, miscellaneous.E64func(2)...))
+	assert.NoError(err)
+	assert.NoError(db.Set(hash, []byte("garbage")))
+
+	problems, err := Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Len(problems, 1)
+	assert.Equal(uint64(2), problems[0].Height)
+
+	// New rolls the tip back by one block before DeleteBlock cuts the rest
+	bc, err := New(db, chainCfg)
+	assert.NoError(err)
+	assert.NoError(bc.DeleteBlock(problems[0].Height))
+	h, err := bc.GetMaxBlockHeight()
+	assert.NoError(err)
+	assert.Equal(uint64(1), h)
+	problems, err = Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Empty(problems)
+}
+
+func TestCheckBrokenLink(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+
+	assert.NoError(db.Set(append(HeightPrefix, miscellaneous.E64func(1)...), []byte("elsewhere")))
+	problems, err := Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Len(problems, 2)
+	assert.Equal(uint64(1), problems[0].Height)
+	assert.Equal(uint64(2), problems[1].Height)
+}
+
+func TestCheckMissingStateRoot(t *testing.T) {
+	assert := assert.New(t)
+	db := newTestChain(t)
+
+	assert.NoError(db.Set(append(SnapRootPrefix, miscellaneous.E64func(2)...), common.HexToHash("0xdead").Bytes()))
+	problems, err := Check(db, nil, nil)
+	assert.NoError(err)
+	assert.Len(problems, 1)
+	assert.Equal(uint64(2), problems[0].Height)
+}
+
+func TestGetTransactionProof(t *testing.T) {
+	assert := assert.New(t)
+	bc, err := New(newTestChain(t), chainCfg)
+	assert.NoError(err)
+
+	b, err := bc.GetBlockByHeight(2)
+	assert.NoError(err)
+	hash := b.Transactions[0].Hash()
+	proof, err := bc.GetTransactionProof(hash)
+	assert.NoError(err)
+	assert.True(proof.Verify())
+	assert.Equal(b.Hash, proof.Header.Hash)
+	assert.Empty(proof.Header.Transactions)
+
+	proof.Leaf = append([]byte{}, proof.Leaf...)
+	proof.Leaf[0] ^= 0xff
+	assert.False(proof.Verify())
+	_, err = bc.GetTransactionProof([]byte("unknown"))
+	assert.Error(err)
+}
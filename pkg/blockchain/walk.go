@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var emptyCodeHash = crypto.Keccak256(nil)
+
+// WalkState calls node for every trie node and code for every contract code
+// reachable from root, accounts and storage alike. Subtrees whose root is in
+// seen are skipped, so walking two roots of neighbouring blocks only visits
+// what changed between them.
+func WalkState(sdb state.Database, root common.Hash, seen map[common.Hash]struct{},
+	node func(hash common.Hash, blob []byte) error, code func(hash common.Hash, code []byte) error) error {
+	t, err := sdb.OpenTrie(root)
+	if err != nil {
+		return err
+	}
+	return walkTrie(sdb, t, seen, node, func(key, leaf []byte) error {
+		var acc state.Account
+		if err := rlp.DecodeBytes(leaf, &acc); err != nil {
+			return err
+		}
+		addrHash := common.BytesToHash(key)
+		st, err := sdb.OpenStorageTrie(addrHash, acc.Root)
+		if err != nil {
+			return err
+		}
+		if err := walkTrie(sdb, st, seen, node, nil); err != nil {
+			return err
+		}
+		if bytes.Equal(acc.CodeHash, emptyCodeHash) {
+			return nil
+		}
+		codeHash := common.BytesToHash(acc.CodeHash)
+		if _, ok := seen[codeHash]; ok {
+			return nil
+		}
+		seen[codeHash] = struct{}{}
+		c, err := sdb.ContractCode(addrHash, codeHash)
+		if err != nil {
+			return err
+		}
+		return code(codeHash, c)
+	})
+}
+
+func walkTrie(sdb state.Database, t state.Trie, seen map[common.Hash]struct{},
+	node func(common.Hash, []byte) error, leaf func(key, value []byte) error) error {
+	it := t.NodeIterator(nil)
+	descend := true
+	for it.Next(descend) {
+		descend = true
+		if it.Leaf() {
+			if leaf != nil {
+				if err := leaf(it.LeafKey(), it.LeafBlob()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		h := it.Hash()
+		// nodes embedded in their parent have no hash of their own
+		if h == (common.Hash{}) {
+			continue
+		}
+		if _, ok := seen[h]; ok {
+			descend = false
+			continue
+		}
+		seen[h] = struct{}{}
+		blob, err := sdb.TrieDB().Node(h)
+		if err != nil {
+			return err
+		}
+		if err := node(h, blob); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
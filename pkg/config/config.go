@@ -1,9 +1,13 @@
 package config
 
 import (
+	"path/filepath"
+
 	"metechain/pkg/blockchain"
 	_ "metechain/pkg/crypto/sigs/secp"
 	"metechain/pkg/miner"
+	"metechain/pkg/storage/store/engine"
+	"metechain/pkg/txpool"
 
 	"github.com/spf13/viper"
 )
@@ -14,6 +18,8 @@ type CfgInfo struct {
 	metemaskCfg *metemaskConfig         `yaml:"metemaskCfg"`
 	P2PConfig   *P2PConfig              `yaml:"p2pconfig"`
 	MinerConfig *miner.Config           `yaml:minerconfig`
+	StorageCfg  *engine.Config          `yaml:"storageCfg"`
+	TxPoolCfg   *txpool.Config          `yaml:"txpoolCfg"`
 	NetWorkType string                  `yaml:"networktype"`
 }
 
@@ -48,10 +54,18 @@ func LoadConfig() (*CfgInfo, error) {
 		return nil, err
 	}
 
-	var cfg CfgInfo
+	cfg := CfgInfo{StorageCfg: engine.DefaultConfig(), TxPoolCfg: txpool.DefaultConfig()}
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	// the freezer lives in the data directory of the storage engine by default
+	if cfg.ChainCfg != nil && cfg.ChainCfg.Freezer != nil && len(cfg.ChainCfg.Freezer.Dir) == 0 {
+		cfg.ChainCfg.Freezer.Dir = filepath.Join(cfg.StorageCfg.Dir(), "ancient")
+	}
+	// so does the journal of the local transactions of the pool
+	if cfg.TxPoolCfg != nil && len(cfg.TxPoolCfg.Journal) == 0 {
+		cfg.TxPoolCfg.Journal = filepath.Join(cfg.StorageCfg.Dir(), "transactions.journal")
+	}
 
 	return &cfg, nil
 }
@@ -154,7 +154,8 @@ func (bc *BlockChain) maybeAcceptBlock(b *block.Block) (bool, bool) {
 
 		if b.Height != 1 {
 			if err := bc.Bc.CheckBlockRegular(b); err != nil {
-				logger.Error("CheckBlockRegular", zap.Error(err))
+				reason, _ := blockchain.Rejection(err)
+				logger.Error("CheckBlockRegular", zap.Stringer("reason", reason), zap.Error(err))
 				return false, false
 			}
 		}
@@ -133,6 +133,11 @@ func (e *Evm) SetNonce(addr common.Address, nonce uint64) {
 	e.cfg.State.SetNonce(addr, nonce)
 }
 
+//Get the gas refund counter
+func (e *Evm) GetRefund() uint64 {
+	return e.cfg.State.GetRefund()
+}
+
 //Get Storage At address
 func (e *Evm) GetStorageAt(addr common.Address, hash common.Hash) common.Hash {
 	proof := e.cfg.State.GetState(addr, hash)
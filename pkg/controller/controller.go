@@ -160,7 +160,8 @@ func (c *Controller) HandleTransactionMessage(msg []byte) error {
 	if err != nil {
 		return err
 	}
-	return c.Pool.Add(st)
+	_, err = c.Pool.AddRemote(st)
+	return err
 }
 
 func (c *Controller) HandleBlockHeadMessage(msg []byte) error {
@@ -7,6 +7,9 @@ type Config struct {
 	MaxAge     int
 	MaxBackups int
 	Comperss   bool
+	// Stderr sends console output to stderr, keeping stdout free for the
+	// output of offline commands.
+	Stderr bool
 }
 
 func DefaultConfig() *Config {
@@ -25,7 +25,14 @@ var defaultWriter io.Writer
 // InitLogger Initialize logger
 func InitLogger(cfg *Config) (err error) {
 	encoder := getEncoder()
-	syncWriter := getLogWriter(cfg.FileName, cfg.MaxAge, cfg.MaxSize, cfg.MaxBackups, cfg.Comperss)
+	console := os.Stdout
+	if cfg.Stderr {
+		console = os.Stderr
+		InfoLogger.SetOutput(console)
+		WarnLogger.SetOutput(console)
+		ErrorLogger.SetOutput(console)
+	}
+	syncWriter := getLogWriter(cfg.FileName, cfg.MaxAge, cfg.MaxSize, cfg.MaxBackups, cfg.Comperss, console)
 
 	level := new(zapcore.Level)
 	err = level.UnmarshalText([]byte(cfg.Level))
@@ -52,7 +59,7 @@ func getEncoder() zapcore.Encoder {
 	return zapcore.NewJSONEncoder(encodeConfig)
 }
 
-func getLogWriter(filename string, maxAge, maxSize, maxBackups int, compress bool) zapcore.WriteSyncer {
+func getLogWriter(filename string, maxAge, maxSize, maxBackups int, compress bool, console *os.File) zapcore.WriteSyncer {
 	umberJackLogger := &lumberjack.Logger{
 		Filename:   filename,
 		MaxAge:     maxAge,
@@ -61,7 +68,7 @@ func getLogWriter(filename string, maxAge, maxSize, maxBackups int, compress boo
 		Compress:   compress,
 	}
 	defaultWriter = umberJackLogger
-	return zapcore.NewMultiWriteSyncer(zapcore.AddSync(umberJackLogger), zapcore.AddSync(os.Stdout))
+	return zapcore.NewMultiWriteSyncer(zapcore.AddSync(umberJackLogger), zapcore.AddSync(console))
 	// return zapcore.NewMultiWriteSyncer(zapcore.AddSync(umberJackLogger))
 }
 
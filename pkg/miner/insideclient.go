@@ -703,7 +703,7 @@ func (inside *InsideClient) GetTransactions() {
 			logger.SugarLogger.Error("DeserializeSignaturedTransaction err", zap.Error(err))
 			return
 		}
-		err = inside.tp.Add(signtx)
+		_, err = inside.tp.Add(signtx)
 		if err != nil {
 			continue
 		}
@@ -752,7 +752,7 @@ func (inside *InsideClient) GetTransactionsTest() {
 			logger.SugarLogger.Error("DeserializeSignaturedTransaction err", zap.Error(err))
 			return
 		}
-		err = inside.tp.Add(signtx)
+		_, err = inside.tp.Add(signtx)
 		if err != nil {
 			continue
 		}
@@ -171,6 +171,14 @@ func (m *Miner) MultiCalcDifficulty() {
 
 		tmpBits := atomic.LoadUint32(&globalBits)
 		target := CompactToBig(tmpBits)
+
+		// the proof of work commits to the difficulty and to the result of
+		// executing the block
+		b.GlobalDifficulty = CompactToBig(tmpBits)
+		if err := m.bc.ExecuteBlock(b); err != nil {
+			logger.Error("CalcDifficulty ExecuteBlock", zap.Error(err))
+			return
+		}
 		fmt.Printf("globalBits%d,tager:%s\n", globalBits, target.String())
 		sectionNum := math.MaxUint64 / uint64(cpuNum)
 		stopCh, toStopCh := make(chan struct{}), make(chan struct{})
@@ -212,7 +220,6 @@ func (m *Miner) Run(flag int) {
 			tmpTime := time.Now()
 			logger.Info("start add block", zap.Int64("timestamp", t0.Unix()))
 			//b.Difficulty = CompactToBig(localBits)
-			b.GlobalDifficulty = CompactToBig(globalBits)
 			if t := time.Now().Unix() - lastblocktime; t > 0 {
 				b.UsedTime = uint64(t)
 			}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"net/http"
 
 	"metechain/pkg/block"
@@ -17,6 +18,7 @@ import (
 	"metechain/pkg/txpool"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/goinggo/mapstructure"
 )
@@ -140,7 +142,7 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 			w.Write(resE)
 		} else {
 			from := para[0].(string)
-			blc, err := s.eth_getBalance(from)
+			blc, err := s.eth_getBalance(from, getBlockTag(para, 1))
 			if err != nil {
 				resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_getBalance 1", err))
 				w.Write(resE)
@@ -242,12 +244,32 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 	case ETH_GASPRICE:
-		resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: s.eth_gasPrice()})
+		price, err := s.eth_gasPrice()
 		if err != nil {
-			resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_gasPrice Marshal", err))
+			resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_gasPrice", err))
 			w.Write(resE)
 		} else {
-			w.Write(resp)
+			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: price})
+			if err != nil {
+				resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_gasPrice Marshal", err))
+				w.Write(resE)
+			} else {
+				w.Write(resp)
+			}
+		}
+	case ETH_MAXPRIORITYFEEPERGAS:
+		tip, err := s.eth_maxPriorityFeePerGas()
+		if err != nil {
+			resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_maxPriorityFeePerGas", err))
+			w.Write(resE)
+		} else {
+			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: tip})
+			if err != nil {
+				resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_maxPriorityFeePerGas Marshal", err))
+				w.Write(resE)
+			} else {
+				w.Write(resp)
+			}
 		}
 	case EHT_GETCODE:
 		para, err := getParam(reqData)
@@ -255,7 +277,12 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 			resE := responseErrFunc(ParameterErr, jsonrpc, id, errorMessage("EHT_GETCODE getParam", err))
 			w.Write(resE)
 		} else {
-			code := s.eth_getCode(para[0].(string))
+			code, err := s.eth_getCode(para[0].(string), getBlockTag(para, 1))
+			if err != nil {
+				resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_getCode", err))
+				w.Write(resE)
+				break
+			}
 			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: code})
 			if err != nil {
 				w.Write([]byte(errorMessage("eth_getCode Marshal getParam", err)))
@@ -270,7 +297,7 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 			w.Write(resE)
 		} else {
 			addr := para[0].(string)
-			count, err := s.eth_getTransactionCount(addr)
+			count, err := s.eth_getTransactionCount(addr, getBlockTag(para, 1))
 			if err != nil {
 				resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_getTransactionCount", err))
 				w.Write(resE)
@@ -322,12 +349,12 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 			resE := responseErrFunc(ParameterErr, jsonrpc, id, errorMessage("ETH_SENDRAWTRANSACTION getParam", err))
 			w.Write(resE)
 		} else {
-			hash, err := s.eth_sendRawTransaction(para[0].(string))
+			hash, replaced, err := s.eth_sendRawTransaction(para[0].(string), remoteIP(req))
 			if err != nil {
 				resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_sendRawTransaction", err))
 				w.Write(resE)
 			} else {
-				resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: hash})
+				resp, err := json.Marshal(responseSendTransaction{JsonRPC: jsonrpc, Id: id, Result: hash, Replaced: replaced})
 				if err != nil {
 					resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_sendRawTransaction Marshal", err))
 					w.Write(resE)
@@ -395,6 +422,48 @@ func (s *Server) HandRequest(w http.ResponseWriter, req *http.Request) {
 				w.Write(resp)
 			}
 		}
+	case ETH_GETPROOF:
+		res, err := s.eth_getProof(reqData)
+		if err != nil {
+			resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_getProof", err))
+			w.Write(resE)
+		} else {
+			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: res})
+			if err != nil {
+				resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_getProof Marshal", err))
+				w.Write(resE)
+			} else {
+				w.Write(resp)
+			}
+		}
+	case ETH_FEEHISTORY:
+		res, err := s.eth_feeHistory(reqData)
+		if err != nil {
+			resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("eth_feeHistory", err))
+			w.Write(resE)
+		} else {
+			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: res})
+			if err != nil {
+				resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("eth_feeHistory Marshal", err))
+				w.Write(resE)
+			} else {
+				w.Write(resp)
+			}
+		}
+	case METE_GETTRANSACTIONSBYADDRESS:
+		res, err := s.mete_getTransactionsByAddress(reqData)
+		if err != nil {
+			resE := responseErrFunc(UnkonwnErr, jsonrpc, id, errorMessage("mete_getTransactionsByAddress", err))
+			w.Write(resE)
+		} else {
+			resp, err := json.Marshal(responseBody{JsonRPC: jsonrpc, Id: id, Result: res})
+			if err != nil {
+				resE := responseErrFunc(JsonMarshalErr, jsonrpc, id, errorMessage("mete_getTransactionsByAddress Marshal", err))
+				w.Write(resE)
+			} else {
+				w.Write(resp)
+			}
+		}
 	case ETH_SIGNTRANSACTION:
 		signatrue, err := s.eth_signTransaction(reqData)
 		if err != nil {
@@ -464,12 +533,21 @@ func (s *Server) eth_signTransaction(mp map[string]interface{}) (string, error)
 	return "", errors.New("unsupport method")
 }
 
-//send signed transaction
-func (s *Server) eth_sendRawTransaction(rawTx string) (string, error) {
+//send signed transaction from the IP address ip
+func (s *Server) eth_sendRawTransaction(rawTx, ip string) (string, bool, error) {
 	if rawTx[:2] != "0x" {
 		rawTx = "0x" + rawTx
 	}
-	return s.cli.SendRawTransaction(rawTx)
+	return s.cli.SendRawTransaction(rawTx, ip)
+}
+
+//remoteIP is the IP address the request was sent from
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
 }
 
 //Executes a new message call immediately without creating a transaction on the block chain.
@@ -492,7 +570,7 @@ func (s *Server) eth_call(mp map[string]interface{}) (string, error) {
 			return "", err
 		}
 	}
-	ret, _, err := s.cli.ContractCall(para.From, para.To, para.Data, blockchain.Check0x(para.Value))
+	ret, _, err := s.cli.ContractCall(para.From, para.To, para.Data, blockchain.Check0x(para.Value), getBlockTag(Para, 1))
 	return ret, err
 }
 
@@ -506,8 +584,8 @@ func (s *Server) eth_blockNumber() uint64 {
 }
 
 //Returns the balance of the account of given address.
-func (s *Server) eth_getBalance(from string) (*big.Int, error) {
-	return s.cli.GetBalance(from)
+func (s *Server) eth_getBalance(from, tag string) (*big.Int, error) {
+	return s.cli.GetBalance(from, tag)
 }
 
 //Returns information about a block by block hash.
@@ -534,7 +612,11 @@ func (s *Server) eth_getTransactionByHash(hash string) (*Transaction, error) {
 	if err != nil {
 		return nil, err
 	}
+	return s.txToEthTx(tx)
+}
 
+// tx to eth tx
+func (s *Server) txToEthTx(tx *transaction.FinishedTransaction) (*Transaction, error) {
 	var trs Transaction
 	// from, _ := s.cli.AddressToCommonAddr(tx.From)
 	trs.From = *tx.From
@@ -592,18 +674,31 @@ func (s *Server) eth_getTransactionByHash(hash string) (*Transaction, error) {
 }
 
 //Returns code at a given address.
-func (s *Server) eth_getCode(addr string) string {
-	return s.cli.GetCode(addr)
+func (s *Server) eth_getCode(addr, tag string) (string, error) {
+	return s.cli.GetCode(addr, tag)
 }
 
 //Returns the number of transactions sent from an address.
-func (s *Server) eth_getTransactionCount(addr string) (uint64, error) {
-	return s.cli.GetNonce(addr)
+func (s *Server) eth_getTransactionCount(addr, tag string) (uint64, error) {
+	return s.cli.GetNonce(addr, tag)
 }
 
 //Returns the current price per gas in wei.
-func (s *Server) eth_gasPrice() string {
-	return uint64ToHexString(s.Cfg.ChainCfg.GasLimit)
+func (s *Server) eth_gasPrice() (string, error) {
+	price, err := s.cli.GasPrice()
+	if err != nil {
+		return "", err
+	}
+	return hexutil.EncodeBig(price), nil
+}
+
+//Returns the tip per gas in wei to pay on top of the base fee.
+func (s *Server) eth_maxPriorityFeePerGas() (string, error) {
+	tip, err := s.cli.MaxPriorityFeePerGas()
+	if err != nil {
+		return "", err
+	}
+	return hexutil.EncodeBig(tip), nil
 }
 
 //Generates and returns an estimate of how much gas is necessary to allow the transaction to complete. The transaction will not be added to the blockchain.
@@ -636,7 +731,7 @@ func (s *Server) eth_estimateGas(mp map[string]interface{}) (string, error) {
 		return fmt.Sprintf("%X", s.Cfg.ChainCfg.GasLimit), nil
 	}
 
-	ret, gas, err := s.cli.ContractCall(para.From, para.To, para.Data, blockchain.Check0x(para.Value))
+	ret, gas, err := s.cli.ContractCall(para.From, para.To, para.Data, blockchain.Check0x(para.Value), "latest")
 	if err != nil {
 		return ret, err
 	}
@@ -646,7 +741,7 @@ func (s *Server) eth_estimateGas(mp map[string]interface{}) (string, error) {
 //Returns the receipt of a transaction by transaction hash.
 func (s *Server) eth_getTransactionReceipt(hash string) (*TransactionReceipt, error) {
 	hash = blockchain.Check0x(hash)
-	tx, err := s.cli.GetTransactionReceipt(hash)
+	tx, receipt, err := s.cli.GetTransactionReceipt(hash)
 	if err != nil {
 		return nil, nil
 	}
@@ -654,7 +749,7 @@ func (s *Server) eth_getTransactionReceipt(hash string) (*TransactionReceipt, er
 	if err != nil {
 		return nil, nil
 	}
-	return s.txToTxReceipt(tx, b), nil
+	return s.txToTxReceipt(tx, receipt, b), nil
 }
 
 //Returns an array of all logs matching a given filter object.
@@ -678,6 +773,15 @@ func (s *Server) eth_getLogs(mp map[string]interface{}) ([]*types.Log, error) {
 		}
 	}
 
+	addresses, err := getLogAddresses(para.Address)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := getLogTopics(para.Topics)
+	if err != nil {
+		return nil, err
+	}
+
 	var fromBlock, toBlock uint64
 	if len(para.FromBlock) > 0 && len(para.ToBlock) > 0 {
 		fb, err := hexToUint64(para.FromBlock)
@@ -693,7 +797,7 @@ func (s *Server) eth_getLogs(mp map[string]interface{}) ([]*types.Log, error) {
 		toBlock = tb
 	}
 
-	return s.cli.GetLogs(para.Address, fromBlock, toBlock, para.Topics, para.BlockHash), nil
+	return s.cli.GetLogs(addresses, fromBlock, toBlock, topics, para.BlockHash)
 }
 
 //Returns the current client version.
@@ -708,18 +812,169 @@ func (s *Server) eth_getStorageAt(mp map[string]interface{}) (string, error) {
 		return "", errors.New(fmt.Sprintf("'%s' not exist", "params"))
 	}
 
-	var addr, hash string
+	var addr, hash, tag string
 	if paras, ok := v.([]interface{}); ok {
 		if len(paras) == 1 {
 			addr = paras[0].(string)
-		} else if len(paras) == 2 {
+		} else if len(paras) >= 2 {
 			addr = paras[0].(string)
 			hash = paras[1].(string)
 		}
+		tag = getBlockTag(paras, 2)
 	} else {
 		return "", errors.New("eth_getStorageAt: params is wrong!")
 	}
-	return s.cli.GetStorageAt(addr, hash), nil
+	return s.cli.GetStorageAt(addr, hash, tag)
+}
+
+//Returns the account and storage values of the specified account including the Merkle-proof.
+func (s *Server) eth_getProof(mp map[string]interface{}) (*AccountResult, error) {
+	paras, err := getParam(mp)
+	if err != nil {
+		return nil, err
+	}
+	if len(paras) < 2 {
+		return nil, errors.New("eth_getProof: params is wrong!")
+	}
+	addr, ok := paras[0].(string)
+	if !ok {
+		return nil, errors.New("eth_getProof: address is wrong!")
+	}
+	rawKeys, ok := paras[1].([]interface{})
+	if !ok {
+		return nil, errors.New("eth_getProof: storage keys is wrong!")
+	}
+	keys := make([]string, len(rawKeys))
+	for i, k := range rawKeys {
+		if keys[i], ok = k.(string); !ok {
+			return nil, errors.New("eth_getProof: storage keys is wrong!")
+		}
+	}
+
+	p, err := s.cli.GetProof(addr, keys, getBlockTag(paras, 2))
+	if err != nil {
+		return nil, err
+	}
+	res := &AccountResult{
+		Address:      p.Address,
+		AccountProof: toHexSlice(p.AccountProof),
+		Balance:      (*hexutil.Big)(p.Balance),
+		CodeHash:     p.CodeHash,
+		Nonce:        hexutil.Uint64(p.Nonce),
+		StorageHash:  p.StorageHash,
+		StorageProof: make([]StorageResult, len(p.StorageProof)),
+	}
+	for i, sp := range p.StorageProof {
+		res.StorageProof[i] = StorageResult{Key: keys[i], Value: (*hexutil.Big)(sp.Value), Proof: toHexSlice(sp.Proof)}
+	}
+	return res, nil
+}
+
+//Returns the base fees, gas used ratios and tips at the reward percentiles of a range of blocks up to the newest block.
+func (s *Server) eth_feeHistory(mp map[string]interface{}) (*FeeHistoryResult, error) {
+	paras, err := getParam(mp)
+	if err != nil {
+		return nil, err
+	}
+	if len(paras) < 2 {
+		return nil, errors.New("eth_feeHistory: params is wrong!")
+	}
+	var count uint64
+	switch v := paras[0].(type) {
+	case string:
+		if count, err = hexutil.DecodeUint64(v); err != nil {
+			return nil, errors.New("eth_feeHistory: block count is wrong!")
+		}
+	case float64:
+		count = uint64(v)
+	default:
+		return nil, errors.New("eth_feeHistory: block count is wrong!")
+	}
+	var percentiles []float64
+	if len(paras) > 2 && paras[2] != nil {
+		raw, ok := paras[2].([]interface{})
+		if !ok {
+			return nil, errors.New("eth_feeHistory: reward percentiles is wrong!")
+		}
+		percentiles = make([]float64, len(raw))
+		for i, p := range raw {
+			if percentiles[i], ok = p.(float64); !ok {
+				return nil, errors.New("eth_feeHistory: reward percentiles is wrong!")
+			}
+		}
+	}
+
+	h, err := s.cli.FeeHistory(count, getBlockTag(paras, 1), percentiles)
+	if err != nil {
+		return nil, err
+	}
+	res := &FeeHistoryResult{
+		OldestBlock:  hexutil.Uint64(h.OldestBlock),
+		BaseFee:      make([]*hexutil.Big, len(h.BaseFee)),
+		GasUsedRatio: h.GasUsedRatio,
+	}
+	if res.GasUsedRatio == nil {
+		res.GasUsedRatio = []float64{}
+	}
+	for i, fee := range h.BaseFee {
+		res.BaseFee[i] = (*hexutil.Big)(fee)
+	}
+	if h.Reward != nil {
+		res.Reward = make([][]*hexutil.Big, len(h.Reward))
+		for i, tips := range h.Reward {
+			res.Reward[i] = make([]*hexutil.Big, len(tips))
+			for j, tip := range tips {
+				res.Reward[i][j] = (*hexutil.Big)(tip)
+			}
+		}
+	}
+	return res, nil
+}
+
+//Returns a page of the transactions sent or received by an address and the cursor of the next page.
+func (s *Server) mete_getTransactionsByAddress(mp map[string]interface{}) (*AddressTxsResult, error) {
+	paras, err := getParam(mp)
+	if err != nil {
+		return nil, err
+	}
+	if len(paras) < 1 {
+		return nil, errors.New("mete_getTransactionsByAddress: params is wrong!")
+	}
+	addr, ok := paras[0].(string)
+	if !ok {
+		return nil, errors.New("mete_getTransactionsByAddress: address is wrong!")
+	}
+	var cursor, direction string
+	var limit int
+	if len(paras) > 1 && paras[1] != nil {
+		if cursor, ok = paras[1].(string); !ok {
+			return nil, errors.New("mete_getTransactionsByAddress: cursor is wrong!")
+		}
+	}
+	if len(paras) > 2 && paras[2] != nil {
+		l, ok := paras[2].(float64)
+		if !ok || l < 0 {
+			return nil, errors.New("mete_getTransactionsByAddress: limit is wrong!")
+		}
+		limit = int(l)
+	}
+	if len(paras) > 3 && paras[3] != nil {
+		if direction, ok = paras[3].(string); !ok {
+			return nil, errors.New("mete_getTransactionsByAddress: direction is wrong!")
+		}
+	}
+
+	txs, next, err := s.cli.GetTransactionsByAddress(addr, cursor, limit, direction)
+	if err != nil {
+		return nil, err
+	}
+	res := &AddressTxsResult{Transactions: make([]*Transaction, len(txs)), Cursor: next}
+	for i, tx := range txs {
+		if res.Transactions[i], err = s.txToEthTx(tx); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 // block to eth block
@@ -735,11 +990,20 @@ func (s *Server) blockToEthBlock(b *block.Block, bl bool) *Block {
 	block.Hash = common.BytesToHash(b.Hash)
 	block.Number = uint64ToHexString(b.Height)
 	block.ParentHash = common.BytesToHash(b.PrevHash)
+	block.ReceiptsRoot = common.BytesToHash(b.ReceiptsRoot)
+	block.StateRoot = common.BytesToHash(b.SnapRoot)
 	block.TimeStamp = uint64ToHexString(b.Timestamp)
+	if b.BaseFee != nil {
+		block.BaseFee = stringToHex(b.BaseFee.Text(16))
+	}
 
 	// miner, _ := s.cli.AddressToCommonAddr(b.Miner)
 	block.Miner = *b.Miner
 
+	if bloom, err := s.cli.GetBlockBloom(b.Hash); err == nil {
+		block.LogsBloom = hexutil.Encode(bloom.Bytes())
+	}
+
 	if bl {
 		block.Transactions = s.txsToEthTxs(b, b.Transactions)
 	} else {
@@ -750,9 +1014,13 @@ func (s *Server) blockToEthBlock(b *block.Block, bl bool) *Block {
 
 // txs to eth txs
 func (s *Server) txsToEthTxs(block *block.Block, ktxs []*transaction.FinishedTransaction) []*TransactionReceipt {
+	receipts, err := s.cli.GetBlockReceipts(block.Hash)
+	if err != nil || len(receipts) != len(ktxs) {
+		receipts = make([]*transaction.Receipt, len(ktxs))
+	}
 	var etxs []*TransactionReceipt
-	for _, ktx := range ktxs {
-		etxs = append(etxs, s.txToTxReceipt(ktx, block))
+	for i, ktx := range ktxs {
+		etxs = append(etxs, s.txToTxReceipt(ktx, receipts[i], block))
 	}
 	return etxs
 }
@@ -767,7 +1035,7 @@ func (s *Server) getTxsHashes(ktxs []*transaction.FinishedTransaction) []common.
 }
 
 // tx to eth tx receipt
-func (s *Server) txToTxReceipt(tx *transaction.FinishedTransaction, block *block.Block) *TransactionReceipt {
+func (s *Server) txToTxReceipt(tx *transaction.FinishedTransaction, receipt *transaction.Receipt, block *block.Block) *TransactionReceipt {
 	var trp TransactionReceipt
 	var blockHash []byte
 	trp.TransactionHash = common.BytesToHash(tx.Hash())
@@ -781,12 +1049,10 @@ func (s *Server) txToTxReceipt(tx *transaction.FinishedTransaction, block *block
 	// to, _ := s.cli.AddressToCommonAddr(tx.To)
 	trp.To = *tx.To
 
-	var index uint
 	if block != nil {
 		for id, t := range block.Transactions {
 			if t.HashToString() == tx.HashToString() {
 				trp.TransactionIndex = uint64ToHexString(uint64(id))
-				index = uint(id)
 				break
 			}
 		}
@@ -797,45 +1063,30 @@ func (s *Server) txToTxReceipt(tx *transaction.FinishedTransaction, block *block
 
 	evm, _ := transaction.DecodeEvmData(tx.Input)
 	if evm != nil {
-		trp.ContractAddress = evm.ContractAddr
 		if evm.Operation == blockchain.CREATECONTRACT {
 			trp.To = common.Address{}
 		} else {
 			trp.To = evm.ContractAddr
 		}
+	}
 
-		var stat uint64
-		if !evm.Status {
-			stat = 0
-			trp.Status = FAILED
-		} else {
-			stat = 1
-			trp.Status = SUCCESS
+	trp.Logs = make([]*types.Log, 0)
+	trp.Status = FAILED
+	if receipt != nil {
+		trp.GasUsed = receipt.GasUsed.String()
+		trp.CumulativeGasUsed = receipt.CumulativeGasUsed.String()
+		trp.ContractAddress = receipt.ContractAddress
+		trp.LogsBloom = receipt.Bloom
+		trp.RevertReason = receipt.RevertReason
+		if receipt.EffectiveGasPrice != nil {
+			trp.EffectiveGasPrice = stringToHex(receipt.EffectiveGasPrice.Text(16))
 		}
-		trp.Logs = evm.Logs
-		if len(trp.Logs) == 0 {
-			trp.Logs = make([]*types.Log, 0)
+		if len(receipt.Logs) > 0 {
+			trp.Logs = receipt.Logs
 		}
-
-		//set bloom
-		receipt := &types.Receipt{
-			Type:              uint8(tx.Type),
-			Status:            stat,
-			Logs:              evm.Logs,
-			TxHash:            common.BytesToHash(tx.Hash()),
-			ContractAddress:   trp.ContractAddress,
-			GasUsed:           tx.GasUsed.Uint64(),
-			BlockHash:         common.BytesToHash(blockHash),
-			TransactionIndex:  index,
-			BlockNumber:       blockchain.Uint64ToBigInt(tx.BlockNum),
-			PostState:         trp.Root.Bytes(),
-			CumulativeGasUsed: tx.GasUsed.Uint64(),
+		if receipt.Status == transaction.ReceiptStatusSuccessful {
+			trp.Status = SUCCESS
 		}
-		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
-
-		trp.LogsBloom = receipt.Bloom
-	} else {
-		trp.Status = FAILED
 	}
 	return &trp
 }
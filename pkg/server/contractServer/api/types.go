@@ -12,6 +12,7 @@ import (
 	"metechain/pkg/txpool"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -38,6 +39,15 @@ type responseBody struct {
 	Result  interface{} `json:"result"`
 }
 
+// responseSendTransaction is the response of eth_sendRawTransaction, Replaced
+// reports whether the transaction replaced one with its nonce in the pool
+type responseSendTransaction struct {
+	JsonRPC  string      `json:"jsonrpc"`
+	Id       interface{} `json:"id"`
+	Result   string      `json:"result"`
+	Replaced bool        `json:"replaced"`
+}
+
 type responseErr struct {
 	JsonRPC string      `json:"jsonrpc"`
 	Id      interface{} `json:"id"`
@@ -88,7 +98,9 @@ type TransactionReceipt struct {
 	TransactionHash  common.Hash `json:"transactionHash"`
 	TransactionIndex string      `json:"transactionIndex"`
 
-	Root common.Hash `json:"root"`
+	Root              common.Hash `json:"root"`
+	RevertReason      string      `json:"revertReason,omitempty"`
+	EffectiveGasPrice string      `json:"effectiveGasPrice,omitempty"`
 }
 
 type responseReceipt struct {
@@ -109,8 +121,11 @@ type Block struct {
 	Nonce        string         `json:"nonce"`
 	Number       string         `json:"number"`
 	ParentHash   common.Hash    `json:"parentHash"`
+	ReceiptsRoot common.Hash    `json:"receiptsRoot"`
+	StateRoot    common.Hash    `json:"stateRoot"`
 	TimeStamp    string         `json:"timestamp"`
 	Transactions interface{}    `json:"transactions"`
+	BaseFee      string         `json:"baseFeePerGas,omitempty"`
 }
 
 type responseBlock struct {
@@ -119,12 +134,40 @@ type responseBlock struct {
 	Result  *Block      `json:"result"`
 }
 
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+type AddressTxsResult struct {
+	Transactions []*Transaction `json:"transactions"`
+	Cursor       string         `json:"cursor,omitempty"`
+}
+
+type FeeHistoryResult struct {
+	OldestBlock  hexutil.Uint64   `json:"oldestBlock"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+}
+
 type reqGetLog struct {
-	FromBlock string   `json:"fromBlock"`
-	ToBlock   string   `json:"toBlock"`
-	Address   string   `json:"address"`
-	Topics    []string `json:"topics"`
-	BlockHash string   `json:"blockhash"`
+	FromBlock string        `json:"fromBlock"`
+	ToBlock   string        `json:"toBlock"`
+	Address   interface{}   `json:"address"`
+	Topics    []interface{} `json:"topics"`
+	BlockHash string        `json:"blockhash"`
 }
 
 var (
@@ -146,11 +189,16 @@ var (
 	ETH_GETTRANSACTIONRECEIPT string = "eth_getTransactionReceipt"
 	ETH_GETLOGS               string = "eth_getLogs"
 	ETH_GETSTORAGEAT          string = "eth_getStorageAt"
+	ETH_GETPROOF              string = "eth_getProof"
+	ETH_FEEHISTORY            string = "eth_feeHistory"
+	ETH_MAXPRIORITYFEEPERGAS  string = "eth_maxPriorityFeePerGas"
 	ETH_SIGNTRANSACTION       string = "eth_signTransaction"
 	ETH_ACCOUNTS              string = "eth_accounts"
 	PERSONAL_UNLOCKACCOUNT    string = "personal_unlockAccount"
 
 	WEB3_CLIENTVERSION string = "web3_clientVersion"
+
+	METE_GETTRANSACTIONSBYADDRESS string = "mete_getTransactionsByAddress"
 )
 
 func getString(mp map[string]interface{}, k string) (string, error) {
@@ -181,6 +229,26 @@ func getParam(mp map[string]interface{}) ([]interface{}, error) {
 	return v.([]interface{}), nil
 }
 
+//getBlockTag returns the block parameter at index i: a tag, a block number or
+//the block hash or number of an EIP-1898 object. It defaults to latest.
+func getBlockTag(para []interface{}, i int) string {
+	if len(para) <= i {
+		return "latest"
+	}
+	switch v := para[i].(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if h, ok := v["blockHash"].(string); ok {
+			return h
+		}
+		if n, ok := v["blockNumber"].(string); ok {
+			return n
+		}
+	}
+	return "latest"
+}
+
 func responseErrFunc(code int, jsonRpc string, id interface{}, msg string) []byte {
 	Err := &ErrorBody{Code: code, Message: msg}
 	resp, err := json.Marshal(responseErr{JsonRPC: jsonRpc, Id: id, Error: Err})
@@ -199,6 +267,14 @@ func uint64ToHexString(val uint64) string {
 	return stringToHex(fmt.Sprintf("%X", val))
 }
 
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
+
 func hexToUint64(hxs string) (uint64, error) {
 	if len(hxs) > 2 {
 		if hxs[:2] == "0x" {
@@ -212,6 +288,52 @@ func hexToUint64(hxs string) (uint64, error) {
 	return n, nil
 }
 
+//getLogAddresses returns the address filter of eth_getLogs, an address or a
+//list of addresses
+func getLogAddresses(v interface{}) ([]common.Address, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []common.Address{common.HexToAddress(v)}, nil
+	case []interface{}:
+		addrs := make([]common.Address, 0, len(v))
+		for _, a := range v {
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid address %v", a)
+			}
+			addrs = append(addrs, common.HexToAddress(s))
+		}
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("invalid address %v", v)
+}
+
+//getLogTopics returns the topic filter of eth_getLogs, by position null for
+//any topic, a topic or a list of topics
+func getLogTopics(v []interface{}) ([][]common.Hash, error) {
+	topics := make([][]common.Hash, len(v))
+	for i, t := range v {
+		switch t := t.(type) {
+		case nil:
+		case string:
+			topics[i] = []common.Hash{common.HexToHash(t)}
+		case []interface{}:
+			for _, h := range t {
+				s, ok := h.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid topic %v", h)
+				}
+				topics[i] = append(topics[i], common.HexToHash(s))
+			}
+		default:
+			return nil, fmt.Errorf("invalid topic %v", t)
+		}
+	}
+	return topics, nil
+}
+
 var (
 	JsonMarshalErr   int = -5001
 	JsonUnmarshalErr int = -5002
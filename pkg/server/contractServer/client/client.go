@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 
 	"metechain/pkg/block"
@@ -33,19 +34,56 @@ func New(bc blockchain.Blockchains, tp *txpool.Pool, cfg *config.CfgInfo) *Clien
 	return &Client{Bc: bc, Tp: tp, Cfg: cfg}
 }
 
+//parse the block tag of a state query: nil for the latest state, which is read
+//from the live state without opening a trie
+func (c *Client) blockRef(tag string) (*blockchain.BlockRef, error) {
+	switch tag {
+	case "", "latest", "pending":
+		return nil, nil
+	case "earliest":
+		return &blockchain.BlockRef{Height: 0}, nil
+	}
+	if len(blockchain.Check0x(tag)) == 2*common.HashLength {
+		h, err := transaction.StringToHash(blockchain.Check0x(tag))
+		if err != nil {
+			return nil, err
+		}
+		return &blockchain.BlockRef{Hash: h}, nil
+	}
+	n, err := strconv.ParseUint(blockchain.Check0x(tag), 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block tag[%s]", tag)
+	}
+	return &blockchain.BlockRef{Height: n}, nil
+}
+
 //cal contract
-func (c *Client) ContractCall(origin string, contractAddr string, callInput, value string) (string, string, error) {
-	return c.Bc.CallSmartContract(contractAddr, origin, callInput, value)
+func (c *Client) ContractCall(origin string, contractAddr string, callInput, value, tag string) (string, string, error) {
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return "", "", err
+	}
+	if ref == nil {
+		return c.Bc.CallSmartContract(contractAddr, origin, callInput, value)
+	}
+	return c.Bc.CallSmartContractAt(contractAddr, origin, callInput, value, *ref)
 }
 
 //Get from Balance
-func (c *Client) GetBalance(from string) (*big.Int, error) {
+func (c *Client) GetBalance(from, tag string) (*big.Int, error) {
 	// addr, err := address.StringToAddress(from)
 	// if err != nil {
 	// 	return nil, err
 	// }
 	addr := common.HexToAddress(from)
-	return c.Bc.GetBalance(&addr)
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return c.Bc.GetBalance(&addr)
+	}
+	return c.Bc.GetBalanceAt(&addr, *ref)
 }
 
 //get block by hash
@@ -80,22 +118,41 @@ func (c *Client) GetTransactionByHash(hash string) (*transaction.FinishedTransac
 }
 
 //Get Code by contract Address
-func (c *Client) GetCode(contractAddr string) string {
-	return common.Bytes2Hex(c.Bc.GetCode(contractAddr))
+func (c *Client) GetCode(contractAddr, tag string) (string, error) {
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return "", err
+	}
+	if ref == nil {
+		return common.Bytes2Hex(c.Bc.GetCode(contractAddr)), nil
+	}
+	code, err := c.Bc.GetCodeAt(contractAddr, *ref)
+	if err != nil {
+		return "", err
+	}
+	return common.Bytes2Hex(code), nil
 }
 
 //Get address Nonce
-func (c *Client) GetNonce(from string) (uint64, error) {
+func (c *Client) GetNonce(from, tag string) (uint64, error) {
 	// addr, err := address.StringToAddress(from)
 	// if err != nil {
 	// 	return 0, err
 	// }
 	addr := common.HexToAddress(from)
-	return c.Bc.GetNonce(&addr)
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return 0, err
+	}
+	if ref == nil {
+		return c.Bc.GetNonce(&addr)
+	}
+	return c.Bc.GetNonceAt(&addr, *ref)
 }
 
-//Send signed Transaction
-func (c *Client) SendRawTransaction(rawTx string) (string, error) {
+//Send signed Transaction from the IP address ip, replaced reports whether it
+//replaced a transaction with its nonce in the pool
+func (c *Client) SendRawTransaction(rawTx, ip string) (string, bool, error) {
 	arr := strings.Split(rawTx, "0x0x0x")
 	var msgHash []byte
 	var meteFrom string
@@ -112,33 +169,33 @@ func (c *Client) SendRawTransaction(rawTx string) (string, error) {
 
 	decTX, err := hexutil.Decode(rawTx)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	var tx types.Transaction
 
 	err = rlp.DecodeBytes(decTX, &tx)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	signer := types.NewEIP2930Signer(tx.ChainId())
 	mas, err := tx.AsMessage(signer, nil)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	logger.InfoLogger.Printf("chainserver sendRawTransaction:{mas.From:%v,to:%v,amount:%v,nounce:%v,hash:%v,gas:%v,gasPrice:%v,txType:%v,chainId:%v,tx lenght:%v}\n", mas.From(), tx.To(), tx.Value(), tx.Nonce(), tx.Hash(), tx.Gas(), tx.GasPrice(), tx.Type(), tx.ChainId(), len(tx.Data()))
 
-	return c.sendRawTransaction(mas.From().Hex(), rawTx, meteFrom, msgHash)
+	return c.sendRawTransaction(mas.From().Hex(), rawTx, meteFrom, msgHash, ip)
 }
 
 //send eth signed transaction
-func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash []byte) (string, error) {
+func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash []byte, ip string) (string, bool, error) {
 	ethFrom := common.HexToAddress(EthFrom)
 	ethData := EthData
 
 	if len(ethData) <= 0 {
-		return "", fmt.Errorf("Wrong eth signed data:%s", fmt.Errorf("ethData length[%v] <= 0", len(ethData)).Error())
+		return "", false, fmt.Errorf("Wrong eth signed data:%s", fmt.Errorf("ethData length[%v] <= 0", len(ethData)).Error())
 	}
 
 	var from, to common.Address
@@ -162,10 +219,10 @@ func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash [
 
 	ethTx, err := transaction.DecodeEthData(ethData)
 	if err != nil {
-		return "", fmt.Errorf("decodeData error:%s", err.Error())
+		return "", false, fmt.Errorf("decodeData error:%s", err.Error())
 	}
 	if ethTx.ChainId().Int64() != g.Cfg.ChainCfg.ChainId {
-		return "", fmt.Errorf("error chain ID: %v", ethTx.ChainId().Int64())
+		return "", false, fmt.Errorf("error chain ID: %v", ethTx.ChainId().Int64())
 	}
 	evm.EthData = EthData
 
@@ -210,15 +267,16 @@ func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash [
 
 	input, err := transaction.EncodeEvmData(&evm)
 	if err != nil {
-		return "", fmt.Errorf("EncodeEvmData error:%s", err.Error())
+		return "", false, fmt.Errorf("EncodeEvmData error:%s", err.Error())
 	}
 
 	nonce, err := g.Bc.GetNonce(&from)
 	if err != nil {
-		return "", fmt.Errorf("GetNonce error:%s,from:%v", err.Error(), from)
+		return "", false, fmt.Errorf("GetNonce error:%s,from:%v", err.Error(), from)
 	}
-	if nonce != ethTx.Nonce() {
-		return "", fmt.Errorf("error: from [%v] nonce[%v] not equal ethTx.Nonce[%v]", from, nonce, ethTx.Nonce())
+	// transactions ahead of the nonce are queued in the pool
+	if nonce > ethTx.Nonce() {
+		return "", false, fmt.Errorf("error: from [%v] nonce[%v] greater than ethTx.Nonce[%v]", from, nonce, ethTx.Nonce())
 	}
 
 	tx := &transaction.SignedTransaction{
@@ -229,7 +287,7 @@ func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash [
 			Nonce:     ethTx.Nonce(),
 			GasLimit:  gasLimit,
 			GasPrice:  gasPrice,
-			GasFeeCap: gasLimit,
+			GasFeeCap: gasPrice,
 			Type:      tag,
 			Input:     input,
 		},
@@ -239,104 +297,164 @@ func (g *Client) sendRawTransaction(EthFrom, EthData, meteFrom string, MsgHash [
 			Data:    transaction.ParseEthSignature(&ethTx),
 		}, */
 	}
-	err = g.Tp.Add(tx)
+	replaced, err := g.Tp.AddFromIP(tx, ip)
 	if err != nil {
-		return "", fmt.Errorf("add tx pool error:%s", err.Error())
+		return "", false, fmt.Errorf("add tx pool error:%s", err.Error())
 	}
 
-	logger.Info("rpc End SendEthSignedRawTransaction:", zap.String("hash", tx.HashToString()))
-	return tx.HashToString(), nil
+	logger.Info("rpc End SendEthSignedRawTransaction:", zap.String("hash", tx.HashToString()), zap.Bool("replaced", replaced))
+	return tx.HashToString(), replaced, nil
 }
 
 //Get Transaction Receipt by hash
-func (c *Client) GetTransactionReceipt(hash string) (*transaction.FinishedTransaction, error) {
+func (c *Client) GetTransactionReceipt(hash string) (*transaction.FinishedTransaction, *transaction.Receipt, error) {
 	h, err := transaction.StringToHash(blockchain.Check0x(hash))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return c.Bc.GetTransactionByHash(h)
+	tx, err := c.Bc.GetTransactionByHash(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	receipt, err := c.Bc.GetReceipt(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, receipt, nil
 }
 
-//GetStorageAt
-func (c *Client) GetStorageAt(addr, hash string) string {
-	return c.Bc.GetStorageAt(addr, hash).Hex()
+//Get receipts of the transactions of the block
+func (c *Client) GetBlockReceipts(hash []byte) ([]*transaction.Receipt, error) {
+	return c.Bc.GetBlockReceipts(hash)
 }
 
-//get Logs
-/* func (c *Client) GetLogs(address string, fromB, toB uint64, topics []string, blockH string) []*types.Log {
-	logs := c.Bc.GetLogs()
-	var resLogs []*types.Log
-	for _, log := range logs {
-		if common.HexToHash(blockH) == log.BlockHash {
-			resLogs = append(resLogs, log)
-		} else if log.BlockNumber >= fromB && log.BlockNumber <= toB {
-			if common.HexToAddress(address) == log.Address {
-				resLogs = append(resLogs, log)
-			}
-		}
+//GetStorageAt
+func (c *Client) GetStorageAt(addr, hash, tag string) (string, error) {
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return "", err
 	}
-	return resLogs
-} */
-func (c *Client) GetLogs(address string, fromB, toB uint64, topics []string, blockH string) []*types.Log {
-
-	if fromB > toB {
-		return nil
+	if ref == nil {
+		return c.Bc.GetStorageAt(addr, hash).Hex(), nil
 	}
-
-	tipblock, err := c.Bc.Tip()
+	value, err := c.Bc.GetStorageAtBlock(addr, hash, *ref)
 	if err != nil {
-		return nil
-	}
-	if tipblock.Height < toB {
-		fmt.Printf("toblock[%v]>maxBlockHeight[%v]", toB, tipblock.Height)
-		return nil
+		return "", err
 	}
+	return value.Hex(), nil
+}
 
-	var logs []*types.Log
-
-	/* if len(blockH) != 0 {
-
+//get Logs
+func (c *Client) GetLogs(addresses []common.Address, fromB, toB uint64, topics [][]common.Hash, blockH string) ([]*types.Log, error) {
+	if len(blockH) != 0 {
 		hash, err := transaction.StringToHash(blockchain.Check0x(blockH))
 		if err != nil {
-			fmt.Printf("invalid hash[%s]", blockH)
-			return nil
+			return nil, fmt.Errorf("invalid hash[%s]", blockH)
 		}
 
-		tx, err := c.Bc.GetTransactionByHash(hash)
+		b, err := c.Bc.GetBlockByHash(hash)
 		if err != nil {
-			fmt.Printf("invalid hash[%s]", hash)
-			return nil
+			return nil, fmt.Errorf("block %s: %v", blockH, err)
 		}
-		log := c.Bc.GetLogByHeight(tx.BlockNum)
+		fromB, toB = b.Height, b.Height
+	}
+	return c.Bc.FilterLogs(fromB, toB, addresses, topics)
+}
 
-		return log
-	} */
-	if len(blockH) != 0 {
+//Get logs bloom of the block
+func (c *Client) GetBlockBloom(hash []byte) (types.Bloom, error) {
+	return c.Bc.GetBlockBloom(hash)
+}
 
-		hash, err := transaction.StringToHash(blockchain.Check0x(blockH))
-		if err != nil {
-			fmt.Printf("invalid hash[%s]", blockH)
-			return nil
-		}
+//Get Max BlockNumber
+func (c *Client) GetMaxBlockNumber() (uint64, error) {
+	return c.Bc.GetMaxBlockHeight()
+}
 
-		b, err := c.Bc.GetBlockByHash(hash)
+//height of the block the tag refers to
+func (c *Client) blockHeight(tag string) (uint64, error) {
+	ref, err := c.blockRef(tag)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case ref == nil:
+		return c.Bc.GetMaxBlockHeight()
+	case len(ref.Hash) > 0:
+		b, err := c.Bc.GetBlockByHash(ref.Hash)
 		if err != nil {
-			fmt.Printf("invalid hash[%s]", hash)
-			return nil
+			return 0, err
 		}
-		log := c.Bc.GetLogByHeight(b.Height)
+		return b.Height, nil
+	default:
+		return ref.Height, nil
+	}
+}
+
+//Get account and storage proofs at the block
+func (c *Client) GetProof(addr string, keys []string, tag string) (*blockchain.AccountProof, error) {
+	num, err := c.blockHeight(tag)
+	if err != nil {
+		return nil, err
+	}
 
-		return log
+	hashes := make([]common.Hash, len(keys))
+	for i, key := range keys {
+		hashes[i] = common.HexToHash(key)
 	}
-	for i := fromB; i <= toB; i++ {
-		log := c.Bc.GetLogByHeight(i)
-		logs = append(logs, log...)
+	return c.Bc.GetProof(common.HexToAddress(addr), hashes, num)
+}
+
+//Get the gas price to pay for a transaction in the next blocks: the base fee
+//of the next block and the suggested tip on top
+func (c *Client) GasPrice() (*big.Int, error) {
+	baseFee, err := c.Bc.GetBaseFee()
+	if err != nil {
+		return nil, err
+	}
+	tip, err := c.Bc.SuggestTipCap()
+	if err != nil {
+		return nil, err
 	}
-	return logs
+	return tip.Add(tip, baseFee), nil
+}
 
+//Get the suggested tip to pay the miner per gas
+func (c *Client) MaxPriorityFeePerGas() (*big.Int, error) {
+	return c.Bc.SuggestTipCap()
 }
 
-//Get Max BlockNumber
-func (c *Client) GetMaxBlockNumber() (uint64, error) {
-	return c.Bc.GetMaxBlockHeight()
+//Get the fee history of count blocks up to the newest block
+func (c *Client) FeeHistory(count uint64, newest string, percentiles []float64) (*blockchain.FeeHistory, error) {
+	num, err := c.blockHeight(newest)
+	if err != nil {
+		return nil, err
+	}
+	return c.Bc.FeeHistory(count, num, percentiles)
+}
+
+// GetTransactionsByAddress get a page of the transactions of the address, the
+// newest first unless direction is "asc", and the cursor of the next page
+func (c *Client) GetTransactionsByAddress(addr, cursor string, limit int, direction string) ([]*transaction.FinishedTransaction, string, error) {
+	dir := blockchain.Descending
+	switch direction {
+	case "", "desc":
+	case "asc":
+		dir = blockchain.Ascending
+	default:
+		return nil, "", fmt.Errorf("unknown direction %q", direction)
+	}
+	pos, err := hex.DecodeString(blockchain.Check0x(cursor))
+	if err != nil {
+		return nil, "", blockchain.ErrCursor
+	}
+
+	txs, next, err := c.Bc.GetTransactionsByAddress(common.HexToAddress(addr), pos, limit, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if next == nil {
+		return txs, "", nil
+	}
+	return txs, "0x" + hex.EncodeToString(next), nil
 }
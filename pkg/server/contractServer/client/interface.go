@@ -3,8 +3,10 @@ package client
 import (
 	"math/big"
 
+	"metechain/pkg/blockchain"
 	"metechain/pkg/transaction"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 
 	"metechain/pkg/block"
@@ -12,29 +14,43 @@ import (
 
 type Clients interface {
 	//call contract
-	ContractCall(origin string, contractAddr string, callInput, value string) (string, string, error)
+	ContractCall(origin string, contractAddr string, callInput, value, tag string) (string, string, error)
 	//get balance by from
-	GetBalance(from string) (*big.Int, error)
+	GetBalance(from, tag string) (*big.Int, error)
 	//get block by hash
 	GetBlockByHash(hash string) (*block.Block, error)
 	//get block by number
 	GetBlockByNumber(num uint64) (*block.Block, error)
 	//get code by contract address
-	GetCode(contractAddr string) string
+	GetCode(contractAddr, tag string) (string, error)
 	//get nonce by address
-	GetNonce(addr string) (uint64, error)
+	GetNonce(addr, tag string) (uint64, error)
 	//get transaction by hash
 	GetTransactionByHash(hash string) (*transaction.FinishedTransaction, error)
-	//send signed transaction
-	SendRawTransaction(rawTx string) (string, error)
+	//send signed transaction, replaced reports whether it replaced one in the pool
+	SendRawTransaction(rawTx, ip string) (hash string, replaced bool, err error)
 	//get transaction receipt
-	GetTransactionReceipt(hash string) (*transaction.FinishedTransaction, error)
+	GetTransactionReceipt(hash string) (*transaction.FinishedTransaction, *transaction.Receipt, error)
+	//get receipts of the transactions of the block
+	GetBlockReceipts(hash []byte) ([]*transaction.Receipt, error)
 
 	//get Storage by address and hash
-	GetStorageAt(addr, hash string) string
+	GetStorageAt(addr, hash, tag string) (string, error)
 	//get logs
-	GetLogs(address string, fromB, toB uint64, topics []string, blockH string) []*types.Log
+	GetLogs(addresses []common.Address, fromB, toB uint64, topics [][]common.Hash, blockH string) ([]*types.Log, error)
+	//get logs bloom of the block
+	GetBlockBloom(hash []byte) (types.Bloom, error)
 	//get max block number
 	GetMaxBlockNumber() (uint64, error)
+	//get account and storage proofs at the block
+	GetProof(addr string, keys []string, tag string) (*blockchain.AccountProof, error)
+	//get the gas price to pay in the next blocks
+	GasPrice() (*big.Int, error)
+	//get the suggested tip per gas
+	MaxPriorityFeePerGas() (*big.Int, error)
+	//get the fee history of blocks up to the newest block
+	FeeHistory(count uint64, newest string, percentiles []float64) (*blockchain.FeeHistory, error)
+	//get a page of the transactions of the address and the cursor of the next page
+	GetTransactionsByAddress(addr, cursor string, limit int, direction string) ([]*transaction.FinishedTransaction, string, error)
 	//	AddressToCommonAddr(address address.Address) (common.Address, error)
 }
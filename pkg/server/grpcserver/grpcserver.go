@@ -20,6 +20,7 @@ import (
 	"metechain/pkg/server/grpcserver/message"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gorilla/handlers"
 	"github.com/pkg/errors"
@@ -33,6 +34,7 @@ import (
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -164,7 +166,7 @@ func (g *Greeter) SendTransaction(ctx context.Context, in *message.SendTransacti
 		Signature: signature,
 	}
 
-	err = g.Tp.Add(tx)
+	replaced, err := g.Tp.AddFromIP(tx, remoteIP(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +182,23 @@ func (g *Greeter) SendTransaction(ctx context.Context, in *message.SendTransacti
 
 	hash := hex.EncodeToString(tx.Hash())
 
-	return &message.SendTransactionResponse{Hash: hash}, nil
+	return &message.SendTransactionResponse{Hash: hash, Replaced: replaced}, nil
+}
+
+// remoteIP is the IP address a gRPC call or a request to the HTTP gateway
+// was sent from
+func remoteIP(ctx context.Context) string {
+	var addr string
+	if req, ok := http.RequestFromServerContext(ctx); ok {
+		addr = req.RemoteAddr
+	} else if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return host
 }
 
 func (g *Greeter) GetBlockByNum(ctx context.Context, in *message.ReqBlockByNumber) (*message.RespBlock, error) {
@@ -285,24 +303,7 @@ func (g *Greeter) GetBlockDetails(ctx context.Context, req *message.GetBlockDeta
 	}
 
 	for _, tx := range block.Transactions {
-		rtx := message.FinalTransaction{
-			Stx: &message.SignedTransaction{
-				Utx: &message.UnsignedTransaction{
-					From:      tx.From.Hex(),
-					To:        tx.To.Hex(),
-					Amount:    tx.Amount.String(),
-					Nonce:     tx.Nonce,
-					GasLimit:  tx.GasLimit.String(),
-					GasPrice:  tx.GasPrice.String(),
-					GasFeeCap: tx.GasFeeCap.String(),
-				},
-				Signature: hex.EncodeToString(tx.Signature),
-			},
-			GasUsed:  tx.GasUsed.String(),
-			BlockNum: tx.BlockNum,
-		}
-
-		replay.Ftxs = append(replay.Ftxs, &rtx)
+		replay.Ftxs = append(replay.Ftxs, finalTransaction(tx))
 	}
 
 	return replay, nil
@@ -337,9 +338,33 @@ func (g *Greeter) GetTransactionDetails(ctx context.Context, in *message.GetTran
 		BlockNum:  tx.BlockNum,
 	}
 
+	if receipt, err := g.Bc.GetReceipt(hash); err == nil {
+		replay.Status = receipt.Status
+		replay.GasUsed = receipt.GasUsed.String()
+		replay.CumulativeGasUsed = receipt.CumulativeGasUsed.String()
+		replay.ContractAddress = receipt.ContractAddress.Hex()
+		replay.LogsBloom = hex.EncodeToString(receipt.Bloom.Bytes())
+		replay.RevertReason = receipt.RevertReason
+		for _, log := range receipt.Logs {
+			replay.Logs = append(replay.Logs, transactionLog(log))
+		}
+	}
+
 	return replay, nil
 }
 
+func transactionLog(log *types.Log) *message.TransactionLog {
+	tl := &message.TransactionLog{
+		Address:  log.Address.Hex(),
+		Data:     log.Data,
+		LogIndex: uint64(log.Index),
+	}
+	for _, topic := range log.Topics {
+		tl.Topics = append(tl.Topics, topic.Hex())
+	}
+	return tl
+}
+
 func (g *Greeter) Sign(ctx context.Context, in *message.SginRequest) (*message.SginResponse, error) {
 	privData, err := hex.DecodeString(in.Priv)
 	if err != nil {
@@ -390,3 +415,69 @@ func (g *Greeter) Sign(ctx context.Context, in *message.SginRequest) (*message.S
 
 	return &message.SginResponse{Signature: hex.EncodeToString(signature)}, nil
 }
+
+// GetTransactionProof returns the transaction hash's merkle proof with the header of its block
+func (g *Greeter) GetTransactionProof(ctx context.Context, in *message.ReqTxProof) (*message.RespTxProof, error) {
+	hash, _ := transaction.StringToHash(blockchain.Check0x(in.Hash))
+	p, err := g.Bc.GetTransactionProof(hash)
+	if err != nil {
+		return &message.RespTxProof{Code: -1, Message: err.Error()}, nil
+	}
+
+	header, err := p.Header.Serialize()
+	if err != nil {
+		logger.Error("block Serialize", zap.String("error", err.Error()))
+		return &message.RespTxProof{Code: -1, Message: err.Error()}, nil
+	}
+	proof, err := p.Proof.Serialize()
+	if err != nil {
+		return &message.RespTxProof{Code: -1, Message: err.Error()}, nil
+	}
+
+	return &message.RespTxProof{Header: header, Index: p.Index, Leaf: p.Leaf, Proof: proof, Code: 0}, nil
+}
+
+// GetTransactionsByAddress returns a page of the transactions sent or received by the address
+func (g *Greeter) GetTransactionsByAddress(ctx context.Context, in *message.ReqTxsByAddress) (*message.RespTxsByAddress, error) {
+	cursor, err := hex.DecodeString(blockchain.Check0x(in.Cursor))
+	if err != nil {
+		return &message.RespTxsByAddress{Code: -1, Message: blockchain.ErrCursor.Error()}, nil
+	}
+	dir := blockchain.Descending
+	if in.Ascending {
+		dir = blockchain.Ascending
+	}
+
+	txs, next, err := g.Bc.GetTransactionsByAddress(common.HexToAddress(in.Address), cursor, int(in.Limit), dir)
+	if err != nil {
+		return &message.RespTxsByAddress{Code: -1, Message: err.Error()}, nil
+	}
+
+	resp := &message.RespTxsByAddress{Code: 0}
+	for _, tx := range txs {
+		resp.Txs = append(resp.Txs, finalTransaction(tx))
+	}
+	if next != nil {
+		resp.Cursor = hex.EncodeToString(next)
+	}
+	return resp, nil
+}
+
+func finalTransaction(tx *transaction.FinishedTransaction) *message.FinalTransaction {
+	return &message.FinalTransaction{
+		Stx: &message.SignedTransaction{
+			Utx: &message.UnsignedTransaction{
+				From:      tx.From.Hex(),
+				To:        tx.To.Hex(),
+				Amount:    tx.Amount.String(),
+				Nonce:     tx.Nonce,
+				GasLimit:  tx.GasLimit.String(),
+				GasPrice:  tx.GasPrice.String(),
+				GasFeeCap: tx.GasFeeCap.String(),
+			},
+			Signature: hex.EncodeToString(tx.Signature),
+		},
+		GasUsed:  tx.GasUsed.String(),
+		BlockNum: tx.BlockNum,
+	}
+}
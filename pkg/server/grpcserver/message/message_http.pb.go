@@ -26,6 +26,8 @@ const OperationGreeterGetBlockByNum = "/message.Greeter/GetBlockByNum"
 const OperationGreeterGetBlockDetails = "/message.Greeter/GetBlockDetails"
 const OperationGreeterGetMaxBlockHeight = "/message.Greeter/GetMaxBlockHeight"
 const OperationGreeterGetTransactionDetails = "/message.Greeter/GetTransactionDetails"
+const OperationGreeterGetTransactionProof = "/message.Greeter/GetTransactionProof"
+const OperationGreeterGetTransactionsByAddress = "/message.Greeter/GetTransactionsByAddress"
 const OperationGreeterGetTxByHash = "/message.Greeter/GetTxByHash"
 const OperationGreeterSendTransaction = "/message.Greeter/SendTransaction"
 const OperationGreeterSign = "/message.Greeter/Sign"
@@ -38,6 +40,8 @@ type GreeterHTTPServer interface {
 	GetBlockDetails(context.Context, *GetBlockDetailsRequest) (*GetBlockDetailsResponse, error)
 	GetMaxBlockHeight(context.Context, *ReqMaxBlockHeight) (*ResMaxBlockHeight, error)
 	GetTransactionDetails(context.Context, *GetTransactionDetailsRequest) (*GetTransactionDetailsResponse, error)
+	GetTransactionProof(context.Context, *ReqTxProof) (*RespTxProof, error)
+	GetTransactionsByAddress(context.Context, *ReqTxsByAddress) (*RespTxsByAddress, error)
 	GetTxByHash(context.Context, *ReqTxByHash) (*RespTxByHash, error)
 	SendTransaction(context.Context, *SendTransactionRequest) (*SendTransactionResponse, error)
 	Sign(context.Context, *SginRequest) (*SginResponse, error)
@@ -55,6 +59,8 @@ func RegisterGreeterHTTPServer(s *http.Server, srv GreeterHTTPServer) {
 	r.GET("/block/details/{height}", _Greeter_GetBlockDetails0_HTTP_Handler(srv))
 	r.GET("/transaction/details/{hash}", _Greeter_GetTransactionDetails0_HTTP_Handler(srv))
 	r.POST("/transaction/sign", _Greeter_Sign0_HTTP_Handler(srv))
+	r.GET("/transaction/proof/{hash}", _Greeter_GetTransactionProof0_HTTP_Handler(srv))
+	r.GET("/transaction/address/{address}", _Greeter_GetTransactionsByAddress0_HTTP_Handler(srv))
 }
 
 func _Greeter_GetBalance0_HTTP_Handler(srv GreeterHTTPServer) func(ctx http.Context) error {
@@ -265,6 +271,50 @@ func _Greeter_Sign0_HTTP_Handler(srv GreeterHTTPServer) func(ctx http.Context) e
 	}
 }
 
+func _Greeter_GetTransactionProof0_HTTP_Handler(srv GreeterHTTPServer) func(ctx http.Context) error {
+	return func(ctx http.Context) error {
+		var in ReqTxProof
+		if err := ctx.BindQuery(&in); err != nil {
+			return err
+		}
+		if err := ctx.BindVars(&in); err != nil {
+			return err
+		}
+		http.SetOperation(ctx, OperationGreeterGetTransactionProof)
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.GetTransactionProof(ctx, req.(*ReqTxProof))
+		})
+		out, err := h(ctx, &in)
+		if err != nil {
+			return err
+		}
+		reply := out.(*RespTxProof)
+		return ctx.Result(200, reply)
+	}
+}
+
+func _Greeter_GetTransactionsByAddress0_HTTP_Handler(srv GreeterHTTPServer) func(ctx http.Context) error {
+	return func(ctx http.Context) error {
+		var in ReqTxsByAddress
+		if err := ctx.BindQuery(&in); err != nil {
+			return err
+		}
+		if err := ctx.BindVars(&in); err != nil {
+			return err
+		}
+		http.SetOperation(ctx, OperationGreeterGetTransactionsByAddress)
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.GetTransactionsByAddress(ctx, req.(*ReqTxsByAddress))
+		})
+		out, err := h(ctx, &in)
+		if err != nil {
+			return err
+		}
+		reply := out.(*RespTxsByAddress)
+		return ctx.Result(200, reply)
+	}
+}
+
 type GreeterHTTPClient interface {
 	GetAddressNonceAt(ctx context.Context, req *ReqNonce, opts ...http.CallOption) (rsp *ResposeNonce, err error)
 	GetBalance(ctx context.Context, req *ReqBalance, opts ...http.CallOption) (rsp *ResBalance, err error)
@@ -273,6 +323,8 @@ type GreeterHTTPClient interface {
 	GetBlockDetails(ctx context.Context, req *GetBlockDetailsRequest, opts ...http.CallOption) (rsp *GetBlockDetailsResponse, err error)
 	GetMaxBlockHeight(ctx context.Context, req *ReqMaxBlockHeight, opts ...http.CallOption) (rsp *ResMaxBlockHeight, err error)
 	GetTransactionDetails(ctx context.Context, req *GetTransactionDetailsRequest, opts ...http.CallOption) (rsp *GetTransactionDetailsResponse, err error)
+	GetTransactionProof(ctx context.Context, req *ReqTxProof, opts ...http.CallOption) (rsp *RespTxProof, err error)
+	GetTransactionsByAddress(ctx context.Context, req *ReqTxsByAddress, opts ...http.CallOption) (rsp *RespTxsByAddress, err error)
 	GetTxByHash(ctx context.Context, req *ReqTxByHash, opts ...http.CallOption) (rsp *RespTxByHash, err error)
 	SendTransaction(ctx context.Context, req *SendTransactionRequest, opts ...http.CallOption) (rsp *SendTransactionResponse, err error)
 	Sign(ctx context.Context, req *SginRequest, opts ...http.CallOption) (rsp *SginResponse, err error)
@@ -377,6 +429,32 @@ func (c *GreeterHTTPClientImpl) GetTransactionDetails(ctx context.Context, in *G
 	return &out, err
 }
 
+func (c *GreeterHTTPClientImpl) GetTransactionProof(ctx context.Context, in *ReqTxProof, opts ...http.CallOption) (*RespTxProof, error) {
+	var out RespTxProof
+	pattern := "/transaction/proof/{hash}"
+	path := binding.EncodeURL(pattern, in, true)
+	opts = append(opts, http.Operation(OperationGreeterGetTransactionProof))
+	opts = append(opts, http.PathTemplate(pattern))
+	err := c.cc.Invoke(ctx, "GET", path, nil, &out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &out, err
+}
+
+func (c *GreeterHTTPClientImpl) GetTransactionsByAddress(ctx context.Context, in *ReqTxsByAddress, opts ...http.CallOption) (*RespTxsByAddress, error) {
+	var out RespTxsByAddress
+	pattern := "/transaction/address/{address}"
+	path := binding.EncodeURL(pattern, in, true)
+	opts = append(opts, http.Operation(OperationGreeterGetTransactionsByAddress))
+	opts = append(opts, http.PathTemplate(pattern))
+	err := c.cc.Invoke(ctx, "GET", path, nil, &out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &out, err
+}
+
 func (c *GreeterHTTPClientImpl) GetTxByHash(ctx context.Context, in *ReqTxByHash, opts ...http.CallOption) (*RespTxByHash, error) {
 	var out RespTxByHash
 	pattern := "/transaction/{hash}"
@@ -55,6 +55,7 @@ func New(hash hash.Hash, data [][]byte) *MerkleTree {
 	}
 	r := &MerkleTree{
 		hash: hash,
+		size: n,
 	}
 	r.tree = r.mkMerkleTreeRoot(n, data)
 	return r
@@ -4,6 +4,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMerkle(t *testing.T) {
@@ -20,3 +22,42 @@ func TestPowerTon(t *testing.T) {
 		t.Logf("i:%d,i&(i-1):%d,poweroftwo:%d\n", i, i&(i-1), powerOfTwo(i))
 	}
 }
+
+func TestProof(t *testing.T) {
+	assert := assert.New(t)
+	for n := 1; n <= 17; n++ {
+		var data [][]byte
+		for i := 0; i < n; i++ {
+			data = append(data, []byte(fmt.Sprintf("tx %d", i)))
+		}
+		tree := New(sha256.New(), data)
+		root := tree.GetMtHash()
+		for i := range data {
+			proof, err := tree.Proof(i)
+			assert.NoError(err)
+			assert.True(VerifyProof(root, data[i], proof), "leaf %d of %d", i, n)
+			assert.False(VerifyProof(root, []byte("other"), proof), "leaf %d of %d", i, n)
+
+			enc, err := proof.Serialize()
+			assert.NoError(err)
+			dec, err := DeserializeProof(enc)
+			assert.NoError(err)
+			assert.True(VerifyProof(root, data[i], dec))
+		}
+		_, err := tree.Proof(n)
+		assert.Equal(ErrIndex, err)
+	}
+}
+
+func TestDeserializeProof(t *testing.T) {
+	assert := assert.New(t)
+	proof := &Proof{Steps: []ProofStep{{Left: true, Hash: []byte{1, 2}}, {Hash: []byte{3}}}}
+	enc, err := proof.Serialize()
+	assert.NoError(err)
+	assert.Equal([]byte{1, 0, 2, 1, 2, 1, 2, 0, 1, 3}, enc)
+
+	for _, bad := range [][]byte{nil, {2, 0, 0}, enc[:len(enc)-1], append(enc, 0)} {
+		_, err := DeserializeProof(bad)
+		assert.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// proofVersion is the first byte of a serialized proof.
+const proofVersion = 1
+
+var ErrIndex = errors.New("merkle: leaf index out of range")
+
+// Proof proves that a leaf is in a tree: the hashes of the siblings on the
+// path from the leaf up to the root.
+type Proof struct {
+	Steps []ProofStep
+}
+
+// ProofStep is one sibling on the path of a Proof.
+type ProofStep struct {
+	Left bool // the sibling is the left child
+	Hash []byte
+}
+
+// Proof returns the proof for the leaf at index.
+func (t *MerkleTree) Proof(index int) (*Proof, error) {
+	if t == nil || index < 0 || index >= t.size {
+		return nil, ErrIndex
+	}
+	var steps []ProofStep
+	node, n := t.GetMtRoot(), t.size
+	// the tree splits n leaves at powerOfTwo(n), as mkMerkleTreeRoot does
+	for n > 1 {
+		i := powerOfTwo(n)
+		if index < i {
+			steps = append(steps, ProofStep{Left: false, Hash: node.rightNode().getMKHash()})
+			node, n = node.leftNode(), i
+		} else {
+			steps = append(steps, ProofStep{Left: true, Hash: node.leftNode().getMKHash()})
+			node, n, index = node.rightNode(), n-i, index-i
+		}
+	}
+	// leaf first
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return &Proof{Steps: steps}, nil
+}
+
+// VerifyProof reports whether proof proves that leaf is in the tree with the
+// given root. Trees are assumed to be built with sha256, as the transaction
+// roots of blocks are.
+func VerifyProof(root, leaf []byte, proof *Proof) bool {
+	if proof == nil || len(leaf) == 0 {
+		return false
+	}
+	h := sha256.Sum256(leaf)
+	cur := h[:]
+	for _, step := range proof.Steps {
+		if step.Left {
+			h = sha256.Sum256(append(append([]byte{}, step.Hash...), cur...))
+		} else {
+			h = sha256.Sum256(append(append([]byte{}, cur...), step.Hash...))
+		}
+		cur = h[:]
+	}
+	return bytes.Equal(cur, root)
+}
+
+// Serialize encodes the proof as a version byte and a big-endian uint16
+// step count, followed by a side byte (1 if the sibling is on the left), a
+// hash length byte and the hash for every step, leaf first.
+func (p *Proof) Serialize() ([]byte, error) {
+	if len(p.Steps) > 0xffff {
+		return nil, fmt.Errorf("merkle: proof of %d steps", len(p.Steps))
+	}
+	buf := []byte{proofVersion, 0, 0}
+	binary.BigEndian.PutUint16(buf[1:], uint16(len(p.Steps)))
+	for _, step := range p.Steps {
+		if len(step.Hash) > 0xff {
+			return nil, fmt.Errorf("merkle: hash of %d bytes", len(step.Hash))
+		}
+		var side byte
+		if step.Left {
+			side = 1
+		}
+		buf = append(buf, side, byte(len(step.Hash)))
+		buf = append(buf, step.Hash...)
+	}
+	return buf, nil
+}
+
+// DeserializeProof decodes a proof encoded by Serialize.
+func DeserializeProof(data []byte) (*Proof, error) {
+	if len(data) < 3 || data[0] != proofVersion {
+		return nil, errors.New("merkle: not a proof")
+	}
+	n := int(binary.BigEndian.Uint16(data[1:3]))
+	data = data[3:]
+	p := &Proof{Steps: make([]ProofStep, 0, n)}
+	for i := 0; i < n; i++ {
+		if len(data) < 2 || data[0] > 1 || len(data) < 2+int(data[1]) {
+			return nil, fmt.Errorf("merkle: bad proof step %d", i)
+		}
+		size := int(data[1])
+		p.Steps = append(p.Steps, ProofStep{
+			Left: data[0] == 1,
+			Hash: append([]byte{}, data[2:2+size]...),
+		})
+		data = data[2+size:]
+	}
+	if len(data) != 0 {
+		return nil, errors.New("merkle: trailing bytes after proof")
+	}
+	return p, nil
+}
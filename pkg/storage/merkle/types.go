@@ -43,6 +43,7 @@ type MerkleNode struct {
 type MerkleTree struct {
 	tree MKNode
 	hash hash.Hash
+	size int // number of leaves
 }
 
 type MerkleProof []ProofElem
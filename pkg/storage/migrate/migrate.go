@@ -0,0 +1,269 @@
+// Package migrate copies a node database between storage engines.
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"metechain/pkg/blockchain"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+	"metechain/pkg/storage/store/engine"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+const (
+	// DefaultBatchKeys is the number of keys written per transaction.
+	DefaultBatchKeys = 1000
+	// DefaultBatchBytes caps the size of a transaction, badger rejects
+	// transactions that grow too big.
+	DefaultBatchBytes = 4 << 20
+	// DefaultSamples is the number of state roots checked by Verify.
+	DefaultSamples = 32
+)
+
+// Progress is called after each batch with the totals copied so far.
+type Progress func(keys int, size int64)
+
+// ErrNotEmpty is returned by Copy when the target already holds data.
+var ErrNotEmpty = errors.New("target database is not empty")
+
+// Copy streams every key of src into dst in batches and returns the number
+// of keys copied. The engine marker is left alone, dst keeps its own.
+func Copy(src, dst store.DB, progress Progress) (int, error) {
+	if !empty(dst) {
+		return 0, ErrNotEmpty
+	}
+
+	itr := src.NewIterator(nil, nil)
+	defer itr.Release()
+
+	var (
+		keys, n int
+		size    int64
+		batch   int
+		tx      = dst.NewTransaction()
+	)
+	defer func() { tx.Cancel() }()
+
+	for itr.Next() {
+		k, v := itr.Key(), itr.Value()
+		if bytes.Equal(k, engine.EngineKey) {
+			continue
+		}
+		if err := tx.Set(k, v); err != nil {
+			return keys, err
+		}
+		n++
+		batch += len(k) + len(v)
+		if n < DefaultBatchKeys && batch < DefaultBatchBytes {
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			return keys, err
+		}
+		keys, size = keys+n, size+int64(batch)
+		n, batch = 0, 0
+		tx = dst.NewTransaction()
+		if progress != nil {
+			progress(keys, size)
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return keys, err
+	}
+	if err := tx.Commit(); err != nil {
+		return keys, err
+	}
+	keys, size = keys+n, size+int64(batch)
+	if progress != nil {
+		progress(keys, size)
+	}
+	return keys, dst.Sync()
+}
+
+// CopyAncients appends the blocks of the freezer src to the empty freezer dst
+// and returns how many were copied. The database copied by Copy finds the
+// blocks it moved to a freezer in dst.
+func CopyAncients(src, dst *bgdb.Freezer) (uint64, error) {
+	have, err := dst.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if have > 0 {
+		return 0, ErrNotEmpty
+	}
+	n, err := src.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	for i := uint64(0); i < n; i++ {
+		var items [5][]byte
+		for j, kind := range []string{bgdb.FreezerHashTable, bgdb.FreezerHeaderTable, bgdb.FreezerBodiesTable,
+			bgdb.FreezerReceiptTable, bgdb.FreezerDifficultyTable} {
+			if items[j], err = src.Ancient(kind, i); err != nil {
+				return i, fmt.Errorf("%s %d: %w", kind, i, err)
+			}
+		}
+		if err := dst.AppendAncient(i, items[0], items[1], items[2], items[3], items[4]); err != nil {
+			return i, err
+		}
+	}
+	return n, dst.Sync()
+}
+
+// empty reports whether db holds nothing but its engine marker.
+func empty(db store.DB) bool {
+	itr := db.NewIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		if !bytes.Equal(itr.Key(), engine.EngineKey) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks that dst holds the same chain tip, snapshot root and a
+// sample of per-block state roots as src, and that those roots resolve in
+// the state trie of dst.
+func Verify(src, dst store.DB, samples int) error {
+	for _, k := range [][]byte{blockchain.HeightKey, blockchain.SnapRootKey, blockchain.FrozenKey} {
+		if err := sameValue(src, dst, k); err != nil {
+			return err
+		}
+	}
+
+	hb, err := src.Get(blockchain.HeightKey)
+	if err == store.NotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	height, err := miscellaneous.D64func(hb)
+	if err != nil {
+		return err
+	}
+	tip := append(blockchain.HeightPrefix, miscellaneous.E64func(height)...)
+	if err := sameValue(src, dst, tip); err != nil {
+		return fmt.Errorf("tip hash: %w", err)
+	}
+	hash, err := dst.Get(tip)
+	if err != nil {
+		return fmt.Errorf("tip hash: %w", err)
+	}
+	if err := sameValue(src, dst, hash); err != nil {
+		return fmt.Errorf("tip block: %w", err)
+	}
+
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(dst))
+	root, err := dst.Get(blockchain.SnapRootKey)
+	if err == nil {
+		if _, err := state.New(common.BytesToHash(root), sdb, nil); err != nil {
+			return fmt.Errorf("snapshot root %x: %w", root, err)
+		}
+	}
+
+	pruned, err := blockchain.PrunedHeight(dst)
+	if err != nil {
+		return err
+	}
+	for _, h := range sampleHeights(height, samples) {
+		k := append(blockchain.SnapRootPrefix, miscellaneous.E64func(h)...)
+		if err := sameValue(src, dst, k); err != nil {
+			return fmt.Errorf("state root at %d: %w", h, err)
+		}
+		// the state below the pruned height is gone in both
+		if h < pruned {
+			continue
+		}
+		root, err := dst.Get(k)
+		if err == store.NotExist {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, err := state.New(common.BytesToHash(root), sdb, nil); err != nil {
+			return fmt.Errorf("state root at %d: %w", h, err)
+		}
+	}
+	return nil
+}
+
+// VerifyAncients checks that the freezer dstF holds as many blocks as srcF,
+// that a sample of them is the same in both and that dst finds them by hash.
+func VerifyAncients(dst store.DB, srcF, dstF *bgdb.Freezer, samples int) error {
+	n, err := srcF.Ancients()
+	if err != nil {
+		return err
+	}
+	m, err := dstF.Ancients()
+	if err != nil {
+		return err
+	}
+	if n != m {
+		return fmt.Errorf("freezer holds %d blocks, want %d", m, n)
+	}
+	// sampleHeights counts from 1, the freezer from 0
+	for _, h := range sampleHeights(n, samples) {
+		for _, kind := range []string{bgdb.FreezerHashTable, bgdb.FreezerBodiesTable, bgdb.FreezerReceiptTable} {
+			a, err := srcF.Ancient(kind, h-1)
+			if err != nil {
+				return err
+			}
+			b, err := dstF.Ancient(kind, h-1)
+			if err != nil {
+				return fmt.Errorf("frozen block %d: %w", h-1, err)
+			}
+			if !bytes.Equal(a, b) {
+				return fmt.Errorf("frozen block %d: %s: %w", h-1, kind, errMismatch)
+			}
+		}
+		hash, err := dstF.Ancient(bgdb.FreezerHashTable, h-1)
+		if err != nil {
+			return err
+		}
+		if _, err := blockchain.ReadBlock(dst, dstF, hash); err != nil {
+			return fmt.Errorf("frozen block %d: %w", h-1, err)
+		}
+	}
+	return nil
+}
+
+// sampleHeights spreads n heights evenly over [1, height], always including height.
+func sampleHeights(height uint64, n int) []uint64 {
+	if height == 0 || n <= 0 {
+		return nil
+	}
+	if uint64(n) > height {
+		n = int(height)
+	}
+	hs := make([]uint64, 0, n)
+	step := height / uint64(n)
+	for h := height; len(hs) < n; h -= step {
+		hs = append(hs, h)
+	}
+	return hs
+}
+
+var errMismatch = errors.New("value differs")
+
+func sameValue(src, dst store.DB, k []byte) error {
+	a, aerr := src.Get(k)
+	b, berr := dst.Get(k)
+	switch {
+	case aerr == store.NotExist && berr == store.NotExist:
+		return nil
+	case aerr != nil:
+		return aerr
+	case berr != nil:
+		return fmt.Errorf("%q: %w", k, berr)
+	case !bytes.Equal(a, b):
+		return fmt.Errorf("%q: %w", k, errMismatch)
+	}
+	return nil
+}
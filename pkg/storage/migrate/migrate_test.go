@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"metechain/pkg/blockchain"
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg/bgdb"
+	"metechain/pkg/storage/store/engine"
+	"metechain/pkg/storage/store/mem"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChain writes the chain keys Verify looks at, with a real state root.
+func fakeChain(t *testing.T, db store.DB) common.Hash {
+	assert := assert.New(t)
+	sdb := state.NewDatabase(bgdb.NewBadgerDatabase(db))
+	st, err := state.New(common.Hash{}, sdb, nil)
+	assert.NoError(err)
+	st.SetBalance(common.HexToAddress("0x01"), big.NewInt(100))
+	root, err := st.Commit(false)
+	assert.NoError(err)
+	assert.NoError(sdb.TrieDB().Commit(root, false, nil))
+
+	hash := []byte("blockhash")
+	assert.NoError(db.Set(blockchain.HeightKey, miscellaneous.E64func(1)))
+	assert.NoError(db.Set(blockchain.SnapRootKey, root.Bytes()))
+	assert.NoError(db.Set(append(blockchain.HeightPrefix, miscellaneous.E64func(1)...), hash))
+	assert.NoError(db.Set(append(blockchain.SnapRootPrefix, miscellaneous.E64func(1)...), root.Bytes()))
+	assert.NoError(db.Set(hash, []byte("block")))
+	return root
+}
+
+func TestCopy(t *testing.T) {
+	assert := assert.New(t)
+	src, dst := mem.New(), mem.New()
+	assert.NoError(src.Set(engine.EngineKey, []byte(engine.Pebble)))
+	assert.NoError(dst.Set(engine.EngineKey, []byte(engine.Badger)))
+	for i := 0; i < 2*DefaultBatchKeys+5; i++ {
+		assert.NoError(src.Mset([]byte("m"), []byte(fmt.Sprint(i)), []byte("v")))
+	}
+	root := fakeChain(t, src)
+
+	var calls int
+	n, err := Copy(src, dst, func(int, int64) { calls++ })
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	ks, err := dst.Mkeys([]byte("m"))
+	assert.NoError(err)
+	assert.Len(ks, 2*DefaultBatchKeys+5)
+	assert.Greater(n, len(ks))
+
+	v, err := dst.Get(engine.EngineKey)
+	assert.NoError(err)
+	assert.Equal([]byte(engine.Badger), v)
+
+	assert.NoError(Verify(src, dst, DefaultSamples))
+
+	assert.NoError(dst.Del(root.Bytes()))
+	assert.Error(Verify(src, dst, DefaultSamples))
+
+	_, err = Copy(src, dst, nil)
+	assert.Equal(ErrNotEmpty, err)
+}
+
+func TestVerifyTip(t *testing.T) {
+	assert := assert.New(t)
+	src, dst := mem.New(), mem.New()
+	fakeChain(t, src)
+	_, err := Copy(src, dst, nil)
+	assert.NoError(err)
+
+	assert.NoError(dst.Set([]byte("blockhash"), []byte("other")))
+	assert.Error(Verify(src, dst, DefaultSamples))
+}
+
+func TestCopyAncients(t *testing.T) {
+	assert := assert.New(t)
+	src, dst := mem.New(), mem.New()
+	fakeChain(t, src)
+	srcF, err := bgdb.NewFreezer(t.TempDir())
+	assert.NoError(err)
+	defer srcF.Close()
+	hash := []byte("frozenhash")
+	assert.NoError(srcF.AppendAncient(0, hash, nil, []byte("frozen"), nil, nil))
+	assert.NoError(src.Set(hash, miscellaneous.E64func(0)))
+	assert.NoError(src.Set(blockchain.FrozenKey, miscellaneous.E64func(1)))
+	_, err = Copy(src, dst, nil)
+	assert.NoError(err)
+
+	// a target without the frozen blocks fails
+	empty, err := bgdb.NewFreezer(t.TempDir())
+	assert.NoError(err)
+	defer empty.Close()
+	assert.Error(VerifyAncients(dst, srcF, empty, DefaultSamples))
+
+	dstF, err := bgdb.NewFreezer(t.TempDir())
+	assert.NoError(err)
+	defer dstF.Close()
+	n, err := CopyAncients(srcF, dstF)
+	assert.NoError(err)
+	assert.Equal(uint64(1), n)
+	assert.NoError(VerifyAncients(dst, srcF, dstF, DefaultSamples))
+	data, err := blockchain.ReadBlock(dst, dstF, hash)
+	assert.NoError(err)
+	assert.Equal([]byte("frozen"), data)
+
+	_, err = CopyAncients(srcF, dstF)
+	assert.Equal(ErrNotEmpty, err)
+}
+
+func TestSampleHeights(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(sampleHeights(0, 4))
+	assert.Equal([]uint64{3, 2, 1}, sampleHeights(3, 10))
+	assert.Equal([]uint64{100, 75, 50, 25}, sampleHeights(100, 4))
+}
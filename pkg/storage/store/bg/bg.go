@@ -417,7 +417,7 @@ func mclear(tx *badger.Txn, m []byte) error {
 	itr := tx.NewIterator(opt)
 	defer itr.Close()
 	for itr.Seek(k); itr.ValidForPrefix(k); itr.Next() {
-		if err := del(tx, itr.Item().Key()); err != nil {
+		if err := del(tx, itr.Item().KeyCopy(nil)); err != nil {
 			return err
 		}
 	}
@@ -603,7 +603,7 @@ func lset(tx *badger.Txn, k []byte, idx int64, v []byte) error {
 	if idx < start || idx >= end {
 		return store.OutOfSize
 	}
-	return set(tx, eListKey(k, idx+start), v)
+	return set(tx, eListKey(k, idx), v)
 }
 
 func lindex(tx *badger.Txn, k []byte, idx int64) ([]byte, error) {
@@ -664,7 +664,7 @@ func sclear(tx *badger.Txn, k []byte) error {
 	itr := tx.NewIterator(opt)
 	defer itr.Close()
 	for itr.Seek(k); itr.ValidForPrefix(k); itr.Next() {
-		if err := del(tx, itr.Item().Key()); err != nil {
+		if err := del(tx, itr.Item().KeyCopy(nil)); err != nil {
 			return err
 		}
 	}
@@ -754,6 +754,15 @@ func zscore(tx *badger.Txn, k, v []byte) (int32, error) {
 }
 
 func zadd(tx *badger.Txn, k []byte, score int32, v []byte) error {
+	// drop the old score index, otherwise the member shows up twice in Zrange
+	if buf, err := get(tx, eZetKey(k, v)); err == nil {
+		old, _ := miscellaneous.D32func(buf)
+		if err := del(tx, eZetScore(k, v, int32(old))); err != nil {
+			return err
+		}
+	} else if err != store.NotExist {
+		return err
+	}
 	if err := set(tx, eZetKey(k, v), miscellaneous.E32func(uint32(score))); err != nil {
 		return err
 	}
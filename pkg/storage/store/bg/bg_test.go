@@ -0,0 +1,22 @@
+package bg
+
+import (
+	"testing"
+
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/storetest"
+
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.DB {
+		dir := t.TempDir()
+		opts := badger.DefaultOptions(dir)
+		opts.Logger = nil
+		db, err := badger.Open(opts)
+		require.NoError(t, err)
+		return New(db)
+	})
+}
@@ -39,18 +39,29 @@ var (
 
 type Database struct {
 	DB store.DB
+
+	ancients *Freezer
 }
 
 // New returns a wrapped LevelDB object. The namespace is the prefix that the
 // metrics reporting should use for surfacing internal stats.
 func NewBadgerDatabase(db store.DB) *Database {
-	return &Database{db}
+	return &Database{DB: db}
+}
+
+// NewDatabaseWithFreezer returns a wrapped db whose ancient methods are
+// served by f.
+func NewDatabaseWithFreezer(db store.DB, f *Freezer) *Database {
+	return &Database{DB: db, ancients: f}
 }
 
 // Close stops the metrics collection, flushes any pending data to disk and closes
 // all io accesses to the underlying key-value store.
 func (db *Database) Close() error {
 	//return db.Close()
+	if db.ancients != nil {
+		return db.ancients.Close()
+	}
 	return nil
 }
 
@@ -203,33 +214,59 @@ func (db *Database) Compact(start []byte, limit []byte) error {
 }
 
 func (db *Database) HasAncient(kind string, number uint64) (bool, error) {
-	return false, errNotSupported
+	if db.ancients == nil {
+		return false, errNotSupported
+	}
+	return db.ancients.HasAncient(kind, number)
 }
 
 func (db *Database) Ancient(kind string, number uint64) ([]byte, error) {
-	return nil, errNotSupported
+	if db.ancients == nil {
+		return nil, errNotSupported
+	}
+	return db.ancients.Ancient(kind, number)
 }
 
 func (db *Database) Ancients() (uint64, error) {
-	return 0, errNotSupported
+	if db.ancients == nil {
+		return 0, errNotSupported
+	}
+	return db.ancients.Ancients()
 }
 
 func (db *Database) AncientSize(kind string) (uint64, error) {
-	return 0, errNotSupported
+	if db.ancients == nil {
+		return 0, errNotSupported
+	}
+	return db.ancients.AncientSize(kind)
 }
 
 func (db *Database) AppendAncient(number uint64, hash, header, body, receipt, td []byte) error {
-	return errNotSupported
+	if db.ancients == nil {
+		return errNotSupported
+	}
+	return db.ancients.AppendAncient(number, hash, header, body, receipt, td)
 }
 
 func (db *Database) TruncateAncients(n uint64) error {
-	return errNotSupported
+	if db.ancients == nil {
+		return errNotSupported
+	}
+	return db.ancients.TruncateAncients(n)
 }
 
 func (db *Database) Sync() error {
+	if db.ancients != nil {
+		if err := db.ancients.Sync(); err != nil {
+			return err
+		}
+	}
 	return db.DB.Sync()
 }
 
 func (db *Database) ReadAncients(kind string, start, count, maxBytes uint64) ([][]byte, error) {
-	return nil, errNotSupported
+	if db.ancients == nil {
+		return nil, errNotSupported
+	}
+	return db.ancients.ReadAncients(kind, start, count, maxBytes)
 }
@@ -0,0 +1,346 @@
+package bgdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb/errors"
+)
+
+// The tables of a Freezer, one for each argument of AppendAncient.
+const (
+	FreezerHashTable       = "hashes"
+	FreezerHeaderTable     = "headers"
+	FreezerBodiesTable     = "bodies"
+	FreezerReceiptTable    = "receipts"
+	FreezerDifficultyTable = "diffs"
+)
+
+var freezerTables = []string{
+	FreezerHashTable,
+	FreezerHeaderTable,
+	FreezerBodiesTable,
+	FreezerReceiptTable,
+	FreezerDifficultyTable,
+}
+
+var (
+	errOutOfBounds  = errors.New("out of bounds")
+	errUnknownTable = errors.New("unknown table")
+	errOutOrder     = errors.New("the append operation is out-order")
+)
+
+// Freezer is an append-only store of immutable chain data in flat files.
+// Every table holds one item per block number, starting at 0, in a data file
+// and an index file of the end offsets of the items.
+type Freezer struct {
+	mu     sync.RWMutex
+	tables map[string]*freezerTable
+	items  uint64
+}
+
+// NewFreezer opens the freezer in dir, creating it if needed. Items torn by
+// a crash are dropped, so that all tables hold the same number of items.
+func NewFreezer(dir string) (*Freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &Freezer{tables: make(map[string]*freezerTable)}
+	for i, name := range freezerTables {
+		t, err := openFreezerTable(dir, name)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[name] = t
+		if i == 0 || t.items < f.items {
+			f.items = t.items
+		}
+	}
+	for _, t := range f.tables {
+		if err := t.truncate(f.items); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// HasAncient returns whether the table kind holds item number.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if _, ok := f.tables[kind]; !ok {
+		return false, errUnknownTable
+	}
+	return number < f.items, nil
+}
+
+// Ancient returns item number of the table kind.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	t, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	if number >= f.items {
+		return nil, errOutOfBounds
+	}
+	return t.retrieve(number)
+}
+
+// ReadAncients returns up to count items of the table kind starting at
+// start. Items after the first are left out once maxBytes is reached.
+func (f *Freezer) ReadAncients(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	t, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	if start >= f.items {
+		return nil, errOutOfBounds
+	}
+	if start+count > f.items {
+		count = f.items - start
+	}
+	var (
+		items [][]byte
+		size  uint64
+	)
+	for i := start; i < start+count; i++ {
+		item, err := t.retrieve(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 && size+uint64(len(item)) > maxBytes {
+			break
+		}
+		items = append(items, item)
+		size += uint64(len(item))
+	}
+	return items, nil
+}
+
+// Ancients returns the number of items in the freezer.
+func (f *Freezer) Ancients() (uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.items, nil
+}
+
+// AncientSize returns the data size of the table kind.
+func (f *Freezer) AncientSize(kind string) (uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	t, ok := f.tables[kind]
+	if !ok {
+		return 0, errUnknownTable
+	}
+	return t.size, nil
+}
+
+// AppendAncient appends item number to every table, number must be the
+// number of items already in the freezer.
+func (f *Freezer) AppendAncient(number uint64, hash, header, body, receipt, td []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if number != f.items {
+		return errOutOrder
+	}
+	items := map[string][]byte{
+		FreezerHashTable:       hash,
+		FreezerHeaderTable:     header,
+		FreezerBodiesTable:     body,
+		FreezerReceiptTable:    receipt,
+		FreezerDifficultyTable: td,
+	}
+	for name, item := range items {
+		if err := f.tables[name].append(item); err != nil {
+			// keep the tables the same length
+			for _, t := range f.tables {
+				t.truncate(f.items)
+			}
+			return err
+		}
+	}
+	f.items++
+	return nil
+}
+
+// TruncateAncients drops every item from number items on.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if items >= f.items {
+		return nil
+	}
+	for _, t := range f.tables {
+		if err := t.truncate(items); err != nil {
+			return err
+		}
+	}
+	f.items = items
+	return nil
+}
+
+// Sync flushes the tables to disk.
+func (f *Freezer) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tables {
+		if err := t.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the tables.
+func (f *Freezer) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, t := range f.tables {
+		if err := t.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freezerTable is one table of a Freezer. Entry i of the index is the end
+// offset of item i in the data file.
+type freezerTable struct {
+	data  *os.File
+	index *os.File
+	items uint64
+	size  uint64
+}
+
+func openFreezerTable(dir, name string) (*freezerTable, error) {
+	data, err := os.OpenFile(filepath.Join(dir, name+".dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	t := &freezerTable{data: data, index: index}
+	if err := t.repair(); err != nil {
+		t.close()
+		return nil, fmt.Errorf("freezer table %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// repair drops a torn index entry and the items whose data did not make it
+// to the data file. Data is written before its index entry.
+func (t *freezerTable) repair() error {
+	ist, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := t.data.Stat()
+	if err != nil {
+		return err
+	}
+	t.items = uint64(ist.Size()) / 8
+	for ; t.items > 0; t.items-- {
+		end, err := t.offset(t.items - 1)
+		if err != nil {
+			return err
+		}
+		if end <= uint64(dst.Size()) {
+			break
+		}
+	}
+	return t.truncate(t.items)
+}
+
+// offset returns the end offset of item i.
+func (t *freezerTable) offset(i uint64) (uint64, error) {
+	var buf [8]byte
+	if _, err := t.index.ReadAt(buf[:], int64(i*8)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (t *freezerTable) retrieve(i uint64) ([]byte, error) {
+	var start uint64
+	if i > 0 {
+		var err error
+		if start, err = t.offset(i - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.offset(i)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		return nil, fmt.Errorf("corrupt index entry %d", i)
+	}
+	item := make([]byte, end-start)
+	if _, err := t.data.ReadAt(item, int64(start)); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (t *freezerTable) append(item []byte) error {
+	if _, err := t.data.WriteAt(item, int64(t.size)); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], t.size+uint64(len(item)))
+	if _, err := t.index.WriteAt(buf[:], int64(t.items*8)); err != nil {
+		return err
+	}
+	t.items++
+	t.size += uint64(len(item))
+	return nil
+}
+
+func (t *freezerTable) truncate(items uint64) error {
+	var size uint64
+	if items > 0 {
+		var err error
+		if size, err = t.offset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * 8)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(size)); err != nil {
+		return err
+	}
+	t.items, t.size = items, size
+	return nil
+}
+
+func (t *freezerTable) sync() error {
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+func (t *freezerTable) close() error {
+	derr := t.data.Close()
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	return derr
+}
@@ -0,0 +1,101 @@
+package bgdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func appendItems(t *testing.T, f *Freezer, from, to uint64) {
+	for i := from; i < to; i++ {
+		body := []byte(fmt.Sprintf("body %d", i))
+		assert.NoError(t, f.AppendAncient(i, []byte{byte(i)}, nil, body, []byte("receipt"), nil))
+	}
+}
+
+func TestFreezer(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	f, err := NewFreezer(dir)
+	assert.NoError(err)
+	appendItems(t, f, 0, 10)
+	assert.Equal(errOutOrder, f.AppendAncient(11, nil, nil, nil, nil, nil))
+
+	n, err := f.Ancients()
+	assert.NoError(err)
+	assert.Equal(uint64(10), n)
+	body, err := f.Ancient(FreezerBodiesTable, 3)
+	assert.NoError(err)
+	assert.Equal([]byte("body 3"), body)
+	header, err := f.Ancient(FreezerHeaderTable, 3)
+	assert.NoError(err)
+	assert.Empty(header)
+	ok, err := f.HasAncient(FreezerHashTable, 10)
+	assert.NoError(err)
+	assert.False(ok)
+	_, err = f.Ancient(FreezerBodiesTable, 10)
+	assert.Equal(errOutOfBounds, err)
+	_, err = f.Ancient("unknown", 0)
+	assert.Equal(errUnknownTable, err)
+
+	// the first item is returned even if it is larger than maxBytes
+	items, err := f.ReadAncients(FreezerBodiesTable, 8, 5, 1)
+	assert.NoError(err)
+	assert.Equal([][]byte{[]byte("body 8")}, items)
+	items, err = f.ReadAncients(FreezerBodiesTable, 8, 5, 100)
+	assert.NoError(err)
+	assert.Equal([][]byte{[]byte("body 8"), []byte("body 9")}, items)
+
+	assert.NoError(f.TruncateAncients(5))
+	appendItems(t, f, 5, 7)
+	assert.NoError(f.Sync())
+	assert.NoError(f.Close())
+
+	f, err = NewFreezer(dir)
+	assert.NoError(err)
+	defer f.Close()
+	n, err = f.Ancients()
+	assert.NoError(err)
+	assert.Equal(uint64(7), n)
+	body, err = f.Ancient(FreezerBodiesTable, 6)
+	assert.NoError(err)
+	assert.Equal([]byte("body 6"), body)
+}
+
+func TestFreezerRepair(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	f, err := NewFreezer(dir)
+	assert.NoError(err)
+	appendItems(t, f, 0, 4)
+	assert.NoError(f.Close())
+
+	// a crash cut the last body short and tore an index entry of the hashes
+	name := filepath.Join(dir, FreezerBodiesTable+".dat")
+	st, err := os.Stat(name)
+	assert.NoError(err)
+	assert.NoError(os.Truncate(name, st.Size()-1))
+	name = filepath.Join(dir, FreezerHashTable+".idx")
+	st, err = os.Stat(name)
+	assert.NoError(err)
+	assert.NoError(os.Truncate(name, st.Size()-3))
+
+	f, err = NewFreezer(dir)
+	assert.NoError(err)
+	defer f.Close()
+	n, err := f.Ancients()
+	assert.NoError(err)
+	assert.Equal(uint64(3), n)
+	size, err := f.AncientSize(FreezerBodiesTable)
+	assert.NoError(err)
+	assert.Equal(uint64(3*len("body 0")), size)
+	appendItems(t, f, 3, 5)
+	body, err := f.Ancient(FreezerBodiesTable, 4)
+	assert.NoError(err)
+	assert.Equal([]byte("body 4"), body)
+}
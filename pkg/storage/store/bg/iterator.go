@@ -11,21 +11,27 @@ func (db *bgStore) NewIterator(prefix []byte, start []byte) store.Iterator {
 	opt.Prefix = prefix
 	opt.PrefetchValues = false
 	tx := db.db.NewTransaction(false)
-	itr := tx.NewIterator(opt)
-	itr.Seek(start)
 	return &bgIterator{
-		tx:  tx,
-		itr: itr,
+		tx:    tx,
+		itr:   tx.NewIterator(opt),
+		start: append(append([]byte{}, prefix...), start...),
 	}
 }
 
+// Next moves to the next key, the first call positions the iterator at
+// prefix+start (or the key after it).
 func (itr *bgIterator) Next() bool {
+	if itr.start != nil {
+		start := itr.start
+		itr.start = nil
+		itr.itr.Seek(start)
+		return itr.itr.Valid()
+	}
 	if !itr.itr.Valid() {
 		return false
 	}
 	itr.itr.Next()
 	return itr.itr.Valid()
-
 }
 
 func (itr *bgIterator) Error() error {
@@ -13,7 +13,8 @@ type bgTransaction struct {
 }
 
 type bgIterator struct {
-	err error
-	tx  *badger.Txn
-	itr *badger.Iterator
+	err   error
+	tx    *badger.Txn
+	itr   *badger.Iterator
+	start []byte
 }
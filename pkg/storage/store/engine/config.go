@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	Pebble = "pebble"
+	Badger = "badger"
+	Mem    = "mem"
+)
+
+// Config selects and tunes the storage engine behind the node's store.DB.
+type Config struct {
+	// Engine is one of "pebble", "badger" or "mem".
+	Engine string `yaml:"engine"`
+
+	// DataDir is the database directory. If it is empty, "<engine>.db" in the
+	// working directory is used. It is ignored by the mem engine.
+	DataDir string `yaml:"datadir"`
+
+	// CacheSize is the block cache size in bytes, 0 keeps the engine default.
+	// Badger v1 has no block cache, so only pebble honours it.
+	CacheSize int64 `yaml:"cachesize"`
+
+	// SyncOnCommit fsyncs the write-ahead log on every commit.
+	SyncOnCommit bool `yaml:"synconcommit"`
+
+	Compaction CompactionConfig `yaml:"compaction"`
+}
+
+// CompactionConfig tunes background compaction. Zero values keep the engine defaults.
+type CompactionConfig struct {
+	// L0Threshold is the number of level-0 tables that triggers a compaction.
+	L0Threshold int `yaml:"l0threshold"`
+
+	// L0StopWrites is the number of level-0 tables at which writes stall.
+	L0StopWrites int `yaml:"l0stopwrites"`
+
+	// Concurrency is the maximum number of concurrent compactions.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// DefaultConfig matches how the node opened its database before the engine
+// became configurable: a synced pebble database in ./pebble.db.
+func DefaultConfig() *Config {
+	return &Config{
+		Engine:       Pebble,
+		SyncOnCommit: true,
+	}
+}
+
+// Dir returns the database directory for the configured engine.
+func (cfg *Config) Dir() string {
+	if len(cfg.DataDir) > 0 {
+		return cfg.DataDir
+	}
+	return cfg.Engine + ".db"
+}
+
+// Parse reads an "engine:dir" pair as used on the command line, for example
+// "pebble:/data/pebble.db". The remaining settings are the defaults.
+func Parse(s string) (*Config, error) {
+	cfg := DefaultConfig()
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		cfg.Engine = s
+	} else {
+		cfg.Engine, cfg.DataDir = s[:i], s[i+1:]
+	}
+	switch cfg.Engine {
+	case Pebble, Badger, Mem:
+		return cfg, nil
+	}
+	return nil, fmt.Errorf("storage: unknown engine %q in %q", cfg.Engine, s)
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/bg"
+	"metechain/pkg/storage/store/mem"
+	"metechain/pkg/storage/store/pb"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/dgraph-io/badger"
+	"go.uber.org/zap"
+)
+
+// EngineKey records which engine created a database. It is written the first
+// time a database is opened and checked on every open after that.
+var EngineKey = []byte("storageEngine")
+
+// Open builds the store.DB described by cfg. It refuses to open a directory
+// that was created by a different engine.
+func Open(cfg *Config, lg *zap.Logger) (store.DB, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+
+	var db store.DB
+	switch cfg.Engine {
+	case Mem:
+		db = mem.New()
+	case Pebble, Badger:
+		if other := detect(cfg.Dir()); len(other) > 0 && other != cfg.Engine {
+			return nil, fmt.Errorf("storage: %s was created by %s, not %s", cfg.Dir(), other, cfg.Engine)
+		}
+		var err error
+		if cfg.Engine == Pebble {
+			db, err = openPebble(cfg, lg)
+		} else {
+			db, err = openBadger(cfg, lg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("storage: unknown engine %q", cfg.Engine)
+	}
+
+	if err := checkMarker(db, cfg.Engine); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("storage: %s: %v", cfg.Dir(), err)
+	}
+	return db, nil
+}
+
+func openPebble(cfg *Config, lg *zap.Logger) (store.DB, error) {
+	opts := &pebble.Options{Logger: lg.Named("pebble").Sugar()}
+	if cfg.CacheSize > 0 {
+		c := pebble.NewCache(cfg.CacheSize)
+		defer c.Unref()
+		opts.Cache = c
+	}
+	if cfg.Compaction.L0Threshold > 0 {
+		opts.L0CompactionThreshold = cfg.Compaction.L0Threshold
+	}
+	if cfg.Compaction.L0StopWrites > 0 {
+		opts.L0StopWritesThreshold = cfg.Compaction.L0StopWrites
+	}
+	if n := cfg.Compaction.Concurrency; n > 0 {
+		opts.MaxConcurrentCompactions = func() int { return n }
+	}
+
+	db, err := pebble.Open(cfg.Dir(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewWithSync(db, cfg.SyncOnCommit), nil
+}
+
+func openBadger(cfg *Config, lg *zap.Logger) (store.DB, error) {
+	opts := badger.DefaultOptions(cfg.Dir())
+	opts.Logger = badgerLogger{lg.Named("badger").Sugar()}
+	opts.SyncWrites = cfg.SyncOnCommit
+	if cfg.Compaction.L0Threshold > 0 {
+		opts.NumLevelZeroTables = cfg.Compaction.L0Threshold
+	}
+	if cfg.Compaction.L0StopWrites > 0 {
+		opts.NumLevelZeroTablesStall = cfg.Compaction.L0StopWrites
+	}
+	if cfg.Compaction.Concurrency > 0 {
+		opts.NumCompactors = cfg.Compaction.Concurrency
+	}
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return bg.New(db), nil
+}
+
+// checkMarker writes EngineKey into a database that does not have one yet and
+// otherwise verifies that it names engine.
+func checkMarker(db store.DB, engine string) error {
+	v, err := db.Get(EngineKey)
+	switch {
+	case err == store.NotExist:
+		return db.Set(EngineKey, []byte(engine))
+	case err != nil:
+		return err
+	case !bytes.Equal(v, []byte(engine)):
+		return fmt.Errorf("created by %s, not %s", v, engine)
+	}
+	return nil
+}
+
+// detect guesses which engine owns dir from the files in it. Neither engine
+// refuses to initialise a directory holding the other's files, so this runs
+// before open to keep them from sharing one.
+func detect(dir string) string {
+	fs, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, f := range fs {
+		name := f.Name()
+		switch {
+		case name == "CURRENT" || strings.HasPrefix(name, "OPTIONS-"):
+			return Pebble
+		case name == "MANIFEST" || strings.HasSuffix(name, ".vlog"):
+			return Badger
+		}
+	}
+	return ""
+}
+
+// badgerLogger adapts a zap logger to badger.Logger.
+type badgerLogger struct {
+	*zap.SugaredLogger
+}
+
+func (l badgerLogger) Warningf(format string, args ...interface{}) {
+	l.Warnf(format, args...)
+}
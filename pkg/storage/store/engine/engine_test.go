@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenWritesMarker(t *testing.T) {
+	assert := assert.New(t)
+	for _, name := range []string{Pebble, Badger, Mem} {
+		cfg := &Config{Engine: name, DataDir: filepath.Join(t.TempDir(), name)}
+		db, err := Open(cfg, nil)
+		assert.NoError(err, name)
+		v, err := db.Get(EngineKey)
+		assert.NoError(err, name)
+		assert.Equal([]byte(name), v)
+		assert.NoError(db.Close())
+	}
+}
+
+func TestOpenReopen(t *testing.T) {
+	assert := assert.New(t)
+	cfg := &Config{Engine: Pebble, DataDir: t.TempDir()}
+	db, err := Open(cfg, nil)
+	assert.NoError(err)
+	assert.NoError(db.Set([]byte("k"), []byte("v")))
+	assert.NoError(db.Close())
+
+	db, err = Open(cfg, nil)
+	assert.NoError(err)
+	v, err := db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("v"), v)
+	assert.NoError(db.Close())
+}
+
+func TestOpenOtherEngine(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	db, err := Open(&Config{Engine: Pebble, DataDir: dir}, nil)
+	assert.NoError(err)
+	assert.NoError(db.Close())
+	_, err = Open(&Config{Engine: Badger, DataDir: dir}, nil)
+	assert.Error(err)
+
+	dir = t.TempDir()
+	db, err = Open(&Config{Engine: Badger, DataDir: dir}, nil)
+	assert.NoError(err)
+	assert.NoError(db.Close())
+	_, err = Open(&Config{Engine: Pebble, DataDir: dir}, nil)
+	assert.Error(err)
+}
+
+func TestOpenWrongMarker(t *testing.T) {
+	assert := assert.New(t)
+	cfg := &Config{Engine: Pebble, DataDir: t.TempDir()}
+	db, err := Open(cfg, nil)
+	assert.NoError(err)
+	assert.NoError(db.Set(EngineKey, []byte(Badger)))
+	assert.NoError(db.Close())
+
+	_, err = Open(cfg, nil)
+	assert.Error(err)
+}
+
+func TestOpenUnknownEngine(t *testing.T) {
+	_, err := Open(&Config{Engine: "leveldb"}, nil)
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	cfg, err := Parse("badger:/data/chain")
+	assert.NoError(err)
+	assert.Equal(Badger, cfg.Engine)
+	assert.Equal("/data/chain", cfg.Dir())
+
+	cfg, err = Parse("pebble")
+	assert.NoError(err)
+	assert.Equal("pebble.db", cfg.Dir())
+
+	_, err = Parse("leveldb:/data")
+	assert.Error(err)
+}
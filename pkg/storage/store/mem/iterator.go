@@ -0,0 +1,82 @@
+package mem
+
+import (
+	"bytes"
+
+	"metechain/pkg/storage/store"
+
+	"github.com/google/btree"
+)
+
+// batchSize is the number of items an iterator copies out of its snapshot
+// at a time.
+const batchSize = 64
+
+// NewIterator iterates a snapshot of the store taken now, so writes made
+// while iterating are not observed.
+func (db *memStore) NewIterator(prefix []byte, start []byte) store.Iterator {
+	return &memIterator{
+		tree:   db.snapshot(),
+		prefix: prefix,
+		next:   append(append([]byte{}, prefix...), start...),
+	}
+}
+
+func (itr *memIterator) Next() bool {
+	if len(itr.items) == 0 {
+		itr.fill()
+	}
+	if len(itr.items) == 0 {
+		itr.cur = nil
+		return false
+	}
+	itr.cur, itr.items = itr.items[0], itr.items[1:]
+	return true
+}
+
+// fill loads the next batch of items with the iterator's prefix.
+func (itr *memIterator) fill() {
+	if itr.done {
+		return
+	}
+	var last []byte
+	itr.tree.AscendGreaterOrEqual(&item{key: itr.next}, func(i btree.Item) bool {
+		it := i.(*item)
+		if !bytes.HasPrefix(it.key, itr.prefix) {
+			itr.done = true
+			return false
+		}
+		itr.items = append(itr.items, it)
+		last = it.key
+		return len(itr.items) < batchSize
+	})
+	if last == nil {
+		itr.done = true
+		return
+	}
+	// the smallest key after last
+	itr.next = append(append([]byte{}, last...), 0)
+}
+
+func (itr *memIterator) Error() error {
+	return nil
+}
+
+func (itr *memIterator) Key() []byte {
+	if itr.cur == nil {
+		return nil
+	}
+	return append([]byte{}, itr.cur.key...)
+}
+
+func (itr *memIterator) Value() []byte {
+	if itr.cur == nil {
+		return nil
+	}
+	return append([]byte{}, itr.cur.val...)
+}
+
+func (itr *memIterator) Release() {
+	itr.tree, itr.items, itr.cur = nil, nil, nil
+	itr.done = true
+}
@@ -0,0 +1,899 @@
+package mem
+
+import (
+	"bytes"
+	"errors"
+
+	"metechain/pkg/storage/miscellaneous"
+	"metechain/pkg/storage/store"
+
+	"github.com/google/btree"
+)
+
+const degree = 32
+
+var errTxnClosed = errors.New("transaction already committed or canceled")
+
+// New returns an empty store.DB kept entirely in memory. Keys are ordered the
+// same way as on disk backends and transactions read from a snapshot taken
+// when they start.
+func New() store.DB {
+	return &memStore{tree: btree.New(degree)}
+}
+
+func (db *memStore) Sync() error {
+	return nil
+}
+
+func (db *memStore) Close() error {
+	return nil
+}
+
+// read runs fn against the live tree under the read lock.
+func (db *memStore) read(fn func(tx *view) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&view{tree: db.tree})
+}
+
+// update runs fn against the live tree under the write lock, fn is applied
+// only if it succeeds.
+func (db *memStore) update(fn func(tx *view) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	v := &view{tree: db.tree.Clone()}
+	if err := fn(v); err != nil {
+		return err
+	}
+	db.tree = v.tree
+	return nil
+}
+
+// snapshot returns a copy-on-write clone of the live tree.
+func (db *memStore) snapshot() *btree.BTree {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.tree.Clone()
+}
+
+func (db *memStore) Del(k []byte) error {
+	return db.update(func(tx *view) error {
+		return del(tx, k)
+	})
+}
+
+func (db *memStore) Set(k, v []byte) error {
+	return db.update(func(tx *view) error {
+		return set(tx, k, v)
+	})
+}
+
+func (db *memStore) Get(k []byte) (v []byte, err error) {
+	err = db.read(func(tx *view) error {
+		v, err = get(tx, k)
+		return err
+	})
+	return v, err
+}
+
+// NewTransaction returns a transaction over a snapshot of the store. Its
+// writes are visible to itself only until Commit replays them on the store.
+func (db *memStore) NewTransaction() store.Transaction {
+	return &memTransaction{db: db, v: &view{tree: db.snapshot(), logged: true}}
+}
+
+func (tx *memTransaction) Cancel() error {
+	tx.closed = true
+	return nil
+}
+
+func (tx *memTransaction) Commit() error {
+	if tx.closed {
+		return errTxnClosed
+	}
+	tx.closed = true
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	for _, w := range tx.v.writes {
+		if w.del {
+			tx.db.tree.Delete(&item{key: w.key})
+		} else {
+			tx.db.tree.ReplaceOrInsert(&item{key: w.key, val: w.val})
+		}
+	}
+	return nil
+}
+
+func (tx *memTransaction) Del(k []byte) error {
+	return del(tx.v, k)
+}
+
+func (tx *memTransaction) Set(k, v []byte) error {
+	return set(tx.v, k, v)
+}
+
+func (tx *memTransaction) Get(k []byte) ([]byte, error) {
+	return get(tx.v, k)
+}
+
+func del(tx *view, k []byte) error {
+	k = miscellaneous.Dup(k)
+	tx.tree.Delete(&item{key: k})
+	if tx.logged {
+		tx.writes = append(tx.writes, write{key: k, del: true})
+	}
+	return nil
+}
+
+func set(tx *view, k, v []byte) error {
+	k, v = miscellaneous.Dup(k), append([]byte{}, v...)
+	tx.tree.ReplaceOrInsert(&item{key: k, val: v})
+	if tx.logged {
+		tx.writes = append(tx.writes, write{key: k, val: v})
+	}
+	return nil
+}
+
+func get(tx *view, k []byte) ([]byte, error) {
+	it := tx.tree.Get(&item{key: k})
+	if it == nil {
+		return nil, store.NotExist
+	}
+	return append([]byte{}, it.(*item).val...), nil
+}
+
+// scan calls fn for every key with the given prefix, starting at from, in
+// key order. The keys and values passed to fn must not be modified.
+func scan(tx *view, prefix, from []byte, fn func(k, v []byte) bool) {
+	tx.tree.AscendGreaterOrEqual(&item{key: from}, func(i btree.Item) bool {
+		it := i.(*item)
+		if !bytes.HasPrefix(it.key, prefix) {
+			return false
+		}
+		return fn(it.key, it.val)
+	})
+}
+
+// keys collects the keys with the given prefix, so callers can delete them
+// without mutating the tree while it is being walked.
+func keys(tx *view, prefix []byte) [][]byte {
+	var ks [][]byte
+	scan(tx, prefix, prefix, func(k, _ []byte) bool {
+		ks = append(ks, k)
+		return true
+	})
+	return ks
+}
+
+func (db *memStore) Mclear(m []byte) error {
+	return db.update(func(tx *view) error {
+		return mclear(tx, m)
+	})
+}
+
+func (db *memStore) Mdel(m, k []byte) error {
+	return db.update(func(tx *view) error {
+		return mdel(tx, m, k)
+	})
+}
+
+func (db *memStore) Mset(m, k, v []byte) error {
+	return db.update(func(tx *view) error {
+		return mset(tx, m, k, v)
+	})
+}
+
+func (db *memStore) Mget(m, k []byte) (v []byte, err error) {
+	err = db.read(func(tx *view) error {
+		v, err = mget(tx, m, k)
+		return err
+	})
+	return v, err
+}
+
+func (db *memStore) Mkeys(m []byte) (ks [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		ks, err = mkeys(tx, m)
+		return err
+	})
+	return ks, err
+}
+
+func (db *memStore) Mvals(m []byte) (vs [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		vs, err = mvals(tx, m)
+		return err
+	})
+	return vs, err
+}
+
+func (db *memStore) Mkvs(m []byte) (ks [][]byte, vs [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		ks, vs, err = mkvs(tx, m)
+		return err
+	})
+	return ks, vs, err
+}
+
+func (db *memStore) Llen(k []byte) (n int64) {
+	db.read(func(tx *view) error {
+		n = llen(tx, k)
+		return nil
+	})
+	return n
+}
+
+func (db *memStore) Lclear(k []byte) error {
+	return db.update(func(tx *view) error {
+		return llclear(tx, k)
+	})
+}
+
+func (db *memStore) Llpush(k, v []byte) (int64, error) {
+	var n int64
+	err := db.update(func(tx *view) (err error) {
+		n, err = llpush(tx, k, v)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return n, nil
+}
+
+func (db *memStore) Llpop(k []byte) ([]byte, error) {
+	var v []byte
+	err := db.update(func(tx *view) (err error) {
+		v, err = llpop(tx, k)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (db *memStore) Lrpush(k, v []byte) (int64, error) {
+	var n int64
+	err := db.update(func(tx *view) (err error) {
+		n, err = lrpush(tx, k, v)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return n, nil
+}
+
+func (db *memStore) Lrpop(k []byte) ([]byte, error) {
+	var v []byte
+	err := db.update(func(tx *view) (err error) {
+		v, err = lrpop(tx, k)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (db *memStore) Lrange(k []byte, start, end int64) (vs [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		vs, err = lrange(tx, k, start, end)
+		return err
+	})
+	return vs, err
+}
+
+func (db *memStore) Lset(k []byte, idx int64, v []byte) error {
+	return db.update(func(tx *view) error {
+		return lset(tx, k, idx, v)
+	})
+}
+
+func (db *memStore) Lindex(k []byte, idx int64) (v []byte, err error) {
+	err = db.read(func(tx *view) error {
+		v, err = lindex(tx, k, idx)
+		return err
+	})
+	return v, err
+}
+
+func (db *memStore) Sclear(k []byte) error {
+	return db.update(func(tx *view) error {
+		return sclear(tx, k)
+	})
+}
+
+func (db *memStore) Sdel(k, v []byte) error {
+	return db.update(func(tx *view) error {
+		return sdel(tx, k, v)
+	})
+}
+
+func (db *memStore) Sadd(k, v []byte) error {
+	return db.update(func(tx *view) error {
+		return sadd(tx, k, v)
+	})
+}
+
+func (db *memStore) Selem(k, v []byte) (ok bool, err error) {
+	err = db.read(func(tx *view) error {
+		ok, err = selem(tx, k, v)
+		return err
+	})
+	return ok, err
+}
+
+func (db *memStore) Smembers(k []byte) (vs [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		vs, err = smembers(tx, k)
+		return err
+	})
+	return vs, err
+}
+
+func (db *memStore) Zclear(k []byte) error {
+	return db.update(func(tx *view) error {
+		return zclear(tx, k)
+	})
+}
+
+func (db *memStore) Zdel(k, v []byte) error {
+	return db.update(func(tx *view) error {
+		return zdel(tx, k, v)
+	})
+}
+
+func (db *memStore) Zadd(k []byte, score int32, v []byte) error {
+	return db.update(func(tx *view) error {
+		return zadd(tx, k, score, v)
+	})
+}
+
+func (db *memStore) Zscore(k, v []byte) (score int32, err error) {
+	err = db.read(func(tx *view) error {
+		score, err = zscore(tx, k, v)
+		return err
+	})
+	return score, err
+}
+
+func (db *memStore) Zrange(k []byte, start, end int32) (vs [][]byte, err error) {
+	err = db.read(func(tx *view) error {
+		vs, err = zrange(tx, k, start, end)
+		return err
+	})
+	return vs, err
+}
+
+func (tx *memTransaction) Mclear(m []byte) error {
+	return mclear(tx.v, m)
+}
+
+func (tx *memTransaction) Mdel(m, k []byte) error {
+	return mdel(tx.v, m, k)
+}
+
+func (tx *memTransaction) Mset(m, k, v []byte) error {
+	return mset(tx.v, m, k, v)
+}
+
+func (tx *memTransaction) Mget(m, k []byte) ([]byte, error) {
+	return mget(tx.v, m, k)
+}
+
+func (tx *memTransaction) Mkeys(m []byte) ([][]byte, error) {
+	return mkeys(tx.v, m)
+}
+
+func (tx *memTransaction) Mvals(m []byte) ([][]byte, error) {
+	return mvals(tx.v, m)
+}
+
+func (tx *memTransaction) Mkvs(m []byte) ([][]byte, [][]byte, error) {
+	return mkvs(tx.v, m)
+}
+
+func (tx *memTransaction) Llen(k []byte) int64 {
+	return llen(tx.v, k)
+}
+
+func (tx *memTransaction) Lclear(k []byte) error {
+	return llclear(tx.v, k)
+}
+
+func (tx *memTransaction) Llpush(k, v []byte) (int64, error) {
+	return llpush(tx.v, k, v)
+}
+
+func (tx *memTransaction) Llpop(k []byte) ([]byte, error) {
+	return llpop(tx.v, k)
+}
+
+func (tx *memTransaction) Lrpush(k, v []byte) (int64, error) {
+	return lrpush(tx.v, k, v)
+}
+
+func (tx *memTransaction) Lrpop(k []byte) ([]byte, error) {
+	return lrpop(tx.v, k)
+}
+
+func (tx *memTransaction) Lrange(k []byte, start, end int64) ([][]byte, error) {
+	return lrange(tx.v, k, start, end)
+}
+
+func (tx *memTransaction) Lset(k []byte, idx int64, v []byte) error {
+	return lset(tx.v, k, idx, v)
+}
+
+func (tx *memTransaction) Lindex(k []byte, idx int64) ([]byte, error) {
+	return lindex(tx.v, k, idx)
+}
+
+func (tx *memTransaction) Sclear(k []byte) error {
+	return sclear(tx.v, k)
+}
+
+func (tx *memTransaction) Sdel(k, v []byte) error {
+	return sdel(tx.v, k, v)
+}
+
+func (tx *memTransaction) Sadd(k, v []byte) error {
+	return sadd(tx.v, k, v)
+}
+
+func (tx *memTransaction) Selem(k, v []byte) (bool, error) {
+	return selem(tx.v, k, v)
+}
+
+func (tx *memTransaction) Smembers(k []byte) ([][]byte, error) {
+	return smembers(tx.v, k)
+}
+
+func (tx *memTransaction) Zclear(k []byte) error {
+	return zclear(tx.v, k)
+}
+
+func (tx *memTransaction) Zdel(k, v []byte) error {
+	return zdel(tx.v, k, v)
+}
+
+func (tx *memTransaction) Zadd(k []byte, score int32, v []byte) error {
+	return zadd(tx.v, k, score, v)
+}
+
+func (tx *memTransaction) Zscore(k, v []byte) (int32, error) {
+	return zscore(tx.v, k, v)
+}
+
+func (tx *memTransaction) Zrange(k []byte, start, end int32) ([][]byte, error) {
+	return zrange(tx.v, k, start, end)
+}
+
+func mclear(tx *view, m []byte) error {
+	for _, k := range keys(tx, eMapKey(m, []byte{})) {
+		if err := del(tx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mdel(tx *view, m, k []byte) error {
+	return del(tx, eMapKey(m, k))
+}
+
+func mset(tx *view, m, k, v []byte) error {
+	return set(tx, eMapKey(m, k), v)
+}
+
+func mget(tx *view, m, k []byte) ([]byte, error) {
+	return get(tx, eMapKey(m, k))
+}
+
+func mkeys(tx *view, m []byte) ([][]byte, error) {
+	ks, _, err := mkvs(tx, m)
+	return ks, err
+}
+
+func mvals(tx *view, m []byte) ([][]byte, error) {
+	_, vs, err := mkvs(tx, m)
+	return vs, err
+}
+
+func mkvs(tx *view, m []byte) ([][]byte, [][]byte, error) {
+	var ks, vs [][]byte
+
+	prefix := eMapKey(m, []byte{})
+	scan(tx, prefix, prefix, func(k, v []byte) bool {
+		ks = append(ks, dMapKey(miscellaneous.Dup(k)))
+		vs = append(vs, append([]byte{}, v...))
+		return true
+	})
+	return ks, vs, nil
+}
+
+func lnew(tx *view, k []byte) error {
+	return set(tx, eListmeteKey(k), eListmeteValue(0, 0))
+}
+
+func llen(tx *view, k []byte) int64 {
+	if start, end, err := listStartEnd(tx, k); err != nil {
+		return 0
+	} else {
+		return end - start
+	}
+}
+
+func llclear(tx *view, k []byte) error {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		return err
+	}
+	for ; start < end; start++ {
+		if err := del(tx, eListKey(k, start)); err != nil {
+			return err
+		}
+	}
+	return del(tx, eListmeteKey(k))
+}
+
+func llpush(tx *view, k, v []byte) (int64, error) {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		if err = lnew(tx, k); err != nil {
+			return -1, err
+		}
+	}
+	if start-1 == end {
+		return -1, store.OutOfSize
+	}
+	if err := set(tx, eListKey(k, start-1), v); err != nil {
+		return -1, err
+	}
+	if err := set(tx, eListmeteKey(k), eListmeteValue(start-1, end)); err != nil {
+		return -1, err
+	}
+	return end - start + 1, nil
+}
+
+func llpop(tx *view, k []byte) ([]byte, error) {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, store.OutOfSize
+	}
+	v, err := get(tx, eListKey(k, start))
+	if err != nil {
+		return nil, err
+	}
+	if err := set(tx, eListmeteKey(k), eListmeteValue(start+1, end)); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func lrpush(tx *view, k, v []byte) (int64, error) {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		if err = lnew(tx, k); err != nil {
+			return -1, err
+		}
+	}
+	if start == end+1 {
+		return -1, store.OutOfSize
+	}
+	if err := set(tx, eListKey(k, end), v); err != nil {
+		return -1, err
+	}
+	if err := set(tx, eListmeteKey(k), eListmeteValue(start, end+1)); err != nil {
+		return -1, err
+	}
+	return end - start + 1, nil
+}
+
+func lrpop(tx *view, k []byte) ([]byte, error) {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, store.OutOfSize
+	}
+	v, err := get(tx, eListKey(k, end-1))
+	if err != nil {
+		return nil, err
+	}
+	if err := set(tx, eListmeteKey(k), eListmeteValue(start, end-1)); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func lset(tx *view, k []byte, idx int64, v []byte) error {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		return err
+	}
+	switch {
+	case idx >= 0:
+		idx += start
+	default:
+		idx += end
+	}
+	if idx < start || idx >= end {
+		return store.OutOfSize
+	}
+	return set(tx, eListKey(k, idx), v)
+}
+
+func lindex(tx *view, k []byte, idx int64) ([]byte, error) {
+	start, end, err := listStartEnd(tx, k)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case idx >= 0:
+		idx += start
+	default:
+		idx += end
+	}
+	if idx < start || idx >= end {
+		return []byte{}, nil
+	}
+	return get(tx, eListKey(k, idx))
+}
+
+func lrange(tx *view, k []byte, start, end int64) ([][]byte, error) {
+	var vs [][]byte
+
+	x, y, err := listStartEnd(tx, k)
+	if err != nil {
+		return nil, err
+	}
+	if end < 0 {
+		end += y
+	} else {
+		end += x
+	}
+	if start < 0 {
+		start += y
+	} else {
+		start += x
+	}
+	if start < x {
+		start = x
+	}
+	if end >= y {
+		end = y
+	}
+	for ; start <= end; start++ {
+		if v, err := get(tx, eListKey(k, start)); err != nil {
+			continue
+		} else {
+			vs = append(vs, v)
+		}
+	}
+	return vs, nil
+}
+
+func sclear(tx *view, k []byte) error {
+	for _, key := range keys(tx, eSetKey(k, []byte{})) {
+		if err := del(tx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sdel(tx *view, k, v []byte) error {
+	return del(tx, eSetKey(k, v))
+}
+
+func sadd(tx *view, k, v []byte) error {
+	return set(tx, eSetKey(k, v), []byte{})
+}
+
+func selem(tx *view, k, v []byte) (bool, error) {
+	_, err := get(tx, eSetKey(k, v))
+	switch {
+	case err == nil:
+		return true, nil
+	case err == store.NotExist:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func smembers(tx *view, k []byte) ([][]byte, error) {
+	var vs [][]byte
+
+	prefix := eSetKey(k, []byte{})
+	scan(tx, prefix, prefix, func(key, _ []byte) bool {
+		vs = append(vs, dSetKey(miscellaneous.Dup(key)))
+		return true
+	})
+	return vs, nil
+}
+
+func zclear(tx *view, k []byte) error {
+	for _, key := range keys(tx, zetScorePrefix(k)) {
+		score, v := dZetScore(key)
+		if err := del(tx, eZetKey(k, v)); err != nil {
+			return err
+		}
+		if err := del(tx, eZetScore(k, v, score)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func zdel(tx *view, k, v []byte) error {
+	key := eZetKey(k, v)
+	buf, err := get(tx, key)
+	if err != nil {
+		return err
+	}
+	if err := del(tx, key); err != nil {
+		return err
+	}
+	score, _ := miscellaneous.D32func(buf)
+	return del(tx, eZetScore(k, v, int32(score)))
+}
+
+func zscore(tx *view, k, v []byte) (int32, error) {
+	if buf, err := get(tx, eZetKey(k, v)); err != nil {
+		return -1, err
+	} else {
+		score, _ := miscellaneous.D32func(buf)
+		return int32(score), nil
+	}
+}
+
+func zadd(tx *view, k []byte, score int32, v []byte) error {
+	// drop the old score index, otherwise the member shows up twice in Zrange
+	if buf, err := get(tx, eZetKey(k, v)); err == nil {
+		old, _ := miscellaneous.D32func(buf)
+		if err := del(tx, eZetScore(k, v, int32(old))); err != nil {
+			return err
+		}
+	} else if err != store.NotExist {
+		return err
+	}
+	if err := set(tx, eZetKey(k, v), miscellaneous.E32func(uint32(score))); err != nil {
+		return err
+	}
+	return set(tx, eZetScore(k, v, score), []byte{})
+}
+
+func zrange(tx *view, k []byte, start, end int32) ([][]byte, error) {
+	var vs [][]byte
+
+	scan(tx, zetScorePrefix(k), eZetScore(k, []byte{}, start), func(key, _ []byte) bool {
+		score, v := dZetScore(key)
+		if score > end {
+			return false
+		}
+		vs = append(vs, miscellaneous.Dup(v))
+		return true
+	})
+	return vs, nil
+}
+
+func listStartEnd(tx *view, k []byte) (int64, int64, error) {
+	if v, err := get(tx, eListmeteKey(k)); err != nil {
+		return 0, 0, err
+	} else {
+		start, end := dListmeteValue(v)
+		return start, end, nil
+	}
+}
+
+// 'l' + k
+func eListmeteKey(k []byte) []byte {
+	return append([]byte{'l'}, k...)
+}
+
+func dListmeteKey(buf []byte) []byte {
+	return buf[1:]
+}
+
+// start + end
+func eListmeteValue(start, end int64) []byte {
+	return append(miscellaneous.E64func(uint64(start)), miscellaneous.E64func(uint64(end))...)
+}
+
+func dListmeteValue(buf []byte) (int64, int64) {
+	start, _ := miscellaneous.D64func(buf[:8])
+	end, _ := miscellaneous.D64func(buf[8:16])
+	return int64(start), int64(end)
+}
+
+// 'l' + k + index
+func eListKey(k []byte, idx int64) []byte {
+	buf := []byte{}
+	buf = append([]byte{'l'}, k...)
+	buf = append(buf, miscellaneous.E64func(uint64(idx))...)
+	return buf
+}
+
+func dListKey(buf []byte) []byte {
+	n := len(buf)
+	return buf[1 : n-8]
+}
+
+// 'm' + mlen + m + '+' + k
+func eMapKey(m, k []byte) []byte {
+	buf := []byte{}
+	buf = append([]byte{'m'}, miscellaneous.E32func(uint32(len(m)))...)
+	buf = append(buf, m...)
+	buf = append(buf, byte('+'))
+	buf = append(buf, k...)
+	return buf
+}
+
+func dMapKey(buf []byte) []byte {
+	buf = buf[1:]
+	n, _ := miscellaneous.D32func(buf[:4])
+	return buf[5+n:]
+}
+
+// 's' + klen + k + '+' + v
+func eSetKey(k, v []byte) []byte {
+	buf := []byte{}
+	buf = append([]byte{'s'}, miscellaneous.E32func(uint32(len(k)))...)
+	buf = append(buf, k...)
+	buf = append(buf, byte('+'))
+	buf = append(buf, v...)
+	return buf
+}
+
+func dSetKey(buf []byte) []byte {
+	buf = buf[1:]
+	n, _ := miscellaneous.D32func(buf[:4])
+	return buf[5+n:]
+}
+
+// 'z' + klen + k + '+' + v
+func eZetKey(k, v []byte) []byte {
+	buf := []byte{}
+	buf = append([]byte{'z'}, miscellaneous.E32func(uint32(len(k)))...)
+	buf = append(buf, k...)
+	buf = append(buf, byte('+'))
+	buf = append(buf, v...)
+	return buf
+}
+
+func dZetKey(buf []byte) []byte {
+	buf = buf[1:]
+	n, _ := miscellaneous.D32func(buf[:4])
+	return buf[5+n:]
+}
+
+// 'sz' + klen + k + '+'
+func zetScorePrefix(k []byte) []byte {
+	return eZetScore(k, []byte{}, 0)[:len(k)+7]
+}
+
+// 'sz' + klen + k + '+' + score + v
+func eZetScore(k, v []byte, score int32) []byte {
+	buf := []byte{}
+	buf = append([]byte("sz"), miscellaneous.E32func(uint32(len(k)))...)
+	buf = append(buf, k...)
+	buf = append(buf, byte('+'))
+	buf = append(buf, miscellaneous.EB32func(uint32(score))...)
+	buf = append(buf, v...)
+	return buf
+}
+
+func dZetScore(buf []byte) (int32, []byte) {
+	buf = buf[2:]
+	n, _ := miscellaneous.D32func(buf[:4])
+	score, _ := miscellaneous.DB32func(buf[5+n : 9+n])
+	return int32(score), buf[9+n:]
+}
@@ -0,0 +1,51 @@
+package mem
+
+import (
+	"testing"
+
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/storetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.DB {
+		return New()
+	})
+}
+
+func TestTransactionSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	db := New()
+	assert.NoError(db.Set([]byte("k"), []byte("old")))
+
+	tx := db.NewTransaction()
+	defer tx.Cancel()
+	assert.NoError(db.Set([]byte("k"), []byte("new")))
+	assert.NoError(db.Set([]byte("k2"), []byte("v2")))
+
+	v, err := tx.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("old"), v)
+	_, err = tx.Get([]byte("k2"))
+	assert.Equal(store.NotExist, err)
+}
+
+func TestIteratorBatches(t *testing.T) {
+	assert := assert.New(t)
+	db := New()
+	for i := 0; i < 3*batchSize+1; i++ {
+		assert.NoError(db.Set([]byte{'p', byte(i >> 8), byte(i)}, []byte{byte(i)}))
+	}
+	assert.NoError(db.Set([]byte("q"), []byte("other")))
+
+	itr := db.NewIterator([]byte("p"), nil)
+	defer itr.Release()
+	var n int
+	for itr.Next() {
+		assert.Equal([]byte{byte(n)}, itr.Value())
+		n++
+	}
+	assert.Equal(3*batchSize+1, n)
+}
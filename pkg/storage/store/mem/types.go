@@ -0,0 +1,54 @@
+package mem
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/google/btree"
+)
+
+// item is a key/value pair kept in key order by the btree.
+type item struct {
+	key []byte
+	val []byte
+}
+
+func (a *item) Less(b btree.Item) bool {
+	return bytes.Compare(a.key, b.(*item).key) < 0
+}
+
+// write is a pending mutation of a transaction, replayed on commit.
+type write struct {
+	key []byte
+	val []byte
+	del bool
+}
+
+// view is the keyspace the map/list/set/zset helpers operate on. The store
+// uses its live tree, a transaction uses a copy-on-write clone and keeps a
+// log of its writes.
+type view struct {
+	tree   *btree.BTree
+	writes []write
+	logged bool
+}
+
+type memStore struct {
+	mu   sync.RWMutex
+	tree *btree.BTree
+}
+
+type memTransaction struct {
+	db     *memStore
+	v      *view
+	closed bool
+}
+
+type memIterator struct {
+	tree   *btree.BTree
+	prefix []byte
+	next   []byte
+	items  []*item
+	cur    *item
+	done   bool
+}
@@ -24,16 +24,21 @@ func (db *pbStore) NewIterator(prefix []byte, start []byte) store.Iterator {
 			UpperBound: keyUpperBound(prefix),
 		}
 	}
-	itr := db.db.NewIter(prefixIterOptions(prefix))
-	itr.SeekGE(start)
 	return &pbIterator{
-		itr: itr,
+		itr:   db.db.NewIter(prefixIterOptions(prefix)),
+		start: append(append([]byte{}, prefix...), start...),
 	}
 }
 
+// Next moves to the next key, the first call positions the iterator at
+// prefix+start (or the key after it).
 func (itr *pbIterator) Next() bool {
-	itr.itr.Next()
-	return itr.itr.Valid()
+	if itr.start != nil {
+		start := itr.start
+		itr.start = nil
+		return itr.itr.SeekGE(start)
+	}
+	return itr.itr.Next()
 }
 
 func (itr *pbIterator) Error() error {
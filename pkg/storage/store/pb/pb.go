@@ -1,18 +1,33 @@
 package pb
 
 import (
+	"errors"
+
 	"metechain/pkg/storage/miscellaneous"
 	"metechain/pkg/storage/store"
 
 	"github.com/cockroachdb/pebble"
 )
 
+var errTxnClosed = errors.New("transaction already committed or canceled")
+
+// New wraps db as a store.DB. Every write is synced to the WAL before it returns.
 func New(db *pebble.DB) store.DB {
-	return &pbStore{db}
+	return NewWithSync(db, true)
+}
+
+// NewWithSync wraps db as a store.DB, sync controls whether writes and
+// transaction commits wait for the WAL to be fsynced.
+func NewWithSync(db *pebble.DB, sync bool) store.DB {
+	wo := pebble.NoSync
+	if sync {
+		wo = pebble.Sync
+	}
+	return &pbStore{db: db, wo: wo}
 }
 
 func (db *pbStore) Sync() error {
-	return nil
+	return db.db.LogData(nil, pebble.Sync)
 }
 
 func (db *pbStore) Close() error {
@@ -20,65 +35,72 @@ func (db *pbStore) Close() error {
 }
 
 func (db *pbStore) Del(k []byte) error {
-	return db.db.Delete(k, nil)
+	return db.db.Delete(k, db.wo)
 }
 
 func (db *pbStore) Set(k, v []byte) error {
-	return db.db.Set(k, v, nil)
+	return db.db.Set(k, v, db.wo)
 }
 
 func (db *pbStore) Get(k []byte) ([]byte, error) {
-	v, c, err := db.db.Get(k)
-	if err != nil {
-		return nil, err
-	}
-	val := make([]byte, len(v))
-	copy(val, v)
-	c.Close()
-	return v, nil
+	return get(db.db, k)
 }
 
+// NewTransaction returns a transaction backed by an indexed batch. Reads inside
+// the transaction see its own writes, nothing reaches the database until Commit.
 func (db *pbStore) NewTransaction() store.Transaction {
-	return &pbTransaction{db: db.db}
+	return &pbTransaction{db: db.db.NewIndexedBatch(), wo: db.wo}
 }
 
-func (tx *pbTransaction) Cancel() error {
+// update runs fn against a fresh batch and commits it, so that compound
+// operations such as list pushes are applied atomically.
+func (db *pbStore) update(fn func(tx readWriter) error) error {
+	tx := db.db.NewIndexedBatch()
+	defer tx.Close()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(db.wo)
+}
 
-	return nil
+func (tx *pbTransaction) Cancel() error {
+	if tx.closed {
+		return nil
+	}
+	tx.closed = true
+	return tx.db.Close()
 }
 
 func (tx *pbTransaction) Commit() error {
-	return nil
+	if tx.closed {
+		return errTxnClosed
+	}
+	tx.closed = true
+	defer tx.db.Close()
+	return tx.db.Commit(tx.wo)
 }
 
 func (tx *pbTransaction) Del(k []byte) error {
-	return tx.db.Delete(k, nil)
+	return del(tx.db, k)
 }
 
 func (tx *pbTransaction) Set(k, v []byte) error {
-	return tx.db.Set(k, v, nil)
+	return set(tx.db, k, v)
 }
 
 func (tx *pbTransaction) Get(k []byte) ([]byte, error) {
-	v, c, err := tx.db.Get(k)
-	if err != nil {
-		return nil, err
-	}
-	val := make([]byte, len(v))
-	copy(val, v)
-	c.Close()
-	return v, nil
+	return get(tx.db, k)
 }
 
-func del(tx *pebble.DB, k []byte) error {
+func del(tx readWriter, k []byte) error {
 	return tx.Delete(k, nil)
 }
 
-func set(tx *pebble.DB, k, v []byte) error {
+func set(tx readWriter, k, v []byte) error {
 	return tx.Set(k, v, nil)
 }
 
-func get(tx *pebble.DB, k []byte) ([]byte, error) {
+func get(tx readWriter, k []byte) ([]byte, error) {
 	v, c, err := tx.Get(k)
 	if err != nil {
 		return nil, err
@@ -86,28 +108,25 @@ func get(tx *pebble.DB, k []byte) ([]byte, error) {
 	val := make([]byte, len(v))
 	copy(val, v)
 	c.Close()
-	return v, nil
+	return val, nil
 }
 
 func (db *pbStore) Mclear(m []byte) error {
-	if err := mclear(db.db, m); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return mclear(tx, m)
+	})
 }
 
 func (db *pbStore) Mdel(m, k []byte) error {
-	if err := mdel(db.db, m, k); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return mdel(tx, m, k)
+	})
 }
 
 func (db *pbStore) Mset(m, k, v []byte) error {
-	if err := mset(db.db, m, k, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return mset(tx, m, k, v)
+	})
 }
 
 func (db *pbStore) Mget(m, k []byte) ([]byte, error) {
@@ -131,26 +150,57 @@ func (db *pbStore) Llen(k []byte) int64 {
 }
 
 func (db *pbStore) Lclear(k []byte) error {
-	if err := llclear(db.db, k); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return llclear(tx, k)
+	})
 }
 
 func (db *pbStore) Llpush(k, v []byte) (int64, error) {
-	return llpush(db.db, k, v)
+	var n int64
+	err := db.update(func(tx readWriter) (err error) {
+		n, err = llpush(tx, k, v)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return n, nil
 }
 
 func (db *pbStore) Llpop(k []byte) ([]byte, error) {
-	return llpop(db.db, k)
+	var v []byte
+	err := db.update(func(tx readWriter) (err error) {
+		v, err = llpop(tx, k)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
 func (db *pbStore) Lrpush(k, v []byte) (int64, error) {
-	return lrpush(db.db, k, v)
+	var n int64
+	err := db.update(func(tx readWriter) (err error) {
+		n, err = lrpush(tx, k, v)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return n, nil
 }
 
 func (db *pbStore) Lrpop(k []byte) ([]byte, error) {
-	return lrpop(db.db, k)
+	var v []byte
+	err := db.update(func(tx readWriter) (err error) {
+		v, err = lrpop(tx, k)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
 func (db *pbStore) Lrange(k []byte, start, end int64) ([][]byte, error) {
@@ -158,10 +208,9 @@ func (db *pbStore) Lrange(k []byte, start, end int64) ([][]byte, error) {
 }
 
 func (db *pbStore) Lset(k []byte, idx int64, v []byte) error {
-	if err := lset(db.db, k, idx, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return lset(tx, k, idx, v)
+	})
 }
 
 func (db *pbStore) Lindex(k []byte, idx int64) ([]byte, error) {
@@ -169,24 +218,21 @@ func (db *pbStore) Lindex(k []byte, idx int64) ([]byte, error) {
 }
 
 func (db *pbStore) Sclear(k []byte) error {
-	if err := sclear(db.db, k); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return sclear(tx, k)
+	})
 }
 
 func (db *pbStore) Sdel(k, v []byte) error {
-	if err := sdel(db.db, k, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return sdel(tx, k, v)
+	})
 }
 
 func (db *pbStore) Sadd(k, v []byte) error {
-	if err := sadd(db.db, k, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return sadd(tx, k, v)
+	})
 }
 
 func (db *pbStore) Selem(k, v []byte) (bool, error) {
@@ -198,24 +244,21 @@ func (db *pbStore) Smembers(k []byte) ([][]byte, error) {
 }
 
 func (db *pbStore) Zclear(k []byte) error {
-	if err := zclear(db.db, k); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return zclear(tx, k)
+	})
 }
 
 func (db *pbStore) Zdel(k, v []byte) error {
-	if err := zdel(db.db, k, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return zdel(tx, k, v)
+	})
 }
 
 func (db *pbStore) Zadd(k []byte, score int32, v []byte) error {
-	if err := zadd(db.db, k, score, v); err != nil {
-		return err
-	}
-	return nil
+	return db.update(func(tx readWriter) error {
+		return zadd(tx, k, score, v)
+	})
 }
 
 func (db *pbStore) Zscore(k, v []byte) (int32, error) {
@@ -330,7 +373,7 @@ func (tx *pbTransaction) Zrange(k []byte, start, end int32) ([][]byte, error) {
 	return zrange(tx.db, k, start, end)
 }
 
-func mclear(tx *pebble.DB, m []byte) error {
+func mclear(tx readWriter, m []byte) error {
 	k := eMapKey(m, []byte{})
 	keyUpperBound := func(b []byte) []byte {
 		end := make([]byte, len(b))
@@ -352,26 +395,26 @@ func mclear(tx *pebble.DB, m []byte) error {
 	itr := tx.NewIter(prefixIterOptions(k))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
-		if err := tx.Delete(itr.Key(), nil); err != nil {
+		if err := del(tx, itr.Key()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func mdel(tx *pebble.DB, m, k []byte) error {
+func mdel(tx readWriter, m, k []byte) error {
 	return del(tx, eMapKey(m, k))
 }
 
-func mset(tx *pebble.DB, m, k, v []byte) error {
+func mset(tx readWriter, m, k, v []byte) error {
 	return set(tx, eMapKey(m, k), v)
 }
 
-func mget(tx *pebble.DB, m, k []byte) ([]byte, error) {
+func mget(tx readWriter, m, k []byte) ([]byte, error) {
 	return get(tx, eMapKey(m, k))
 }
 
-func mkeys(tx *pebble.DB, m []byte) ([][]byte, error) {
+func mkeys(tx readWriter, m []byte) ([][]byte, error) {
 	var ks [][]byte
 
 	k := eMapKey(m, []byte{})
@@ -395,12 +438,12 @@ func mkeys(tx *pebble.DB, m []byte) ([][]byte, error) {
 	itr := tx.NewIter(prefixIterOptions(k))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
-		ks = append(ks, append([]byte{}, itr.Key()...))
+		ks = append(ks, dMapKey(append([]byte{}, itr.Key()...)))
 	}
 	return ks, nil
 }
 
-func mvals(tx *pebble.DB, m []byte) ([][]byte, error) {
+func mvals(tx readWriter, m []byte) ([][]byte, error) {
 	var vs [][]byte
 
 	k := eMapKey(m, []byte{})
@@ -429,7 +472,7 @@ func mvals(tx *pebble.DB, m []byte) ([][]byte, error) {
 	return vs, nil
 }
 
-func mkvs(tx *pebble.DB, m []byte) ([][]byte, [][]byte, error) {
+func mkvs(tx readWriter, m []byte) ([][]byte, [][]byte, error) {
 	var ks, vs [][]byte
 
 	k := eMapKey(m, []byte{})
@@ -453,17 +496,17 @@ func mkvs(tx *pebble.DB, m []byte) ([][]byte, [][]byte, error) {
 	itr := tx.NewIter(prefixIterOptions(k))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
-		ks = append(ks, append([]byte{}, itr.Key()...))
+		ks = append(ks, dMapKey(append([]byte{}, itr.Key()...)))
 		vs = append(vs, append([]byte{}, itr.Value()...))
 	}
 	return ks, vs, nil
 }
 
-func lnew(tx *pebble.DB, k []byte) error {
+func lnew(tx readWriter, k []byte) error {
 	return set(tx, eListmeteKey(k), eListmeteValue(0, 0))
 }
 
-func llen(tx *pebble.DB, k []byte) int64 {
+func llen(tx readWriter, k []byte) int64 {
 	if start, end, err := listStartEnd(tx, k); err != nil {
 		return 0
 	} else {
@@ -471,7 +514,7 @@ func llen(tx *pebble.DB, k []byte) int64 {
 	}
 }
 
-func llclear(tx *pebble.DB, k []byte) error {
+func llclear(tx readWriter, k []byte) error {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		return err
@@ -484,7 +527,7 @@ func llclear(tx *pebble.DB, k []byte) error {
 	return del(tx, eListmeteKey(k))
 }
 
-func llpush(tx *pebble.DB, k, v []byte) (int64, error) {
+func llpush(tx readWriter, k, v []byte) (int64, error) {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		if err = lnew(tx, k); err != nil {
@@ -503,7 +546,7 @@ func llpush(tx *pebble.DB, k, v []byte) (int64, error) {
 	return end - start + 1, nil
 }
 
-func llpop(tx *pebble.DB, k []byte) ([]byte, error) {
+func llpop(tx readWriter, k []byte) ([]byte, error) {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		return nil, err
@@ -521,7 +564,7 @@ func llpop(tx *pebble.DB, k []byte) ([]byte, error) {
 	return v, nil
 }
 
-func lrpush(tx *pebble.DB, k, v []byte) (int64, error) {
+func lrpush(tx readWriter, k, v []byte) (int64, error) {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		if err = lnew(tx, k); err != nil {
@@ -540,7 +583,7 @@ func lrpush(tx *pebble.DB, k, v []byte) (int64, error) {
 	return end - start + 1, nil
 }
 
-func lrpop(tx *pebble.DB, k []byte) ([]byte, error) {
+func lrpop(tx readWriter, k []byte) ([]byte, error) {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		return nil, err
@@ -558,7 +601,7 @@ func lrpop(tx *pebble.DB, k []byte) ([]byte, error) {
 	return v, nil
 }
 
-func lset(tx *pebble.DB, k []byte, idx int64, v []byte) error {
+func lset(tx readWriter, k []byte, idx int64, v []byte) error {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		return err
@@ -572,10 +615,10 @@ func lset(tx *pebble.DB, k []byte, idx int64, v []byte) error {
 	if idx < start || idx >= end {
 		return store.OutOfSize
 	}
-	return set(tx, eListKey(k, idx+start), v)
+	return set(tx, eListKey(k, idx), v)
 }
 
-func lindex(tx *pebble.DB, k []byte, idx int64) ([]byte, error) {
+func lindex(tx readWriter, k []byte, idx int64) ([]byte, error) {
 	start, end, err := listStartEnd(tx, k)
 	if err != nil {
 		return nil, err
@@ -592,7 +635,7 @@ func lindex(tx *pebble.DB, k []byte, idx int64) ([]byte, error) {
 	return get(tx, eListKey(k, idx))
 }
 
-func lrange(tx *pebble.DB, k []byte, start, end int64) ([][]byte, error) {
+func lrange(tx readWriter, k []byte, start, end int64) ([][]byte, error) {
 	var vs [][]byte
 
 	x, y, err := listStartEnd(tx, k)
@@ -625,7 +668,7 @@ func lrange(tx *pebble.DB, k []byte, start, end int64) ([][]byte, error) {
 	return vs, nil
 }
 
-func sclear(tx *pebble.DB, k []byte) error {
+func sclear(tx readWriter, k []byte) error {
 	k = eSetKey(k, []byte{})
 	keyUpperBound := func(b []byte) []byte {
 		end := make([]byte, len(b))
@@ -647,22 +690,22 @@ func sclear(tx *pebble.DB, k []byte) error {
 	itr := tx.NewIter(prefixIterOptions(k))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
-		if err := tx.Delete(itr.Key(), nil); err != nil {
+		if err := del(tx, itr.Key()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func sdel(tx *pebble.DB, k, v []byte) error {
+func sdel(tx readWriter, k, v []byte) error {
 	return del(tx, eSetKey(k, v))
 }
 
-func sadd(tx *pebble.DB, k, v []byte) error {
+func sadd(tx readWriter, k, v []byte) error {
 	return set(tx, eSetKey(k, v), []byte{})
 }
 
-func selem(tx *pebble.DB, k, v []byte) (bool, error) {
+func selem(tx readWriter, k, v []byte) (bool, error) {
 	_, err := get(tx, eSetKey(k, v))
 	switch {
 	case err == nil:
@@ -674,7 +717,7 @@ func selem(tx *pebble.DB, k, v []byte) (bool, error) {
 	}
 }
 
-func smembers(tx *pebble.DB, k []byte) ([][]byte, error) {
+func smembers(tx readWriter, k []byte) ([][]byte, error) {
 	var vs [][]byte
 
 	k = eSetKey(k, []byte{})
@@ -698,12 +741,12 @@ func smembers(tx *pebble.DB, k []byte) ([][]byte, error) {
 	itr := tx.NewIter(prefixIterOptions(k))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
-		vs = append(vs, append([]byte{}, itr.Value()...))
+		vs = append(vs, dSetKey(append([]byte{}, itr.Key()...)))
 	}
 	return vs, nil
 }
 
-func zclear(tx *pebble.DB, k []byte) error {
+func zclear(tx readWriter, k []byte) error {
 	key := []byte{}
 	key = append([]byte("sz"), miscellaneous.E32func(uint32(len(k)))...)
 	key = append(key, k...)
@@ -725,7 +768,7 @@ func zclear(tx *pebble.DB, k []byte) error {
 			UpperBound: keyUpperBound(prefix),
 		}
 	}
-	itr := tx.NewIter(prefixIterOptions(k))
+	itr := tx.NewIter(prefixIterOptions(key))
 	defer itr.Close()
 	for itr.First(); itr.Valid(); itr.Next() {
 		score, v := dZetScore(itr.Key())
@@ -739,7 +782,7 @@ func zclear(tx *pebble.DB, k []byte) error {
 	return nil
 }
 
-func zdel(tx *pebble.DB, k, v []byte) error {
+func zdel(tx readWriter, k, v []byte) error {
 	key := eZetKey(k, v)
 	buf, err := get(tx, key)
 	if err != nil {
@@ -755,7 +798,7 @@ func zdel(tx *pebble.DB, k, v []byte) error {
 	return nil
 }
 
-func zscore(tx *pebble.DB, k, v []byte) (int32, error) {
+func zscore(tx readWriter, k, v []byte) (int32, error) {
 	if buf, err := get(tx, eZetKey(k, v)); err != nil {
 		return -1, err
 	} else {
@@ -764,7 +807,16 @@ func zscore(tx *pebble.DB, k, v []byte) (int32, error) {
 	}
 }
 
-func zadd(tx *pebble.DB, k []byte, score int32, v []byte) error {
+func zadd(tx readWriter, k []byte, score int32, v []byte) error {
+	// drop the old score index, otherwise the member shows up twice in Zrange
+	if buf, err := get(tx, eZetKey(k, v)); err == nil {
+		old, _ := miscellaneous.D32func(buf)
+		if err := del(tx, eZetScore(k, v, int32(old))); err != nil {
+			return err
+		}
+	} else if err != store.NotExist {
+		return err
+	}
 	if err := set(tx, eZetKey(k, v), miscellaneous.E32func(uint32(score))); err != nil {
 		return err
 	}
@@ -774,7 +826,7 @@ func zadd(tx *pebble.DB, k []byte, score int32, v []byte) error {
 	return nil
 }
 
-func zrange(tx *pebble.DB, k []byte, start, end int32) ([][]byte, error) {
+func zrange(tx readWriter, k []byte, start, end int32) ([][]byte, error) {
 	var vs [][]byte
 
 	key := []byte{}
@@ -798,9 +850,9 @@ func zrange(tx *pebble.DB, k []byte, start, end int32) ([][]byte, error) {
 			UpperBound: keyUpperBound(prefix),
 		}
 	}
-	itr := tx.NewIter(prefixIterOptions(k))
+	itr := tx.NewIter(prefixIterOptions(key))
 	defer itr.Close()
-	for itr.First(); itr.Valid(); itr.Next() {
+	for itr.SeekGE(eZetScore(k, []byte{}, start)); itr.Valid(); itr.Next() {
 		if score, v := dZetScore(itr.Key()); score > end {
 			break
 		} else {
@@ -810,7 +862,7 @@ func zrange(tx *pebble.DB, k []byte, start, end int32) ([][]byte, error) {
 	return vs, nil
 }
 
-func listStartEnd(tx *pebble.DB, k []byte) (int64, int64, error) {
+func listStartEnd(tx readWriter, k []byte) (int64, int64, error) {
 	if v, err := get(tx, eListmeteKey(k)); err != nil {
 		return 0, 0, err
 	} else {
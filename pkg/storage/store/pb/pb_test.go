@@ -0,0 +1,28 @@
+package pb
+
+import (
+	"testing"
+
+	"metechain/pkg/storage/store"
+	"metechain/pkg/storage/store/storetest"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.DB {
+		db, err := pebble.Open(t.TempDir(), &pebble.Options{})
+		require.NoError(t, err)
+		return New(db)
+	})
+}
+
+func TestStoreInMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.DB {
+		db, err := pebble.Open("", &pebble.Options{FS: vfs.NewMem()})
+		require.NoError(t, err)
+		return NewWithSync(db, false)
+	})
+}
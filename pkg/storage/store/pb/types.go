@@ -2,15 +2,26 @@ package pb
 
 import "github.com/cockroachdb/pebble"
 
+// readWriter is implemented by both *pebble.DB and an indexed *pebble.Batch,
+// so the map/list/set/zset helpers work the same inside and outside a transaction.
+type readWriter interface {
+	pebble.Reader
+	pebble.Writer
+}
+
 type pbStore struct {
 	db *pebble.DB
+	wo *pebble.WriteOptions
 }
 
 type pbTransaction struct {
-	db *pebble.DB
+	db     *pebble.Batch
+	wo     *pebble.WriteOptions
+	closed bool
 }
 
 type pbIterator struct {
-	err error
-	itr *pebble.Iterator
+	err   error
+	itr   *pebble.Iterator
+	start []byte
 }
@@ -0,0 +1,458 @@
+// Package storetest is a conformance suite shared by every store.DB backend.
+// A backend runs it from its own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.DB { return open(t.TempDir()) })
+//	}
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"metechain/pkg/storage/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory opens an empty store.DB. Run closes it when the subtest ends.
+type Factory func(t *testing.T) store.DB
+
+// ops is the set of operations shared by store.DB and store.Transaction,
+// so that every data structure is checked both directly and inside a transaction.
+type ops interface {
+	Del([]byte) error
+	Set([]byte, []byte) error
+	Get([]byte) ([]byte, error)
+
+	Mclear([]byte) error
+	Mdel([]byte, []byte) error
+	Mkeys([]byte) ([][]byte, error)
+	Mvals([]byte) ([][]byte, error)
+	Mset([]byte, []byte, []byte) error
+	Mget([]byte, []byte) ([]byte, error)
+	Mkvs([]byte) ([][]byte, [][]byte, error)
+
+	Llen([]byte) int64
+	Lclear([]byte) error
+	Llpop([]byte) ([]byte, error)
+	Lrpop([]byte) ([]byte, error)
+	Lset([]byte, int64, []byte) error
+	Lrpush([]byte, []byte) (int64, error)
+	Llpush([]byte, []byte) (int64, error)
+	Lindex([]byte, int64) ([]byte, error)
+	Lrange([]byte, int64, int64) ([][]byte, error)
+
+	Sclear([]byte) error
+	Sadd([]byte, []byte) error
+	Sdel([]byte, []byte) error
+	Smembers([]byte) ([][]byte, error)
+	Selem([]byte, []byte) (bool, error)
+
+	Zclear([]byte) error
+	Zdel([]byte, []byte) error
+	Zadd([]byte, int32, []byte) error
+	Zscore([]byte, []byte) (int32, error)
+	Zrange([]byte, int32, int32) ([][]byte, error)
+}
+
+var (
+	_ ops = store.DB(nil)
+	_ ops = store.Transaction(nil)
+)
+
+// Run checks newDB against the store.DB contract.
+func Run(t *testing.T, newDB Factory) {
+	for _, c := range []struct {
+		name string
+		fn   func(*testing.T, ops)
+	}{
+		{"KV", testKV},
+		{"Map", testMap},
+		{"List", testList},
+		{"Set", testSet},
+		{"SortedSet", testSortedSet},
+	} {
+		c := c
+		t.Run(c.name+"/DB", func(t *testing.T) {
+			db := open(t, newDB)
+			c.fn(t, db)
+		})
+		t.Run(c.name+"/Transaction", func(t *testing.T) {
+			db := open(t, newDB)
+			tx := db.NewTransaction()
+			defer tx.Cancel()
+			c.fn(t, tx)
+			require.NoError(t, tx.Commit())
+		})
+	}
+
+	t.Run("Iterator", func(t *testing.T) { testIterator(t, open(t, newDB)) })
+	t.Run("TransactionIsolation", func(t *testing.T) { testIsolation(t, open(t, newDB)) })
+	t.Run("TransactionCommit", func(t *testing.T) { testCommit(t, open(t, newDB)) })
+	t.Run("TransactionCancel", func(t *testing.T) { testCancel(t, open(t, newDB)) })
+}
+
+func open(t *testing.T, newDB Factory) store.DB {
+	db := newDB(t)
+	require.NotNil(t, db)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testKV(t *testing.T, db ops) {
+	assert := assert.New(t)
+
+	_, err := db.Get([]byte("k"))
+	assert.Equal(store.NotExist, err)
+
+	assert.NoError(db.Set([]byte("k"), []byte("v1")))
+	v, err := db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("v1"), v)
+
+	assert.NoError(db.Set([]byte("k"), []byte("v2")))
+	v, err = db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("v2"), v)
+
+	// the returned slice belongs to the caller
+	v[0] = 'x'
+	v, err = db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("v2"), v)
+
+	assert.NoError(db.Del([]byte("k")))
+	_, err = db.Get([]byte("k"))
+	assert.Equal(store.NotExist, err)
+
+	// deleting a missing key is not an error
+	assert.NoError(db.Del([]byte("missing")))
+}
+
+func testMap(t *testing.T, db ops) {
+	assert := assert.New(t)
+	m := []byte("map")
+
+	_, err := db.Mget(m, []byte("a"))
+	assert.Equal(store.NotExist, err)
+
+	assert.NoError(db.Mset(m, []byte("b"), []byte("2")))
+	assert.NoError(db.Mset(m, []byte("a"), []byte("1")))
+	assert.NoError(db.Mset(m, []byte("c"), []byte("3")))
+	// a map whose name extends m must not leak into m
+	assert.NoError(db.Mset([]byte("map2"), []byte("z"), []byte("26")))
+
+	v, err := db.Mget(m, []byte("b"))
+	assert.NoError(err)
+	assert.Equal([]byte("2"), v)
+
+	ks, err := db.Mkeys(m)
+	assert.NoError(err)
+	assert.Equal(bss("a", "b", "c"), ks)
+
+	vs, err := db.Mvals(m)
+	assert.NoError(err)
+	assert.Equal(bss("1", "2", "3"), vs)
+
+	ks, vs, err = db.Mkvs(m)
+	assert.NoError(err)
+	assert.Equal(bss("a", "b", "c"), ks)
+	assert.Equal(bss("1", "2", "3"), vs)
+
+	assert.NoError(db.Mdel(m, []byte("b")))
+	_, err = db.Mget(m, []byte("b"))
+	assert.Equal(store.NotExist, err)
+	ks, err = db.Mkeys(m)
+	assert.NoError(err)
+	assert.Equal(bss("a", "c"), ks)
+
+	assert.NoError(db.Mclear(m))
+	ks, err = db.Mkeys(m)
+	assert.NoError(err)
+	assert.Empty(ks)
+
+	ks, err = db.Mkeys([]byte("map2"))
+	assert.NoError(err)
+	assert.Equal(bss("z"), ks)
+}
+
+func testList(t *testing.T, db ops) {
+	assert := assert.New(t)
+	k := []byte("list")
+
+	assert.Equal(int64(0), db.Llen(k))
+	_, err := db.Llpop(k)
+	assert.Equal(store.NotExist, err)
+
+	n, err := db.Lrpush(k, []byte("b"))
+	assert.NoError(err)
+	assert.Equal(int64(1), n)
+	n, err = db.Lrpush(k, []byte("c"))
+	assert.NoError(err)
+	assert.Equal(int64(2), n)
+	n, err = db.Llpush(k, []byte("a"))
+	assert.NoError(err)
+	assert.Equal(int64(3), n)
+	assert.Equal(int64(3), db.Llen(k))
+
+	vs, err := db.Lrange(k, 0, -1)
+	assert.NoError(err)
+	assert.Equal(bss("a", "b", "c"), vs)
+	vs, err = db.Lrange(k, 1, 1)
+	assert.NoError(err)
+	assert.Equal(bss("b"), vs)
+	vs, err = db.Lrange(k, -2, -1)
+	assert.NoError(err)
+	assert.Equal(bss("b", "c"), vs)
+
+	v, err := db.Lindex(k, 0)
+	assert.NoError(err)
+	assert.Equal([]byte("a"), v)
+	v, err = db.Lindex(k, -1)
+	assert.NoError(err)
+	assert.Equal([]byte("c"), v)
+	v, err = db.Lindex(k, 3)
+	assert.NoError(err)
+	assert.Empty(v)
+
+	assert.NoError(db.Lset(k, 1, []byte("B")))
+	assert.NoError(db.Lset(k, -1, []byte("C")))
+	assert.Equal(store.OutOfSize, db.Lset(k, 3, []byte("x")))
+	vs, err = db.Lrange(k, 0, -1)
+	assert.NoError(err)
+	assert.Equal(bss("a", "B", "C"), vs)
+
+	v, err = db.Llpop(k)
+	assert.NoError(err)
+	assert.Equal([]byte("a"), v)
+	v, err = db.Lrpop(k)
+	assert.NoError(err)
+	assert.Equal([]byte("C"), v)
+	assert.Equal(int64(1), db.Llen(k))
+
+	v, err = db.Lrpop(k)
+	assert.NoError(err)
+	assert.Equal([]byte("B"), v)
+	_, err = db.Lrpop(k)
+	assert.Equal(store.OutOfSize, err)
+
+	_, err = db.Lrpush(k, []byte("x"))
+	assert.NoError(err)
+	assert.NoError(db.Lclear(k))
+	assert.Equal(int64(0), db.Llen(k))
+}
+
+func testSet(t *testing.T, db ops) {
+	assert := assert.New(t)
+	k := []byte("set")
+
+	ok, err := db.Selem(k, []byte("a"))
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(db.Sadd(k, []byte("b")))
+	assert.NoError(db.Sadd(k, []byte("a")))
+	assert.NoError(db.Sadd(k, []byte("a")))
+	assert.NoError(db.Sadd([]byte("set2"), []byte("z")))
+
+	ok, err = db.Selem(k, []byte("a"))
+	assert.NoError(err)
+	assert.True(ok)
+
+	vs, err := db.Smembers(k)
+	assert.NoError(err)
+	assert.Equal(bss("a", "b"), vs)
+
+	assert.NoError(db.Sdel(k, []byte("a")))
+	ok, err = db.Selem(k, []byte("a"))
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(db.Sclear(k))
+	vs, err = db.Smembers(k)
+	assert.NoError(err)
+	assert.Empty(vs)
+
+	vs, err = db.Smembers([]byte("set2"))
+	assert.NoError(err)
+	assert.Equal(bss("z"), vs)
+}
+
+func testSortedSet(t *testing.T, db ops) {
+	assert := assert.New(t)
+	k := []byte("zset")
+
+	_, err := db.Zscore(k, []byte("a"))
+	assert.Equal(store.NotExist, err)
+
+	assert.NoError(db.Zadd(k, 30, []byte("c")))
+	assert.NoError(db.Zadd(k, 10, []byte("a")))
+	assert.NoError(db.Zadd(k, 20, []byte("b")))
+	assert.NoError(db.Zadd(k, 20, []byte("bb")))
+	assert.NoError(db.Zadd([]byte("zset2"), 15, []byte("z")))
+
+	score, err := db.Zscore(k, []byte("b"))
+	assert.NoError(err)
+	assert.Equal(int32(20), score)
+
+	vs, err := db.Zrange(k, 0, 100)
+	assert.NoError(err)
+	assert.Equal(bss("a", "b", "bb", "c"), vs)
+	vs, err = db.Zrange(k, 15, 20)
+	assert.NoError(err)
+	assert.Equal(bss("b", "bb"), vs)
+
+	// re-adding a member moves it instead of duplicating it
+	assert.NoError(db.Zadd(k, 40, []byte("a")))
+	score, err = db.Zscore(k, []byte("a"))
+	assert.NoError(err)
+	assert.Equal(int32(40), score)
+	vs, err = db.Zrange(k, 0, 100)
+	assert.NoError(err)
+	assert.Equal(bss("b", "bb", "c", "a"), vs)
+
+	assert.NoError(db.Zdel(k, []byte("b")))
+	_, err = db.Zscore(k, []byte("b"))
+	assert.Equal(store.NotExist, err)
+	vs, err = db.Zrange(k, 0, 100)
+	assert.NoError(err)
+	assert.Equal(bss("bb", "c", "a"), vs)
+
+	assert.NoError(db.Zclear(k))
+	vs, err = db.Zrange(k, 0, 100)
+	assert.NoError(err)
+	assert.Empty(vs)
+	_, err = db.Zscore(k, []byte("c"))
+	assert.Equal(store.NotExist, err)
+
+	vs, err = db.Zrange([]byte("zset2"), 0, 100)
+	assert.NoError(err)
+	assert.Equal(bss("z"), vs)
+}
+
+func testIterator(t *testing.T, db store.DB) {
+	assert := assert.New(t)
+
+	for _, k := range []string{"a1", "b1", "b2", "b3", "c1"} {
+		require.NoError(t, db.Set([]byte(k), []byte("v"+k)))
+	}
+
+	collect := func(prefix, start []byte) []string {
+		var ks []string
+		itr := db.NewIterator(prefix, start)
+		defer itr.Release()
+		for itr.Next() {
+			ks = append(ks, string(itr.Key()))
+			assert.Equal("v"+string(itr.Key()), string(itr.Value()))
+		}
+		assert.NoError(itr.Error())
+		return ks
+	}
+
+	assert.Equal([]string{"a1", "b1", "b2", "b3", "c1"}, collect(nil, nil))
+	assert.Equal([]string{"b1", "b2", "b3"}, collect([]byte("b"), nil))
+	// start is relative to the prefix and positions at or after that key
+	assert.Equal([]string{"b2", "b3"}, collect([]byte("b"), []byte("2")))
+	assert.Equal([]string{"b3"}, collect([]byte("b"), []byte("21")))
+	assert.Empty(collect([]byte("b"), []byte("4")))
+	assert.Empty(collect([]byte("d"), nil))
+}
+
+func testIsolation(t *testing.T, db store.DB) {
+	assert := assert.New(t)
+	require.NoError(t, db.Set([]byte("k"), []byte("old")))
+
+	tx := db.NewTransaction()
+	defer tx.Cancel()
+	assert.NoError(tx.Set([]byte("k"), []byte("new")))
+	assert.NoError(tx.Set([]byte("k2"), []byte("v2")))
+	assert.NoError(tx.Mset([]byte("m"), []byte("a"), []byte("1")))
+	_, err := tx.Lrpush([]byte("l"), []byte("x"))
+	assert.NoError(err)
+
+	// the transaction reads its own writes
+	v, err := tx.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("new"), v)
+	ks, err := tx.Mkeys([]byte("m"))
+	assert.NoError(err)
+	assert.Equal(bss("a"), ks)
+	assert.Equal(int64(1), tx.Llen([]byte("l")))
+
+	// nobody else does until it commits
+	v, err = db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("old"), v)
+	_, err = db.Get([]byte("k2"))
+	assert.Equal(store.NotExist, err)
+	_, err = db.Mget([]byte("m"), []byte("a"))
+	assert.Equal(store.NotExist, err)
+	assert.Equal(int64(0), db.Llen([]byte("l")))
+
+	itr := db.NewIterator([]byte("k"), nil)
+	var n int
+	for itr.Next() {
+		n++
+	}
+	itr.Release()
+	assert.Equal(1, n)
+}
+
+func testCommit(t *testing.T, db store.DB) {
+	assert := assert.New(t)
+	require.NoError(t, db.Set([]byte("gone"), []byte("v")))
+
+	tx := db.NewTransaction()
+	defer tx.Cancel()
+	for i := 0; i < 100; i++ {
+		assert.NoError(tx.Set([]byte(fmt.Sprintf("k%03d", i)), []byte{byte(i)}))
+	}
+	assert.NoError(tx.Del([]byte("gone")))
+	assert.NoError(tx.Zadd([]byte("z"), 1, []byte("a")))
+	assert.NoError(tx.Commit())
+
+	for i := 0; i < 100; i++ {
+		v, err := db.Get([]byte(fmt.Sprintf("k%03d", i)))
+		assert.NoError(err)
+		assert.Equal([]byte{byte(i)}, v)
+	}
+	_, err := db.Get([]byte("gone"))
+	assert.Equal(store.NotExist, err)
+	score, err := db.Zscore([]byte("z"), []byte("a"))
+	assert.NoError(err)
+	assert.Equal(int32(1), score)
+
+	// canceling after a commit is harmless, which lets callers defer Cancel
+	assert.NoError(tx.Cancel())
+}
+
+func testCancel(t *testing.T, db store.DB) {
+	assert := assert.New(t)
+	require.NoError(t, db.Set([]byte("k"), []byte("old")))
+
+	tx := db.NewTransaction()
+	assert.NoError(tx.Set([]byte("k"), []byte("new")))
+	assert.NoError(tx.Del([]byte("k")))
+	assert.NoError(tx.Set([]byte("k2"), []byte("v2")))
+	assert.NoError(tx.Sadd([]byte("s"), []byte("a")))
+	assert.NoError(tx.Cancel())
+
+	v, err := db.Get([]byte("k"))
+	assert.NoError(err)
+	assert.Equal([]byte("old"), v)
+	_, err = db.Get([]byte("k2"))
+	assert.Equal(store.NotExist, err)
+	ok, err := db.Selem([]byte("s"), []byte("a"))
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func bss(ss ...string) [][]byte {
+	bs := make([][]byte, len(ss))
+	for i, s := range ss {
+		bs[i] = []byte(s)
+	}
+	return bs
+}
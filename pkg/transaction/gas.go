@@ -0,0 +1,75 @@
+package transaction
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// IntrinsicGas is the gas a transaction uses before anything of it is executed,
+// contract transactions pay for their code or input on top
+func (t *Transaction) IntrinsicGas() (uint64, error) {
+	switch t.Type {
+	case CoinBaseTransaction:
+		return 0, nil
+	case EvmContractTransaction:
+		evmC, err := DecodeEvmData(t.Input)
+		if err != nil {
+			return 0, err
+		}
+		switch evmC.Operation {
+		case "create", "Create":
+			return params.TxGasContractCreation + dataGas(evmC.CreateCode), nil
+		default:
+			return params.TxGas + dataGas(evmC.CallInput), nil
+		}
+	default:
+		return params.TxGas, nil
+	}
+}
+
+func dataGas(data []byte) uint64 {
+	var gas uint64
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	return gas
+}
+
+// FeeCap is the most the transaction pays per gas, its gas price if it sets no
+// fee cap
+func (t *Transaction) FeeCap() *big.Int {
+	if t.GasFeeCap == nil || t.GasFeeCap.Sign() == 0 {
+		return t.GasPrice
+	}
+	return t.GasFeeCap
+}
+
+// EffectiveGasPrice is what the transaction pays per gas in a block with the
+// base fee: the base fee and its gas price as tip on top, up to its fee cap
+func (t *Transaction) EffectiveGasPrice(baseFee *big.Int) (*big.Int, error) {
+	feeCap := t.FeeCap()
+	if feeCap.Cmp(baseFee) < 0 {
+		return nil, fmt.Errorf("fee cap %v below base fee %v", feeCap, baseFee)
+	}
+	price := new(big.Int).Add(baseFee, t.GasPrice)
+	if price.Cmp(feeCap) > 0 {
+		price.Set(feeCap)
+	}
+	return price, nil
+}
+
+// EffectiveTip is what the transaction pays the miner per gas in a block with
+// the base fee, negative if its fee cap is below the base fee
+func (t *Transaction) EffectiveTip(baseFee *big.Int) *big.Int {
+	tip := new(big.Int).Sub(t.FeeCap(), baseFee)
+	if tip.Cmp(t.GasPrice) > 0 {
+		tip.Set(t.GasPrice)
+	}
+	return tip
+}
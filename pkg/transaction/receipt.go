@@ -0,0 +1,81 @@
+package transaction
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	coreTps "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// ReceiptStatusFailed is the status of a transaction whose execution failed
+	ReceiptStatusFailed = uint64(0)
+	// ReceiptStatusSuccessful is the status of a transaction that was executed
+	ReceiptStatusSuccessful = uint64(1)
+)
+
+// Receipt is the result of executing a transaction in its block
+type Receipt struct {
+	TxHash            []byte         `json:"transactionHash"`
+	Status            uint64         `json:"status"`
+	CumulativeGasUsed *big.Int       `json:"cumulativeGasUsed"` // gas used by the block up to and including the transaction
+	GasUsed           *big.Int       `json:"gasUsed"`
+	Logs              []*coreTps.Log `json:"logs"`
+	Bloom             coreTps.Bloom  `json:"logsBloom"`
+	ContractAddress   common.Address `json:"contractAddress"`   // address of the created contract
+	RevertReason      string         `json:"revertReason"`      // why a failed transaction failed
+	EffectiveGasPrice *big.Int       `json:"effectiveGasPrice"` // price paid per gas, since block.Version3
+}
+
+// NewReceipt returns the receipt of the transaction hash with the status
+func NewReceipt(hash []byte, status uint64) *Receipt {
+	return &Receipt{
+		TxHash:            hash,
+		Status:            status,
+		CumulativeGasUsed: new(big.Int),
+		GasUsed:           new(big.Int),
+	}
+}
+
+// receiptRLP is the part of a receipt that the receipts root of a block
+// commits to, logs only encode their address, topics and data
+type receiptRLP struct {
+	TxHash            []byte
+	Status            uint64
+	CumulativeGasUsed *big.Int
+	GasUsed           *big.Int
+	Bloom             coreTps.Bloom
+	Logs              []*coreTps.Log
+	ContractAddress   common.Address
+}
+
+// EncodeRLP implements rlp.Encoder, it leaves out what does not follow from
+// executing the transaction alone, like the block hash in the logs
+func (r *Receipt) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, &receiptRLP{
+		TxHash:            r.TxHash,
+		Status:            r.Status,
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		GasUsed:           r.GasUsed,
+		Bloom:             r.Bloom,
+		Logs:              r.Logs,
+		ContractAddress:   r.ContractAddress,
+	})
+}
+
+// EncodeReceipts encode the receipts of a block
+func EncodeReceipts(receipts []*Receipt) ([]byte, error) {
+	return json.Marshal(receipts)
+}
+
+// DecodeReceipts decode the receipts of a block
+func DecodeReceipts(data []byte) ([]*Receipt, error) {
+	var receipts []*Receipt
+	if err := json.Unmarshal(data, &receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
@@ -125,7 +125,7 @@ func (t *Transaction) SignHash() []byte {
 
 // GasCap gas fee upper limit
 func (t *Transaction) GasCap() *big.Int {
-	return new(big.Int).Mul(t.GasPrice, t.GasLimit)
+	return new(big.Int).Mul(t.FeeCap(), t.GasLimit)
 }
 
 // Serialize transaction in the cbor format
@@ -76,3 +76,47 @@ func TestCodec(t *testing.T) {
 	assert.Equal(mayFt.GasPrice.String(), ft.GasPrice.String())
 	assert.Equal(mayFt.GasLimit.String(), ft.GasLimit.String())
 }
+
+func TestGas(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := NewTransaction()
+	tx.Type = TransferTransaction
+	gas, err := tx.IntrinsicGas()
+	assert.NoError(err)
+	assert.Equal(uint64(21000), gas)
+
+	input, err := EncodeEvmData(&EvmContract{Operation: "create", CreateCode: []byte{0, 1, 2}})
+	assert.NoError(err)
+	tx.Type, tx.Input = EvmContractTransaction, input
+	gas, err = tx.IntrinsicGas()
+	assert.NoError(err)
+	assert.Equal(uint64(53000+4+16+16), gas)
+
+	input, err = EncodeEvmData(&EvmContract{Operation: "call", CallInput: []byte{1}})
+	assert.NoError(err)
+	tx.Input = input
+	gas, err = tx.IntrinsicGas()
+	assert.NoError(err)
+	assert.Equal(uint64(21000+16), gas)
+
+	// without a fee cap the gas price is all it pays
+	tx.GasPrice = big.NewInt(10)
+	assert.Equal(big.NewInt(10), tx.FeeCap())
+	price, err := tx.EffectiveGasPrice(big.NewInt(4))
+	assert.NoError(err)
+	assert.Equal(big.NewInt(10), price)
+	_, err = tx.EffectiveGasPrice(big.NewInt(11))
+	assert.Error(err)
+
+	tx.GasPrice, tx.GasFeeCap = big.NewInt(2), big.NewInt(10)
+	price, err = tx.EffectiveGasPrice(big.NewInt(4))
+	assert.NoError(err)
+	assert.Equal(big.NewInt(6), price)
+	price, err = tx.EffectiveGasPrice(big.NewInt(9))
+	assert.NoError(err)
+	assert.Equal(big.NewInt(10), price)
+
+	tx.GasLimit = big.NewInt(3)
+	assert.Equal(big.NewInt(30), tx.GasCap())
+}
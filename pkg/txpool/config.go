@@ -1,9 +1,97 @@
 package txpool
 
-import "go.uber.org/zap"
+import (
+	"time"
+
+	"metechain/pkg/transaction"
+
+	"go.uber.org/zap"
+)
 
 type Config struct {
-	BlockChain IBlockchain
+	BlockChain IBlockchain `yaml:"-"`
+
+	Logger *zap.Logger `yaml:"-"`
+
+	// Broadcast sends the local transactions reloaded from the journal to
+	// the peers, if set
+	Broadcast func(*transaction.SignedTransaction) `yaml:"-"`
+
+	// Journal is the file the local transactions are kept in across restarts,
+	// none are kept if it is empty
+	Journal string `yaml:"journal"`
+
+	// Rejournal is how often the journal is compacted to the local
+	// transactions left in the pool
+	Rejournal time.Duration `yaml:"rejournal"`
+
+	// PriceBump is how much more in percent a transaction must pay to replace
+	// the one with its nonce
+	PriceBump uint64 `yaml:"pricebump"`
+
+	// PriceLimit is the least tip per gas the pool accepts while it is empty,
+	// the least rises linearly to twice as much as the pool fills
+	PriceLimit uint64 `yaml:"pricelimit"`
+
+	// PendingLimit is the most transactions Pending returns at once
+	PendingLimit int `yaml:"pendinglimit"`
+
+	// GlobalSlots is the most transactions in the pool. Once it is full a
+	// transaction only gets in by paying more than the cheapest one there,
+	// which is evicted.
+	GlobalSlots int `yaml:"globalslots"`
+
+	// AccountSlots is the most pending transactions of an account, its later
+	// ones stay queued
+	AccountSlots int `yaml:"accountslots"`
+
+	// AccountQueue is the most queued transactions of an account
+	AccountQueue int `yaml:"accountqueue"`
+
+	// IPSlots is the most transactions in the pool sent from one IP address
+	IPSlots int `yaml:"ipslots"`
+}
+
+// DefaultConfig is the configuration of a pool that leaves the limits unset
+func DefaultConfig() *Config {
+	return &Config{
+		PriceBump:    10,
+		PriceLimit:   1,
+		PendingLimit: 100,
+		GlobalSlots:  10000,
+		AccountSlots: 16,
+		AccountQueue: 64,
+		IPSlots:      1024,
+		Rejournal:    time.Hour,
+	}
+}
 
-	Logger *zap.Logger
+// withDefaults fills in the limits cfg leaves unset
+func (cfg Config) withDefaults() Config {
+	def := DefaultConfig()
+	if cfg.PriceBump == 0 {
+		cfg.PriceBump = def.PriceBump
+	}
+	if cfg.PriceLimit == 0 {
+		cfg.PriceLimit = def.PriceLimit
+	}
+	if cfg.PendingLimit <= 0 {
+		cfg.PendingLimit = def.PendingLimit
+	}
+	if cfg.GlobalSlots <= 0 {
+		cfg.GlobalSlots = def.GlobalSlots
+	}
+	if cfg.AccountSlots <= 0 {
+		cfg.AccountSlots = def.AccountSlots
+	}
+	if cfg.AccountQueue <= 0 {
+		cfg.AccountQueue = def.AccountQueue
+	}
+	if cfg.IPSlots <= 0 {
+		cfg.IPSlots = def.IPSlots
+	}
+	if cfg.Rejournal <= 0 {
+		cfg.Rejournal = def.Rejournal
+	}
+	return cfg
 }
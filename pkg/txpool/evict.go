@@ -0,0 +1,88 @@
+package txpool
+
+import (
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// EvictReason is why a transaction left the pool without being packed
+type EvictReason int
+
+const (
+	// EvictReplaced transactions were replaced by one paying more
+	EvictReplaced EvictReason = iota
+	// EvictCancelled transactions were replaced by a cancellation
+	EvictCancelled
+	// EvictExpired transactions stayed in the pool too long
+	EvictExpired
+	// EvictInvalid transactions can no longer be executed
+	EvictInvalid
+	// EvictOverflow transactions were dropped for lack of room
+	EvictOverflow
+	// EvictUnderpriced transactions made room in the full pool for one paying
+	// more
+	EvictUnderpriced
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReplaced:
+		return "replaced"
+	case EvictCancelled:
+		return "cancelled"
+	case EvictExpired:
+		return "expired"
+	case EvictInvalid:
+		return "invalid"
+	case EvictOverflow:
+		return "overflow"
+	case EvictUnderpriced:
+		return "underpriced"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionEvent is sent when a transaction is evicted from the pool
+type EvictionEvent struct {
+	Tx     transaction.SignedTransaction
+	Reason EvictReason
+	// By is the transaction that replaced Tx or took its room, if any
+	By *transaction.SignedTransaction
+}
+
+// SubscribeEvictions subscribes ch to the eviction events of the pool
+func (p *Pool) SubscribeEvictions(ch chan<- EvictionEvent) event.Subscription {
+	return p.evictFeed.Subscribe(ch)
+}
+
+// evict records the eviction of st, the pool sends its event once it is
+// unlocked
+func (p *Pool) evict(st transaction.SignedTransaction, reason EvictReason, by *transaction.SignedTransaction) {
+	p.forget(st.HashToString())
+	p.evictions = append(p.evictions, EvictionEvent{Tx: st, Reason: reason, By: by})
+}
+
+// sendEvictions sends the events of the evictions recorded, the pool must not
+// be locked
+func (p *Pool) sendEvictions() {
+	p.qlock.Lock()
+	evictions := p.evictions
+	p.evictions = nil
+	p.qlock.Unlock()
+
+	for _, ev := range evictions {
+		p.evictFeed.Send(ev)
+	}
+}
+
+// isCancel reports whether st cancels the transaction with its nonce: a
+// self-transfer of nothing
+func isCancel(st *transaction.SignedTransaction) bool {
+	switch st.Type {
+	case transaction.TransferTransaction, transaction.EvmmeteTransaction:
+		return st.To != nil && *st.To == *st.From && st.Amount.Sign() == 0
+	}
+	return false
+}
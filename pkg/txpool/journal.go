@@ -0,0 +1,140 @@
+package txpool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"metechain/pkg/transaction"
+
+	"go.uber.org/zap"
+)
+
+// maxRecord bounds the length prefix so a corrupt journal can not make load
+// allocate without limit, no transaction comes near it
+const maxRecord = 4 << 20
+
+// errNoActiveJournal is returned for an insert into a journal that is not
+// open for writing
+var errNoActiveJournal = errors.New("no active journal")
+
+// journal is the file the local transactions of the pool are kept in across
+// restarts. Every record is the length of a serialized transaction as 4 bytes
+// big endian and the transaction.
+type journal struct {
+	path   string
+	writer *os.File
+	logger *zap.Logger
+}
+
+func newJournal(path string, logger *zap.Logger) *journal {
+	return &journal{path: path, logger: logger}
+}
+
+// load reads the transactions of the journal and calls add with each of them.
+// A record torn by a crash or corrupt ends the journal, it is dropped on the
+// next rotate.
+func (j *journal) load(add func(*transaction.SignedTransaction) error) error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxRecord {
+			j.logger.Warn("drop corrupt journal records", zap.String("path", j.path), zap.Uint32("size", n))
+			return nil
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil
+		}
+		st, err := transaction.DeserializeSignaturedTransaction(data)
+		if err != nil {
+			j.logger.Warn("drop corrupt journal records", zap.String("path", j.path), zap.Error(err))
+			return nil
+		}
+		if err := add(st); err != nil {
+			return err
+		}
+	}
+}
+
+// insert appends st to the journal
+func (j *journal) insert(st *transaction.SignedTransaction) error {
+	if j.writer == nil {
+		return errNoActiveJournal
+	}
+	return writeRecord(j.writer, st)
+}
+
+// rotate replaces the journal with one of the transactions txs and opens it
+// for writing
+func (j *journal) rotate(txs []transaction.SignedTransaction) error {
+	if j.writer != nil {
+		if err := j.writer.Close(); err != nil {
+			return err
+		}
+		j.writer = nil
+	}
+
+	f, err := os.OpenFile(j.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for i := range txs {
+		if err := writeRecord(w, &txs[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(j.path+".new", j.path); err != nil {
+		return err
+	}
+
+	j.writer, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (j *journal) close() error {
+	if j.writer == nil {
+		return nil
+	}
+	err := j.writer.Close()
+	j.writer = nil
+	return err
+}
+
+func writeRecord(w io.Writer, st *transaction.SignedTransaction) error {
+	data, err := st.Serialize()
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
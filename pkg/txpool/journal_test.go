@@ -0,0 +1,101 @@
+package txpool
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestJournal(t *testing.T) {
+	assert := assert.New(t)
+	bc := newTestChain()
+	path := filepath.Join(t.TempDir(), "transactions.journal")
+	p, err := NewPool(Config{BlockChain: bc, Journal: path})
+	assert.NoError(err)
+
+	keyA, err := crypto.GenerateKey()
+	assert.NoError(err)
+	keyB, err := crypto.GenerateKey()
+	assert.NoError(err)
+	a, b := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyB.PublicKey)
+	sign := func(st *transaction.SignedTransaction, key *ecdsa.PrivateKey) *transaction.SignedTransaction {
+		sig, err := crypto.Sign(st.SignHash(), key)
+		assert.NoError(err)
+		st.Signature = sig
+		return st
+	}
+
+	locals := []*transaction.SignedTransaction{sign(newTestTx(a, 0), keyA), sign(newTestTx(a, 1), keyA), sign(newTestTx(b, 0), keyB)}
+	for _, st := range locals {
+		_, err := p.Add(st)
+		assert.NoError(err)
+	}
+	// only the local transactions are journaled
+	remote := sign(newTestTx(b, 1), keyB)
+	_, err = p.AddRemote(remote)
+	assert.NoError(err)
+	assert.NoError(p.Close())
+
+	// a replaced one is dropped from the journal once it is compacted
+	faster := newTestTx(a, 1)
+	faster.GasPrice, faster.GasFeeCap = big.NewInt(2), big.NewInt(3)
+	sign(faster, keyA)
+	var sent []string
+	broadcast := func(st *transaction.SignedTransaction) { sent = append(sent, st.HashToString()) }
+	p, err = NewPool(Config{BlockChain: bc, Journal: path, Rejournal: time.Nanosecond, Broadcast: broadcast})
+	assert.NoError(err)
+	assert.Len(sent, 3)
+	assert.Equal(3, p.q.len())
+	_, err = p.Add(faster)
+	assert.NoError(err)
+	p.FilterTransaction(nil)
+	assert.NoError(p.Close())
+
+	// the ones on chain or its payer cannot afford are not reloaded
+	bc.nonces[a] = 1
+	bc.balances[b] = new(big.Int)
+	sent = nil
+	p, err = NewPool(Config{BlockChain: bc, Journal: path, Broadcast: broadcast})
+	assert.NoError(err)
+	assert.Equal([]string{faster.HashToString()}, sent)
+	assert.Equal(1, p.q.len())
+	assert.NoError(p.Close())
+}
+
+func TestJournalCorrupt(t *testing.T) {
+	assert := assert.New(t)
+	a := common.HexToAddress("0x0a")
+	for _, tail := range [][]byte{
+		// a length no transaction comes near
+		{0xff, 0xff, 0xff, 0xff},
+		// a record that does not decode
+		{0, 0, 0, 3, 1, 2, 3},
+	} {
+		path := filepath.Join(t.TempDir(), "transactions.journal")
+		j := newJournal(path, zap.NewNop())
+		assert.NoError(j.rotate([]transaction.SignedTransaction{*newTestTx(a, 0)}))
+		assert.NoError(j.close())
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		assert.NoError(err)
+		_, err = f.Write(tail)
+		assert.NoError(err)
+		assert.NoError(f.Close())
+
+		var loaded []uint64
+		assert.NoError(j.load(func(st *transaction.SignedTransaction) error {
+			loaded = append(loaded, st.GetNonce())
+			return nil
+		}))
+		assert.Equal([]uint64{0}, loaded)
+	}
+}
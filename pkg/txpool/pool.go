@@ -1,42 +1,81 @@
 package txpool
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
 
+	"metechain/pkg/block"
 	_ "metechain/pkg/crypto/sigs/ed25519"
 	_ "metechain/pkg/crypto/sigs/secp"
 	"metechain/pkg/transaction"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 	"go.uber.org/zap"
 )
 
 const (
-	PendingLimit = 100
-	poolCap      = 10000
-	timesub      = 100
+	timesub = 100
+
+	// queuedLifetime is how long in seconds a transaction stays queued
+	queuedLifetime = 36 * timesub
 )
 
+// ErrReplaceUnderpriced is returned for a transaction with the nonce of one in
+// the pool that does not pay enough more to replace it
+var ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+
+// ErrUnderpriced is returned for a transaction paying too little tip to get in
+// the pool
+var ErrUnderpriced = errors.New("transaction underpriced")
+
 type IBlockchain interface {
 	GetNonce(*common.Address) (uint64, error)
 	GetAvailableBalance(*common.Address) (*big.Int, error)
 
 	GetBindingmeteAddress(ethAddr string) (*common.Address, error)
+	GetBaseFee() (*big.Int, error)
+	SubscribeReorg(chan<- block.ReorgEvent) event.Subscription
 }
 
 // Pool is a temporary storage pool for unchained transactions.
-// Transactions are sorted by GasCap and Nonce in the pool and waiting to be
-// uploaded to the chain.
+// Transactions are sorted by the tip they pay on top of the base fee of the
+// next block and Nonce in the pool and waiting to be uploaded to the chain.
+// Only the executable transactions are pending in q, the ones with a nonce
+// ahead of the next one of their caller are queued until the gap fills.
+// Once the pool is full a transaction gets in by evicting the one paying the
+// least tip, if it pays more. The local transactions are kept in a journal,
+// a restarted pool reloads them.
 type Pool struct {
-	qlock sync.Mutex
-	q     *orderlyQueue
+	qlock  sync.Mutex
+	q      *orderlyQueue
+	queued *futureQueue
 
 	bc         IBlockchain
 	logger     *zap.Logger
 	pendingBuf []transaction.SignedTransaction
+	cfg        Config
+
+	// origins maps the hashes of the transactions sent from an IP address to
+	// it, ipSlots counts them by address
+	origins map[string]string
+	ipSlots map[string]int
+
+	// locals are the hashes of the transactions submitted to this node, the
+	// journal keeps them
+	locals      map[string]struct{}
+	journal     *journal
+	rejournaled time.Time
+
+	reorgs   chan block.ReorgEvent
+	reorgSub event.Subscription
+
+	evictFeed event.Feed
+	evictions []EvictionEvent
 }
 
 // NewPool Create transaction pool
@@ -45,10 +84,17 @@ func NewPool(cfg Config) (*Pool, error) {
 		return nil, fmt.Errorf("bc cannot be empty")
 	}
 
+	cfg = cfg.withDefaults()
 	p := &Pool{
 		bc:         cfg.BlockChain,
 		q:          newQueue(),
-		pendingBuf: make([]transaction.SignedTransaction, PendingLimit, PendingLimit),
+		queued:     newFutureQueue(cfg.AccountQueue),
+		pendingBuf: make([]transaction.SignedTransaction, cfg.PendingLimit, cfg.PendingLimit),
+		cfg:        cfg,
+		origins:    make(map[string]string),
+		ipSlots:    make(map[string]int),
+		locals:     make(map[string]struct{}),
+		reorgs:     make(chan block.ReorgEvent, reorgChanSize),
 	}
 
 	if cfg.Logger != nil {
@@ -57,15 +103,140 @@ func NewPool(cfg Config) (*Pool, error) {
 		p.logger = zap.NewNop()
 	}
 
+	if cfg.Journal != "" {
+		p.journal = newJournal(cfg.Journal, p.logger)
+		survivors, err := p.reload()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Broadcast != nil {
+			for i := range survivors {
+				cfg.Broadcast(&survivors[i])
+			}
+		}
+	}
+
+	p.reorgSub = cfg.BlockChain.SubscribeReorg(p.reorgs)
+	go p.loop()
+
 	return p, nil
 }
 
-// Add to add a new transaction to the pool, outdated transactions
-// will return an error
-func (p *Pool) Add(st *transaction.SignedTransaction) error {
+// reload adds the transactions of the journal that can still be executed as
+// local ones and compacts the journal to them, it returns them
+func (p *Pool) reload() ([]transaction.SignedTransaction, error) {
+	var survivors []transaction.SignedTransaction
+	spent := make(map[common.Address]*big.Int)
+	err := p.journal.load(func(st *transaction.SignedTransaction) error {
+		if err := p.validate(st, spent); err != nil {
+			p.logger.Debug("drop journaled tx", zap.String("transaction", st.String()), zap.Error(err))
+			return nil
+		}
+		if _, err := p.add(st, ""); err != nil {
+			p.logger.Debug("drop journaled tx", zap.String("transaction", st.String()), zap.Error(err))
+			return nil
+		}
+		p.locals[st.HashToString()] = struct{}{}
+		survivors = append(survivors, *st)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.logger.Info("reload journal", zap.String("path", p.journal.path), zap.Int("transactions", len(survivors)))
+	return survivors, p.rejournal()
+}
+
+// validate checks that st is ahead of the nonce of its caller and that its
+// payer can afford it on top of what spent says it pays for the transactions
+// validated before
+func (p *Pool) validate(st *transaction.SignedTransaction, spent map[common.Address]*big.Int) error {
+	if err := st.VerifySign(); err != nil {
+		return err
+	}
+	if err := p.geCallerNonce(st.Caller(), st.GetNonce()); err != nil {
+		return err
+	}
+
+	payer := st.Caller()
+	if st.Type == transaction.WithdrawToEthTransaction {
+		kaddr, err := p.bc.GetBindingmeteAddress(payer.String())
+		if err != nil {
+			return err
+		}
+		payer = kaddr
+	}
+	balance, err := p.bc.GetAvailableBalance(payer)
+	if err != nil {
+		return err
+	}
+	cost := new(big.Int).Add(st.Amount, st.GasCap())
+	if s, ok := spent[*payer]; ok {
+		cost.Add(cost, s)
+	}
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("%s has %v, its transactions cost %v", payer, balance, cost)
+	}
+	spent[*payer] = cost
+	return nil
+}
+
+// rejournal compacts the journal to the local transactions in the pool
+func (p *Pool) rejournal() error {
+	var locals []transaction.SignedTransaction
+	for _, st := range append(append([]transaction.SignedTransaction{}, p.q.stList...), p.queued.list()...) {
+		if _, ok := p.locals[st.HashToString()]; ok {
+			locals = append(locals, st)
+		}
+	}
+	// they are reloaded in the order of their nonces
+	sort.Slice(locals, func(i, j int) bool {
+		if ci, cj := locals[i].Caller().String(), locals[j].Caller().String(); ci != cj {
+			return ci < cj
+		}
+		return locals[i].GetNonce() < locals[j].GetNonce()
+	})
+	p.rejournaled = time.Now()
+	return p.journal.rotate(locals)
+}
+
+// Close stops following the reorganizations of the chain and closes the
+// journal of the pool
+func (p *Pool) Close() error {
+	p.reorgSub.Unsubscribe()
+
+	p.qlock.Lock()
+	defer p.qlock.Unlock()
+
+	if p.journal == nil {
+		return nil
+	}
+	return p.journal.close()
+}
+
+// Add to add a new local transaction to the pool, outdated transactions
+// will return an error. It reports whether the transaction replaced one with
+// its nonce.
+func (p *Pool) Add(st *transaction.SignedTransaction) (bool, error) {
+	return p.AddFromIP(st, "")
+}
+
+// AddFromIP adds a local transaction sent from the IP address ip, which is
+// held to the limit of transactions per address. An empty ip is not limited.
+func (p *Pool) AddFromIP(st *transaction.SignedTransaction, ip string) (bool, error) {
+	return p.addSigned(st, ip, true)
+}
+
+// AddRemote adds a transaction received from a peer, unlike a local one it
+// is not journaled
+func (p *Pool) AddRemote(st *transaction.SignedTransaction) (bool, error) {
+	return p.addSigned(st, "", false)
+}
+
+func (p *Pool) addSigned(st *transaction.SignedTransaction, ip string, local bool) (bool, error) {
 	if st.Type == transaction.TransferTransaction {
 		if len(st.Input) > 0 {
-			return fmt.Errorf("Unsupported Token transaction currently,input: %v", string(st.Input))
+			return false, fmt.Errorf("Unsupported Token transaction currently,input: %v", string(st.Input))
 		}
 	}
 	// if st.GasLimit*st.GasPrice < blockchain.MINGASLIMIT || st.GasLimit*st.GasPrice > blockchain.MAXGASLIMIT {
@@ -73,17 +244,32 @@ func (p *Pool) Add(st *transaction.SignedTransaction) error {
 	// }
 
 	if err := st.VerifySign(); err != nil {
-		return err
+		return false, err
 	}
 
+	defer p.sendEvictions()
 	p.qlock.Lock()
 	defer p.qlock.Unlock()
-	p.logger.Debug("add tx", zap.String("transaction", st.String()))
+	p.logger.Debug("add tx", zap.String("transaction", st.String()), zap.String("ip", ip), zap.Bool("local", local))
 
-	return p.add(st)
+	replaced, err := p.add(st, ip)
+	if err != nil || !local {
+		return replaced, err
+	}
+	if _, ok := p.locals[st.HashToString()]; ok {
+		return replaced, nil
+	}
+	p.locals[st.HashToString()] = struct{}{}
+	if p.journal != nil {
+		if err := p.journal.insert(st); err != nil {
+			p.logger.Warn("journal tx", zap.String("transaction", st.String()), zap.Error(err))
+		}
+	}
+	return replaced, nil
 }
 
 func (p *Pool) AddList(stList []transaction.SignedTransaction) []error {
+	defer p.sendEvictions()
 	p.qlock.Lock()
 	defer p.qlock.Unlock()
 
@@ -95,7 +281,7 @@ func (p *Pool) AddList(stList []transaction.SignedTransaction) []error {
 			continue
 		}
 
-		if err := p.add(&stList[i]); err != nil {
+		if _, err := p.add(&stList[i], ""); err != nil {
 			err = fmt.Errorf("transaction:%s,error:%v", stList[i].String(), err)
 			eList = append(eList, err)
 		}
@@ -104,21 +290,237 @@ func (p *Pool) AddList(stList []transaction.SignedTransaction) []error {
 	return eList
 }
 
-func (p *Pool) add(st *transaction.SignedTransaction) error {
-	if p.q.len() >= poolCap {
-		return fmt.Errorf("pool is full,please try again later")
-	}
-
+func (p *Pool) add(st *transaction.SignedTransaction, ip string) (bool, error) {
 	// Check if the nonce of the transaction is required
 	if err := p.geCallerNonce(st.Caller(), st.GetNonce()); err != nil {
-		return err
+		return false, err
+	}
+	if tip, min := st.EffectiveTip(p.q.baseFee), p.minTip(); tip.Cmp(min) < 0 {
+		return false, fmt.Errorf("%w: tip %v, the pool takes %v at least", ErrUnderpriced, tip, min)
+	}
+
+	caller := st.Caller()
+	if idx, ok := p.findSignedTransactionIdx(*caller, st.GetNonce()); ok {
+		old := p.q.stList[idx]
+		if old.HashToString() == st.HashToString() {
+			return false, nil
+		}
+		if !p.replaces(&old, st) {
+			return false, ErrReplaceUnderpriced
+		}
+		p.q.push(*st)
+		p.evictReplaced(old, st)
+		p.track(st, ip)
+		return true, nil
+	}
+	if old, ok := p.queued.get(caller.String(), st.GetNonce()); ok {
+		old := *old
+		if old.HashToString() == st.HashToString() {
+			return false, nil
+		}
+		if !p.replaces(&old, st) {
+			return false, ErrReplaceUnderpriced
+		}
+		if err := p.queued.add(*st, time.Now().Unix()); err != nil {
+			return false, err
+		}
+		p.evictReplaced(old, st)
+		p.track(st, ip)
+		return true, nil
+	}
+
+	next, err := p.pendingNonce(caller)
+	if err != nil {
+		return false, err
+	}
+	pending := st.GetNonce() == next && len(p.q.rstBuffer[caller.String()]) < p.cfg.AccountSlots
+	if n := len(p.queued.accounts[caller.String()]); !pending && n >= p.cfg.AccountQueue {
+		return false, fmt.Errorf("%s has %d queued transactions already", caller, n)
+	}
+	if ip != "" && p.ipSlots[ip] >= p.cfg.IPSlots {
+		return false, fmt.Errorf("%s has %d transactions in the pool already", ip, p.ipSlots[ip])
+	}
+	if p.q.len()+p.queued.len() >= p.cfg.GlobalSlots {
+		if err := p.makeRoom(st); err != nil {
+			return false, err
+		}
+	}
+
+	if pending {
+		p.q.push(*st)
+		p.track(st, ip)
+		p.promote(caller)
+		return false, nil
+	}
+
+	p.logger.Debug("queue tx", zap.String("transaction", st.String()), zap.Uint64("next nonce", next))
+	if err := p.queued.add(*st, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	p.track(st, ip)
+	return false, nil
+}
+
+// minTip is the least tip per gas the pool takes, the price limit while it is
+// empty rising linearly to twice that once it is full
+func (p *Pool) minTip() *big.Int {
+	slots := big.NewInt(int64(p.cfg.GlobalSlots))
+	min := new(big.Int).SetUint64(p.cfg.PriceLimit)
+	min.Mul(min, new(big.Int).Add(slots, big.NewInt(int64(p.q.len()+p.queued.len()))))
+	return min.Div(min, slots)
+}
+
+// makeRoom evicts the transaction of another caller paying the least tip from
+// the full pool for st, if st pays more. Only the last transaction of a caller
+// is evicted, so that no nonce gap is left.
+func (p *Pool) makeRoom(st *transaction.SignedTransaction) error {
+	caller := st.Caller()
+	cheapest, _ := p.q.cheapest(caller.String())
+	queued, ok := p.queued.cheapest(p.q.baseFee, caller.String())
+	if ok && (cheapest == nil || queued.EffectiveTip(p.q.baseFee).Cmp(cheapest.EffectiveTip(p.q.baseFee)) < 0) {
+		cheapest = queued
+	}
+	if cheapest == nil {
+		return fmt.Errorf("pool is full,please try again later")
+	}
+	if st.EffectiveTip(p.q.baseFee).Cmp(cheapest.EffectiveTip(p.q.baseFee)) <= 0 {
+		return fmt.Errorf("%w: pool is full, tip %v does not beat the least one %v", ErrUnderpriced,
+			st.EffectiveTip(p.q.baseFee), cheapest.EffectiveTip(p.q.baseFee))
 	}
 
-	p.q.push(*st)
-	//p.logger.Debug("success add", zap.String("transaction", st.String()))
+	victim := *cheapest
+	p.logger.Debug("evict underpriced tx", zap.String("transaction", victim.String()), zap.String("by", st.HashToString()))
+	p.evict(victim, EvictUnderpriced, st)
+	if ok && cheapest == queued {
+		p.queued.remove(victim.Caller().String(), victim.GetNonce())
+		return nil
+	}
+	if idx, ok := p.findSignedTransactionIdx(*victim.Caller(), victim.GetNonce()); ok {
+		p.q.remove(idx)
+	}
 	return nil
 }
 
+// track counts st against the IP address it was sent from
+func (p *Pool) track(st *transaction.SignedTransaction, ip string) {
+	if ip == "" {
+		return
+	}
+	p.origins[st.HashToString()] = ip
+	p.ipSlots[ip]++
+}
+
+// forget stops counting the transaction with the hash against its IP address
+// and as a local one
+func (p *Pool) forget(hash string) {
+	delete(p.locals, hash)
+	ip, ok := p.origins[hash]
+	if !ok {
+		return
+	}
+	delete(p.origins, hash)
+	if p.ipSlots[ip]--; p.ipSlots[ip] <= 0 {
+		delete(p.ipSlots, ip)
+	}
+}
+
+// replaces reports whether st pays enough to replace old, which has its nonce:
+// a higher tip and fee cap, both by the price bump at least
+func (p *Pool) replaces(old, st *transaction.SignedTransaction) bool {
+	for _, prices := range [][2]*big.Int{{old.GasPrice, st.GasPrice}, {old.FeeCap(), st.FeeCap()}} {
+		threshold := new(big.Int).Mul(prices[0], new(big.Int).SetUint64(100+p.cfg.PriceBump))
+		threshold.Div(threshold, big.NewInt(100))
+		if prices[1].Cmp(prices[0]) <= 0 || prices[1].Cmp(threshold) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pool) evictReplaced(old transaction.SignedTransaction, st *transaction.SignedTransaction) {
+	reason := EvictReplaced
+	if isCancel(st) {
+		reason = EvictCancelled
+	}
+	p.logger.Debug("replace tx", zap.String("transaction", old.String()), zap.String("by", st.HashToString()), zap.Stringer("reason", reason))
+	p.evict(old, reason, st)
+}
+
+// pendingNonce returns the nonce that follows on the pending transactions of
+// the caller
+func (p *Pool) pendingNonce(caller *common.Address) (uint64, error) {
+	next, err := p.bc.GetNonce(caller)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, err := p.q.getIndex(caller.String(), next); err != nil {
+			return next, nil
+		}
+		next++
+	}
+}
+
+// promote moves the queued transactions of the caller that follow on its
+// pending ones to pending while it has slots left
+func (p *Pool) promote(caller *common.Address) {
+	next, err := p.pendingNonce(caller)
+	if err != nil {
+		p.logger.Error("promote", zap.String("address", caller.String()), zap.Error(err))
+		return
+	}
+	// the queued transactions below are on chain or pending already
+	for _, st := range p.queued.forward(caller.String(), next) {
+		p.evict(st, EvictInvalid, nil)
+	}
+	for len(p.q.rstBuffer[caller.String()]) < p.cfg.AccountSlots {
+		st, ok := p.queued.pop(caller.String(), next)
+		if !ok {
+			return
+		}
+		p.q.push(st)
+		next++
+	}
+}
+
+// promoteAll promotes the queued transactions of every caller
+func (p *Pool) promoteAll() {
+	for caller := range p.queued.accounts {
+		addr := common.HexToAddress(caller)
+		p.promote(&addr)
+	}
+}
+
+// demote moves the pending transactions of the caller after a nonce gap back
+// to the queued ones
+func (p *Pool) demote(caller *common.Address) {
+	next, err := p.pendingNonce(caller)
+	if err != nil {
+		p.logger.Error("demote", zap.String("address", caller.String()), zap.Error(err))
+		return
+	}
+
+	var nonces []uint64
+	for _, rst := range p.q.rstBuffer[caller.String()] {
+		if rst.nonce > next {
+			nonces = append(nonces, rst.nonce)
+		}
+	}
+	now := time.Now().Unix()
+	for _, nonce := range nonces {
+		idx, ok := p.findSignedTransactionIdx(*caller, nonce)
+		if !ok {
+			continue
+		}
+		st := p.q.stList[idx]
+		p.q.remove(idx)
+		if err := p.queued.add(st, now); err != nil {
+			p.logger.Debug("drop tx", zap.String("transaction", st.String()), zap.Error(err))
+			p.evict(st, EvictOverflow, nil)
+		}
+	}
+}
+
 func (p *Pool) geCallerNonce(caller *common.Address, nonce uint64) error {
 	lastNonce, _ := p.bc.GetNonce(caller)
 	if nonce < lastNonce {
@@ -145,16 +547,23 @@ func (t *traderInfo) String() string {
 
 // Pending returns the transaction that can be packaged
 func (p *Pool) Pending() ([]transaction.SignedTransaction, error) {
+	defer p.sendEvictions()
 	p.qlock.Lock()
 	defer p.qlock.Unlock()
 
+	if baseFee, err := p.bc.GetBaseFee(); err != nil {
+		p.logger.Error("get base fee", zap.Error(err))
+	} else {
+		p.q = p.q.reorder(baseFee)
+	}
+
 	traderBuffer := make(map[string]traderInfo)
 	//unreadyBuffer := make([]transaction.SignedTransaction, 0)
 	i, l := 0, p.q.len()
 
 	var badTxIdxs []int
 	a := 0
-	for ; a < PendingLimit && i < l; i++ {
+	for ; a < p.cfg.PendingLimit && i < l; i++ {
 		idx := l - 1 - i
 		st := p.q.stList[idx]
 		if n := p.compareNonce(traderBuffer, st.Caller(), st.GetNonce()); n < 0 {
@@ -189,9 +598,17 @@ func (p *Pool) Pending() ([]transaction.SignedTransaction, error) {
 		p.logger.Debug("success pending", zap.String("transaction", st.Transaction.String()))
 	}
 
+	callers := make(map[common.Address]struct{})
 	for _, idx := range badTxIdxs {
+		callers[*p.q.stList[idx].Caller()] = struct{}{}
+		p.evict(p.q.stList[idx], EvictInvalid, nil)
 		p.q.remove(idx)
 	}
+	// the later transactions of the callers cannot be executed before the gap
+	// the bad ones left fills
+	for caller := range callers {
+		p.demote(&caller)
+	}
 
 	return p.pendingBuf[:a], nil
 }
@@ -222,10 +639,10 @@ func (p *Pool) transferTransactionPreCalculated(traderBuf map[string]traderInfo,
 		return err
 	}
 
-	amountAndGas := new(big.Int).Add(tx.Amount, tx.GasFeeCap)
+	amountAndGas := new(big.Int).Add(tx.Amount, tx.GasCap())
 
 	if caller.availableBalance.Cmp(amountAndGas) == -1 {
-		return fmt.Errorf(err.Error()+":caller avalible balance(%s) - amount and gas(%s)",
+		return fmt.Errorf("caller avalible balance(%s) - amount and gas(%s)",
 			caller.availableBalance, amountAndGas)
 	}
 
@@ -251,7 +668,7 @@ func (p *Pool) pledgeTrasnactionPreCalculated(traderBuf map[string]traderInfo, t
 	avlBalance := new(big.Int).Sub(caller.availableBalance, tx.Amount)
 
 	if avlBalance.Cmp(tx.GasCap()) == -1 {
-		return fmt.Errorf(err.Error()+": availbale balance(%s) - gas fee(%s)", avlBalance, tx.GasCap())
+		return fmt.Errorf("availbale balance(%s) - gas fee(%s)", avlBalance, tx.GasCap())
 	}
 
 	avlBalance = avlBalance.Sub(avlBalance, tx.GasCap())
@@ -310,11 +727,27 @@ func (p *Pool) compareNonce(traderBuf map[string]traderInfo, trader *common.Addr
 	return 0
 }
 
-// FilterTransaction filter incoming transactions
+// FilterTransaction filter incoming transactions, the queued transactions
+// that follow on them are promoted
 func (p *Pool) FilterTransaction(stList []transaction.SignedTransaction) {
+	defer p.sendEvictions()
 	p.qlock.Lock()
 	defer p.qlock.Unlock()
 
+	p.removeIncluded(stList)
+	p.cacheOutSignedTransaction()
+	p.promoteAll()
+
+	if p.journal != nil && time.Since(p.rejournaled) >= p.cfg.Rejournal {
+		if err := p.rejournal(); err != nil {
+			p.logger.Error("rejournal", zap.Error(err))
+		}
+	}
+}
+
+// removeIncluded removes the pending transactions with the caller and nonce of
+// one of stList, which are on chain
+func (p *Pool) removeIncluded(stList []transaction.SignedTransaction) {
 	for _, st := range stList {
 		rstList, ok := p.q.rstBuffer[st.Caller().String()]
 		if !ok {
@@ -323,12 +756,11 @@ func (p *Pool) FilterTransaction(stList []transaction.SignedTransaction) {
 
 		for _, rst := range rstList {
 			if st.GetNonce() == rst.nonce {
+				p.forget(p.q.stList[rst.idx].HashToString())
 				p.q.remove(rst.idx)
 			}
 		}
 	}
-
-	p.cacheOutSignedTransaction()
 }
 
 // TransctionCacheOut  Eliminate transactions in the transaction pool
@@ -358,12 +790,19 @@ func (p *Pool) cacheOutSignedTransaction() error {
 			continue
 		}
 
+		p.evict(p.q.stList[idx], EvictExpired, nil)
 		p.q.remove(idx)
 		p.logger.Debug("remove tx", zap.String("addr", addr.String()), zap.Uint64("nonce", nonce))
 	}
 
-	for p.q.len() > poolCap/3*2 {
+	for _, st := range p.queued.expire(time.Now().Unix() - queuedLifetime) {
+		p.logger.Debug("remove queued tx", zap.String("addr", st.Caller().String()), zap.Uint64("nonce", st.GetNonce()))
+		p.evict(st, EvictExpired, nil)
+	}
+
+	for p.q.len() > p.cfg.GlobalSlots/3*2 {
 		p.logger.Debug("remove tx", zap.String("addr", p.q.stList[0].Caller().String()), zap.Uint64("nonce", p.q.stList[0].GetNonce()))
+		p.evict(p.q.stList[0], EvictOverflow, nil)
 		p.q.remove(0)
 	}
 
@@ -394,7 +833,7 @@ func (p *Pool) FindSignedTransaction(addr *common.Address, nonce uint64) (*trans
 
 	list, ok := p.q.rstBuffer[addr.String()]
 	if !ok {
-		return nil, false
+		return p.queued.get(addr.String(), nonce)
 	}
 
 	for _, rst := range list {
@@ -407,7 +846,7 @@ func (p *Pool) FindSignedTransaction(addr *common.Address, nonce uint64) (*trans
 		}
 	}
 
-	return nil, false
+	return p.queued.get(addr.String(), nonce)
 }
 
 func (p *Pool) CopySignedTransactions() []transaction.SignedTransaction {
@@ -417,7 +856,7 @@ func (p *Pool) CopySignedTransactions() []transaction.SignedTransaction {
 	stc := make([]transaction.SignedTransaction, p.q.len())
 
 	copy(stc, p.q.stList)
-	return stc
+	return append(stc, p.queued.list()...)
 }
 
 func (p *Pool) GetTxByHash(hash string) (*transaction.SignedTransaction, error) {
@@ -426,6 +865,9 @@ func (p *Pool) GetTxByHash(hash string) (*transaction.SignedTransaction, error)
 
 	addrStr, nonce, err := p.q.getAddrAndNonceByHash(hash)
 	if err != nil {
+		if st, ok := p.queued.getByHash(hash); ok {
+			return st, nil
+		}
 		return nil, err
 	}
 	// addr, err := address.NewAddrFromString(addrStr)
@@ -484,7 +926,7 @@ func (p *Pool) withdrawTrasnactionPreCalculated(traderBuf map[string]traderInfo,
 	amountAndGas := new(big.Int).Add(tx.Amount, tx.GasCap())
 
 	if caller.availableBalance.Cmp(amountAndGas) == -1 {
-		return fmt.Errorf(err.Error()+":caller avalible balance(%s) - amount and gas(%s)",
+		return fmt.Errorf("caller avalible balance(%s) - amount and gas(%s)",
 			caller.availableBalance, amountAndGas)
 	}
 
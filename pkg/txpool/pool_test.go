@@ -0,0 +1,345 @@
+package txpool
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/logger"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "txpool")
+	if err != nil {
+		panic(err)
+	}
+	cfg := logger.DefaultConfig()
+	cfg.FileName = filepath.Join(dir, "debug.log")
+	if err := logger.InitLogger(cfg); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testChain is the state the pool reads its transactions against
+type testChain struct {
+	nonces    map[common.Address]uint64
+	balances  map[common.Address]*big.Int
+	reorgFeed event.Feed
+}
+
+func newTestChain() *testChain {
+	return &testChain{nonces: make(map[common.Address]uint64), balances: make(map[common.Address]*big.Int)}
+}
+
+func (c *testChain) GetNonce(addr *common.Address) (uint64, error) {
+	return c.nonces[*addr], nil
+}
+
+func (c *testChain) GetAvailableBalance(addr *common.Address) (*big.Int, error) {
+	if b, ok := c.balances[*addr]; ok {
+		return new(big.Int).Set(b), nil
+	}
+	return big.NewInt(1e18), nil
+}
+
+func (c *testChain) GetBindingmeteAddress(ethAddr string) (*common.Address, error) {
+	return nil, fmt.Errorf("%s is not bound", ethAddr)
+}
+
+func (c *testChain) GetBaseFee() (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (c *testChain) SubscribeReorg(ch chan<- block.ReorgEvent) event.Subscription {
+	return c.reorgFeed.Subscribe(ch)
+}
+
+func newTestTx(from common.Address, nonce uint64) *transaction.SignedTransaction {
+	to := common.HexToAddress("0xff")
+	return &transaction.SignedTransaction{Transaction: transaction.Transaction{
+		From: &from, To: &to, Nonce: nonce, Amount: big.NewInt(1), Type: transaction.TransferTransaction,
+		GasLimit: big.NewInt(21000), GasPrice: big.NewInt(1), GasFeeCap: big.NewInt(2),
+	}}
+}
+
+func addTx(p *Pool, st *transaction.SignedTransaction) error {
+	_, err := p.add(st, "")
+	return err
+}
+
+func TestQueued(t *testing.T) {
+	assert := assert.New(t)
+	bc := newTestChain()
+	p, err := NewPool(Config{BlockChain: bc})
+	assert.NoError(err)
+	a := common.HexToAddress("0x0a")
+
+	// a nonce gap queues the transaction until it fills
+	assert.NoError(addTx(p, newTestTx(a, 2)))
+	assert.Equal(0, p.q.len())
+	assert.Equal(1, p.queued.len())
+	_, ok := p.FindSignedTransaction(&a, 2)
+	assert.True(ok)
+	_, err = p.GetTxByHash(newTestTx(a, 2).HashToString())
+	assert.NoError(err)
+	assert.Len(p.CopySignedTransactions(), 1)
+
+	assert.NoError(addTx(p, newTestTx(a, 0)))
+	assert.Equal(1, p.q.len())
+	assert.NoError(addTx(p, newTestTx(a, 1)))
+	assert.Equal(3, p.q.len())
+	assert.Equal(0, p.queued.len())
+
+	// the gap fills once the pending ones are on chain
+	assert.NoError(addTx(p, newTestTx(a, 5)))
+	pending, err := p.Pending()
+	assert.NoError(err)
+	assert.Len(pending, 3)
+	bc.nonces[a] = 3
+	p.FilterTransaction([]transaction.SignedTransaction{*newTestTx(a, 0), *newTestTx(a, 1), *newTestTx(a, 2)})
+	assert.Equal(0, p.q.len())
+	assert.Equal(1, p.queued.len())
+	assert.NoError(addTx(p, newTestTx(a, 4)))
+	assert.Equal(0, p.q.len())
+	assert.NoError(addTx(p, newTestTx(a, 3)))
+	assert.Equal(3, p.q.len())
+	assert.Equal(0, p.queued.len())
+
+	// below the chain nonce
+	assert.Error(addTx(p, newTestTx(a, 2)))
+}
+
+func TestAccountLimits(t *testing.T) {
+	assert := assert.New(t)
+	p, err := NewPool(Config{BlockChain: newTestChain()})
+	assert.NoError(err)
+	a := common.HexToAddress("0x0a")
+
+	slots, queue := p.cfg.AccountSlots, p.cfg.AccountQueue
+	for n := 0; n < slots+queue; n++ {
+		assert.NoError(addTx(p, newTestTx(a, uint64(n))))
+	}
+	assert.Equal(slots, p.q.len())
+	assert.Equal(queue, p.queued.len())
+	assert.Error(addTx(p, newTestTx(a, uint64(slots+queue))))
+
+	// stale queued transactions are dropped
+	for _, qt := range p.queued.accounts[a.String()] {
+		assert.NotZero(qt.timestamp)
+	}
+	for i := range p.queued.accounts[a.String()] {
+		p.queued.accounts[a.String()][i].timestamp -= queuedLifetime + 1
+	}
+	p.FilterTransaction(nil)
+	assert.Equal(0, p.queued.len())
+	assert.Equal(slots, p.q.len())
+}
+
+func TestDemote(t *testing.T) {
+	assert := assert.New(t)
+	bc := newTestChain()
+	p, err := NewPool(Config{BlockChain: bc})
+	assert.NoError(err)
+	a := common.HexToAddress("0x0a")
+
+	for n := uint64(0); n < 3; n++ {
+		assert.NoError(addTx(p, newTestTx(a, n)))
+	}
+	assert.Equal(3, p.q.len())
+
+	// the first transaction cannot pay, the later ones wait for its nonce
+	bc.balances[a] = new(big.Int)
+	pending, err := p.Pending()
+	assert.NoError(err)
+	assert.Empty(pending)
+	assert.Equal(0, p.q.len())
+	assert.Equal(2, p.queued.len())
+
+	delete(bc.balances, a)
+	assert.NoError(addTx(p, newTestTx(a, 0)))
+	assert.Equal(3, p.q.len())
+	pending, err = p.Pending()
+	assert.NoError(err)
+	assert.Len(pending, 3)
+}
+
+func TestReplace(t *testing.T) {
+	assert := assert.New(t)
+	p, err := NewPool(Config{BlockChain: newTestChain()})
+	assert.NoError(err)
+	evictions := make(chan EvictionEvent, 4)
+	sub := p.SubscribeEvictions(evictions)
+	defer sub.Unsubscribe()
+	a := common.HexToAddress("0x0a")
+
+	st := newTestTx(a, 0)
+	replaced, err := p.add(st, "")
+	assert.NoError(err)
+	assert.False(replaced)
+
+	// the same transaction again is no replacement
+	replaced, err = p.add(st, "")
+	assert.NoError(err)
+	assert.False(replaced)
+
+	// both the tip and the fee cap must go up by the price bump
+	faster := newTestTx(a, 0)
+	faster.GasPrice, faster.GasFeeCap = big.NewInt(2), big.NewInt(2)
+	_, err = p.add(faster, "")
+	assert.Equal(ErrReplaceUnderpriced, err)
+	faster.GasFeeCap = big.NewInt(3)
+	replaced, err = p.add(faster, "")
+	assert.NoError(err)
+	assert.True(replaced)
+	found, ok := p.FindSignedTransaction(&a, 0)
+	assert.True(ok)
+	assert.Equal(faster.HashToString(), found.HashToString())
+	assert.Equal(1, p.q.len())
+
+	// a self-transfer of nothing cancels it
+	cancel := newTestTx(a, 0)
+	cancel.To, cancel.Amount = &a, new(big.Int)
+	cancel.GasPrice, cancel.GasFeeCap = big.NewInt(3), big.NewInt(4)
+	replaced, err = p.add(cancel, "")
+	assert.NoError(err)
+	assert.True(replaced)
+
+	// queued transactions are replaced the same way
+	queued := newTestTx(a, 2)
+	assert.NoError(addTx(p, queued))
+	queuedFaster := newTestTx(a, 2)
+	queuedFaster.GasPrice, queuedFaster.GasFeeCap = big.NewInt(10), big.NewInt(10)
+	replaced, err = p.add(queuedFaster, "")
+	assert.NoError(err)
+	assert.True(replaced)
+	assert.Equal(1, p.queued.len())
+
+	p.sendEvictions()
+	for _, want := range []EvictionEvent{
+		{Tx: *st, Reason: EvictReplaced, By: faster},
+		{Tx: *faster, Reason: EvictCancelled, By: cancel},
+		{Tx: *queued, Reason: EvictReplaced, By: queuedFaster},
+	} {
+		ev := <-evictions
+		assert.Equal(want.Reason, ev.Reason)
+		assert.Equal(want.Tx.HashToString(), ev.Tx.HashToString())
+		assert.Equal(want.By.HashToString(), ev.By.HashToString())
+	}
+}
+
+func TestPoolFull(t *testing.T) {
+	assert := assert.New(t)
+	p, err := NewPool(Config{BlockChain: newTestChain(), GlobalSlots: 4})
+	assert.NoError(err)
+	evictions := make(chan EvictionEvent, 4)
+	sub := p.SubscribeEvictions(evictions)
+	defer sub.Unsubscribe()
+
+	withTip := func(st *transaction.SignedTransaction, tip int64) *transaction.SignedTransaction {
+		st.GasPrice, st.GasFeeCap = big.NewInt(tip), big.NewInt(tip+1)
+		return st
+	}
+	for i := int64(1); i <= 3; i++ {
+		assert.NoError(addTx(p, withTip(newTestTx(common.BigToAddress(big.NewInt(i)), 0), i+1)))
+	}
+	b := common.HexToAddress("0x0b")
+	cheap := withTip(newTestTx(b, 1), 1)
+	assert.NoError(addTx(p, cheap))
+	assert.Equal(3, p.q.len())
+	assert.Equal(1, p.queued.len())
+
+	// the least tip rises as the pool fills
+	assert.Equal(big.NewInt(2), p.minTip())
+	a := common.HexToAddress("0x0a")
+	assert.ErrorIs(addTx(p, newTestTx(a, 0)), ErrUnderpriced)
+
+	// a transaction takes the room of the cheapest one
+	st := withTip(newTestTx(a, 0), 2)
+	assert.NoError(addTx(p, st))
+	assert.Equal(4, p.q.len())
+	assert.Equal(0, p.queued.len())
+	p.sendEvictions()
+	ev := <-evictions
+	assert.Equal(EvictUnderpriced, ev.Reason)
+	assert.Equal(cheap.HashToString(), ev.Tx.HashToString())
+	assert.Equal(st.HashToString(), ev.By.HashToString())
+
+	// if it pays more
+	c := common.HexToAddress("0x0c")
+	assert.ErrorIs(addTx(p, withTip(newTestTx(c, 0), 2)), ErrUnderpriced)
+	assert.NoError(addTx(p, withTip(newTestTx(c, 0), 3)))
+	assert.Equal(4, p.q.len())
+	p.sendEvictions()
+	ev = <-evictions
+	assert.Equal(EvictUnderpriced, ev.Reason)
+	assert.Equal(int64(2), ev.Tx.GasPrice.Int64())
+}
+
+func TestPoolFullPending(t *testing.T) {
+	assert := assert.New(t)
+	p, err := NewPool(Config{BlockChain: newTestChain(), GlobalSlots: 4})
+	assert.NoError(err)
+	evictions := make(chan EvictionEvent, 4)
+	sub := p.SubscribeEvictions(evictions)
+	defer sub.Unsubscribe()
+
+	withTip := func(st *transaction.SignedTransaction, tip int64) *transaction.SignedTransaction {
+		st.GasPrice, st.GasFeeCap = big.NewInt(tip), big.NewInt(tip+1)
+		return st
+	}
+	b, d, e := common.HexToAddress("0x0b"), common.HexToAddress("0x0d"), common.HexToAddress("0x0e")
+	assert.NoError(addTx(p, withTip(newTestTx(b, 0), 1)))
+	assert.NoError(addTx(p, withTip(newTestTx(b, 1), 5)))
+	cheapest := withTip(newTestTx(d, 0), 3)
+	assert.NoError(addTx(p, cheapest))
+	assert.NoError(addTx(p, withTip(newTestTx(e, 0), 4)))
+	assert.Equal(4, p.q.len())
+
+	// the first transaction of b pays the least, but only the last one of a
+	// caller can go without stranding the ones after it
+	a := common.HexToAddress("0x0a")
+	assert.ErrorIs(addTx(p, withTip(newTestTx(a, 0), 2)), ErrUnderpriced)
+	st := withTip(newTestTx(a, 0), 4)
+	assert.NoError(addTx(p, st))
+	assert.Equal(4, p.q.len())
+	assert.Equal(0, p.queued.len())
+	p.sendEvictions()
+	ev := <-evictions
+	assert.Equal(EvictUnderpriced, ev.Reason)
+	assert.Equal(cheapest.HashToString(), ev.Tx.HashToString())
+	assert.Len(p.q.rstBuffer[b.String()], 2)
+}
+
+func TestIPSlots(t *testing.T) {
+	assert := assert.New(t)
+	p, err := NewPool(Config{BlockChain: newTestChain(), IPSlots: 2})
+	assert.NoError(err)
+	a := common.HexToAddress("0x0a")
+
+	for n := uint64(0); n < 2; n++ {
+		_, err := p.add(newTestTx(a, n), "10.0.0.1")
+		assert.NoError(err)
+	}
+	_, err = p.add(newTestTx(a, 2), "10.0.0.1")
+	assert.Error(err)
+	_, err = p.add(newTestTx(a, 2), "10.0.0.2")
+	assert.NoError(err)
+	assert.Equal(3, p.q.len())
+
+	// included transactions free their slots
+	p.FilterTransaction([]transaction.SignedTransaction{*newTestTx(a, 0)})
+	_, err = p.add(newTestTx(a, 3), "10.0.0.1")
+	assert.NoError(err)
+	assert.Equal(map[string]int{"10.0.0.1": 2, "10.0.0.2": 1}, p.ipSlots)
+}
@@ -20,6 +20,9 @@ type orderlyQueue struct {
 	rstBuffer  map[string][]receivedTransaction
 	timeBuffer map[string]int64
 	hashBuffer map[string]string
+	// baseFee of the next block, transactions are ordered by the tip they pay
+	// on top of it
+	baseFee *big.Int
 }
 
 type receivedTransaction struct {
@@ -39,6 +42,7 @@ func newQueue() *orderlyQueue {
 		rstBuffer:  make(map[string][]receivedTransaction),
 		timeBuffer: make(map[string]int64),
 		hashBuffer: make(map[string]string),
+		baseFee:    new(big.Int),
 	}
 }
 
@@ -50,14 +54,7 @@ func (q *orderlyQueue) push(st transaction.SignedTransaction) {
 	if rstList, ok := q.rstBuffer[st.Caller().String()]; ok {
 		for _, rst := range rstList {
 			if rst.nonce == st.GetNonce() {
-				// if rst.price >= st.GasCap() {
-				// 	return
-				// }
-
-				if rst.price.Cmp(st.GasCap()) >= 0 {
-					return
-				}
-
+				// the pool checked that st pays enough to replace it
 				q.update(st.Caller().String(), rst.nonce, withPrice(st.GasCap()), withTimestamp(time.Now().Second()))
 				delete(q.hashBuffer, q.stList[rst.idx].HashToString())
 				q.stList[rst.idx] = st
@@ -226,8 +223,7 @@ func (q *orderlyQueue) less(i int, y transaction.SignedTransaction) bool {
 
 	rstList := q.rstBuffer[y.Caller().String()]
 	if len(rstList) == 1 {
-		// return y.GasCap() <= x.GasCap()
-		return y.GasCap().Cmp(x.GasCap()) <= 0
+		return y.EffectiveTip(q.baseFee).Cmp(x.EffectiveTip(q.baseFee)) <= 0
 	}
 
 	for _, rst := range rstList {
@@ -236,8 +232,46 @@ func (q *orderlyQueue) less(i int, y transaction.SignedTransaction) bool {
 		}
 	}
 
-	// return y.GasCap() <= x.GasCap()
-	return y.GasCap().Cmp(x.GasCap()) <= 0
+	return y.EffectiveTip(q.baseFee).Cmp(x.EffectiveTip(q.baseFee)) <= 0
+}
+
+// cheapest returns the pending transaction paying the least tip on top of the
+// base fee among the last ones of the callers other than exclude, those are
+// the ones that can go without leaving a gap
+func (q *orderlyQueue) cheapest(exclude string) (*transaction.SignedTransaction, bool) {
+	var min *transaction.SignedTransaction
+	for caller, rstList := range q.rstBuffer {
+		if caller == exclude {
+			continue
+		}
+		last := rstList[0]
+		for _, rst := range rstList[1:] {
+			if rst.nonce > last.nonce {
+				last = rst
+			}
+		}
+		st := &q.stList[last.idx]
+		if min == nil || st.EffectiveTip(q.baseFee).Cmp(min.EffectiveTip(q.baseFee)) < 0 {
+			min = st
+		}
+	}
+	return min, min != nil
+}
+
+// reorder orders the transactions by the tip they pay on top of the base fee
+// when it changed
+func (q *orderlyQueue) reorder(baseFee *big.Int) *orderlyQueue {
+	if q.baseFee.Cmp(baseFee) == 0 {
+		return q
+	}
+
+	nq := newQueue()
+	nq.baseFee = new(big.Int).Set(baseFee)
+	for _, st := range q.stList {
+		nq.push(st)
+	}
+	nq.timeBuffer = q.timeBuffer
+	return nq
 }
 
 func timeKey(caller string, nonce uint64) string {
@@ -0,0 +1,161 @@
+package txpool
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"metechain/pkg/transaction"
+)
+
+// queuedTransaction is a transaction whose nonce is ahead of the next one of
+// its caller, it waits for the gap to fill
+type queuedTransaction struct {
+	st transaction.SignedTransaction
+	// unix time the transaction was queued at
+	timestamp int64
+}
+
+// futureQueue holds the queued transactions of each caller, by nonce
+type futureQueue struct {
+	accounts map[string][]queuedTransaction
+	size     int
+	// limit is the most queued transactions of a caller
+	limit int
+}
+
+func newFutureQueue(limit int) *futureQueue {
+	return &futureQueue{accounts: make(map[string][]queuedTransaction), limit: limit}
+}
+
+func (f *futureQueue) len() int {
+	return f.size
+}
+
+// add queues the transaction, it replaces the queued one with the same nonce
+func (f *futureQueue) add(st transaction.SignedTransaction, timestamp int64) error {
+	caller := st.Caller().String()
+	list := f.accounts[caller]
+	i := sort.Search(len(list), func(i int) bool { return list[i].st.GetNonce() >= st.GetNonce() })
+	if i < len(list) && list[i].st.GetNonce() == st.GetNonce() {
+		list[i] = queuedTransaction{st, timestamp}
+		return nil
+	}
+	if len(list) >= f.limit {
+		return fmt.Errorf("%s has %d queued transactions already", caller, len(list))
+	}
+
+	list = append(list, queuedTransaction{})
+	copy(list[i+1:], list[i:])
+	list[i] = queuedTransaction{st, timestamp}
+	f.accounts[caller] = list
+	f.size++
+	return nil
+}
+
+// pop takes the queued transaction of the caller with the nonce if it is the
+// lowest one
+func (f *futureQueue) pop(caller string, nonce uint64) (transaction.SignedTransaction, bool) {
+	list := f.accounts[caller]
+	if len(list) == 0 || list[0].st.GetNonce() != nonce {
+		return transaction.SignedTransaction{}, false
+	}
+	f.set(caller, list[1:])
+	return list[0].st, true
+}
+
+// forward drops the queued transactions of the caller below the nonce
+func (f *futureQueue) forward(caller string, nonce uint64) []transaction.SignedTransaction {
+	list := f.accounts[caller]
+	i := sort.Search(len(list), func(i int) bool { return list[i].st.GetNonce() >= nonce })
+	dropped := make([]transaction.SignedTransaction, i)
+	for j := range dropped {
+		dropped[j] = list[j].st
+	}
+	f.set(caller, list[i:])
+	return dropped
+}
+
+// expire drops the transactions queued before the unix time
+func (f *futureQueue) expire(before int64) []transaction.SignedTransaction {
+	var dropped []transaction.SignedTransaction
+	for caller, list := range f.accounts {
+		kept := list[:0]
+		for _, qt := range list {
+			if qt.timestamp < before {
+				dropped = append(dropped, qt.st)
+			} else {
+				kept = append(kept, qt)
+			}
+		}
+		f.set(caller, kept)
+	}
+	return dropped
+}
+
+// remove drops the queued transaction of the caller with the nonce
+func (f *futureQueue) remove(caller string, nonce uint64) {
+	list := f.accounts[caller]
+	for i, qt := range list {
+		if qt.st.GetNonce() == nonce {
+			f.set(caller, append(list[:i:i], list[i+1:]...))
+			return
+		}
+	}
+}
+
+// cheapest returns the queued transaction paying the least tip on top of the
+// base fee among the last ones of the callers other than exclude, those are
+// the ones that can go without leaving a gap
+func (f *futureQueue) cheapest(baseFee *big.Int, exclude string) (*transaction.SignedTransaction, bool) {
+	var min *transaction.SignedTransaction
+	for caller, list := range f.accounts {
+		if caller == exclude {
+			continue
+		}
+		st := &list[len(list)-1].st
+		if min == nil || st.EffectiveTip(baseFee).Cmp(min.EffectiveTip(baseFee)) < 0 {
+			min = st
+		}
+	}
+	return min, min != nil
+}
+
+func (f *futureQueue) set(caller string, list []queuedTransaction) {
+	f.size += len(list) - len(f.accounts[caller])
+	if len(list) == 0 {
+		delete(f.accounts, caller)
+	} else {
+		f.accounts[caller] = list
+	}
+}
+
+func (f *futureQueue) get(caller string, nonce uint64) (*transaction.SignedTransaction, bool) {
+	for i, qt := range f.accounts[caller] {
+		if qt.st.GetNonce() == nonce {
+			return &f.accounts[caller][i].st, true
+		}
+	}
+	return nil, false
+}
+
+func (f *futureQueue) getByHash(hash string) (*transaction.SignedTransaction, bool) {
+	for caller, list := range f.accounts {
+		for i := range list {
+			if list[i].st.HashToString() == hash {
+				return &f.accounts[caller][i].st, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (f *futureQueue) list() []transaction.SignedTransaction {
+	stList := make([]transaction.SignedTransaction, 0, f.size)
+	for _, list := range f.accounts {
+		for _, qt := range list {
+			stList = append(stList, qt.st)
+		}
+	}
+	return stList
+}
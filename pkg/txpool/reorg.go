@@ -0,0 +1,101 @@
+package txpool
+
+import (
+	"metechain/pkg/block"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// reorgChanSize is how many reorganizations of the chain wait for the pool
+const reorgChanSize = 16
+
+// loop follows the reorganizations of the chain until the pool is closed
+func (p *Pool) loop() {
+	for {
+		select {
+		case ev := <-p.reorgs:
+			p.reorg(ev)
+		case <-p.reorgSub.Err():
+			return
+		}
+	}
+}
+
+// reorg brings the pool in line with the chain switched to another branch.
+// The transactions of the blocks dropped that the branch does not include are
+// added again, the ones it includes are removed and the transactions of the
+// callers of either are checked against their nonces on chain.
+func (p *Pool) reorg(ev block.ReorgEvent) {
+	defer p.sendEvictions()
+	p.qlock.Lock()
+	defer p.qlock.Unlock()
+
+	callers := make(map[common.Address]struct{})
+	included := make(map[string]struct{})
+	var stList []transaction.SignedTransaction
+	for _, b := range ev.Added {
+		for _, ft := range b.Transactions {
+			if ft.IsCoinBaseTransaction() {
+				continue
+			}
+			callers[*ft.Caller()] = struct{}{}
+			included[ft.SignedTransaction.HashToString()] = struct{}{}
+			stList = append(stList, ft.SignedTransaction)
+		}
+	}
+	p.removeIncluded(stList)
+
+	reinjected := 0
+	for _, b := range ev.Dropped {
+		for _, ft := range b.Transactions {
+			if ft.IsCoinBaseTransaction() {
+				continue
+			}
+			callers[*ft.Caller()] = struct{}{}
+			if _, ok := included[ft.SignedTransaction.HashToString()]; ok {
+				continue
+			}
+			st := ft.SignedTransaction
+			if _, err := p.add(&st, ""); err != nil {
+				p.logger.Debug("drop reorged tx", zap.String("transaction", st.String()), zap.Error(err))
+				continue
+			}
+			reinjected++
+		}
+	}
+
+	for caller := range callers {
+		caller := caller
+		p.recheck(&caller)
+	}
+	p.logger.Info("reorg", zap.Int("dropped blocks", len(ev.Dropped)), zap.Int("added blocks", len(ev.Added)),
+		zap.Int("reinjected", reinjected))
+}
+
+// recheck drops the pending transactions of the caller below its nonce on
+// chain, the rest move between pending and queued as their nonces follow on
+// it
+func (p *Pool) recheck(caller *common.Address) {
+	nonce, err := p.bc.GetNonce(caller)
+	if err != nil {
+		p.logger.Error("recheck", zap.String("address", caller.String()), zap.Error(err))
+		return
+	}
+
+	var stale []uint64
+	for _, rst := range p.q.rstBuffer[caller.String()] {
+		if rst.nonce < nonce {
+			stale = append(stale, rst.nonce)
+		}
+	}
+	for _, n := range stale {
+		if idx, ok := p.findSignedTransactionIdx(*caller, n); ok {
+			p.evict(p.q.stList[idx], EvictInvalid, nil)
+			p.q.remove(idx)
+		}
+	}
+	p.demote(caller)
+	p.promote(caller)
+}
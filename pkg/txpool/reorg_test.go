@@ -0,0 +1,54 @@
+package txpool
+
+import (
+	"testing"
+
+	"metechain/pkg/block"
+	"metechain/pkg/transaction"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorg(t *testing.T) {
+	assert := assert.New(t)
+	bc := newTestChain()
+	p, err := NewPool(Config{BlockChain: bc})
+	assert.NoError(err)
+	defer p.Close()
+	a, b := common.HexToAddress("0x0a"), common.HexToAddress("0x0b")
+
+	newBlock := func(txs ...*transaction.SignedTransaction) *block.Block {
+		blk := &block.Block{}
+		for _, st := range txs {
+			blk.Transactions = append(blk.Transactions, &transaction.FinishedTransaction{SignedTransaction: *st})
+		}
+		return blk
+	}
+
+	// the old branch included a0, a1 and b0, the pool holds a2 and b1
+	bc.nonces[a], bc.nonces[b] = 2, 1
+	assert.NoError(addTx(p, newTestTx(a, 2)))
+	assert.NoError(addTx(p, newTestTx(b, 1)))
+	assert.Equal(2, p.q.len())
+
+	// the new branch includes a0 and b0, b1 too
+	bc.nonces[a], bc.nonces[b] = 1, 2
+	p.reorg(block.ReorgEvent{
+		Dropped: []*block.Block{newBlock(newTestTx(a, 0), newTestTx(a, 1)), newBlock(newTestTx(b, 0))},
+		Added:   []*block.Block{newBlock(newTestTx(a, 0), newTestTx(b, 0), newTestTx(b, 1))},
+	})
+
+	// a1 is back and a2 follows on it, b1 is gone
+	for n := uint64(1); n <= 2; n++ {
+		_, ok := p.FindSignedTransaction(&a, n)
+		assert.True(ok)
+	}
+	_, ok := p.FindSignedTransaction(&b, 1)
+	assert.False(ok)
+	assert.Equal(2, p.q.len())
+	assert.Equal(0, p.queued.len())
+	pending, err := p.Pending()
+	assert.NoError(err)
+	assert.Len(pending, 2)
+}